@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/internal/format"
+	"github.com/OpenCHAMI/magellan/pkg/bios"
+	"github.com/OpenCHAMI/magellan/pkg/power"
+	"github.com/cznic/mathutil"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/stmcginnis/gofish/schemas"
+)
+
+var (
+	biosInventoryFile string
+	biosOutputFormat  format.DataFormat = format.FORMAT_YAML
+	biosReferenceNode string
+	biosGoldenFile    string
+	biosSetFile       string
+	biosApplyTime     string
+	biosResetType     string
+	biosVerify        bool
+	biosVerifyTimeout time.Duration
+	biosVerifyPeriod  time.Duration
+)
+
+// The `bios` command groups subcommands that read and compare the BIOS
+// attributes of nodes found by a previous inventory crawl.
+var BiosCmd = &cobra.Command{
+	Use: "bios",
+	Example: `  // dump the BIOS attributes of all nodes in the inventory
+  magellan bios get -f nodes.json
+  // compare nodes against a reference node
+  magellan bios diff -f nodes.json --reference x1000c0s0b3n0
+  // compare nodes against a golden file
+  magellan bios diff -f nodes.json --golden golden.yaml x1000c0s0b3n0 x1000c0s0b3n1
+  // apply attributes on the next reset, reboot the nodes and verify the values
+  magellan bios set -f nodes.json --file attrs.yaml --reset ForceRestart --verify x1000c0s0b3n0`,
+	Short: "Get and compare BIOS attributes of nodes",
+	Long:  "Get and compare the Redfish BIOS attributes of nodes found by a previous inventory crawl.\nSee the 'collect' and 'power' commands for further details.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cmd.Help(); err != nil {
+			log.Error().Err(err).Msg("failed to print help")
+		}
+	},
+}
+
+var biosGetCmd = &cobra.Command{
+	Use:   "get [node-id...]",
+	Short: "Dump the BIOS attributes of nodes (all nodes in the inventory if none are given)",
+	Run: func(cmd *cobra.Command, args []string) {
+		nodes := getBiosAttributes(loadInventoryTargets(biosInventoryFile, args))
+		writeFormattedOutput(nodes, biosOutputFormat)
+	},
+}
+
+var biosDiffCmd = &cobra.Command{
+	Use:   "diff [node-id...]",
+	Short: "Report BIOS attributes that differ from a reference node or golden file, grouped by node model",
+	Run: func(cmd *cobra.Command, args []string) {
+		// the reference node does not need to be one of the compared nodes
+		ids := args
+		if biosReferenceNode != "" && len(ids) > 0 && !slices.Contains(ids, biosReferenceNode) {
+			ids = append(ids, biosReferenceNode)
+		}
+		targets := loadInventoryTargets(biosInventoryFile, ids)
+
+		// determine the reference attributes
+		var reference map[string]any
+		if biosGoldenFile != "" {
+			var err error
+			reference, err = bios.LoadAttributesFile(biosGoldenFile)
+			if err != nil {
+				log.Error().Err(err).Str("path", biosGoldenFile).Msg("failed to load golden file")
+				os.Exit(1)
+			}
+		}
+		nodes := getBiosAttributes(targets)
+		if biosGoldenFile == "" {
+			// no need to compare the reference node with itself
+			i := slices.IndexFunc(nodes, func(node bios.NodeBios) bool {
+				return node.ClusterID == biosReferenceNode
+			})
+			if i < 0 {
+				log.Error().Str("node", biosReferenceNode).Msg("failed to get BIOS attributes of reference node")
+				os.Exit(1)
+			}
+			reference = nodes[i].Attributes
+			nodes = slices.Delete(nodes, i, i+1)
+		}
+
+		diffs := bios.Diff(reference, nodes)
+		if len(diffs) == 0 {
+			log.Info().Int("nodes", len(nodes)).Msg("no BIOS attribute differences found")
+		}
+		writeFormattedOutput(diffs, biosOutputFormat)
+	},
+}
+
+var biosSetCmd = &cobra.Command{
+	Use:   "set --file <path> <node-id>...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Set BIOS attributes of nodes through their Redfish settings object",
+	Run: func(cmd *cobra.Command, args []string) {
+		attrs, err := bios.LoadAttributesFile(biosSetFile)
+		if err != nil {
+			log.Error().Err(err).Str("path", biosSetFile).Msg("failed to load attributes file")
+			os.Exit(1)
+		}
+		if len(attrs) == 0 {
+			log.Error().Str("path", biosSetFile).Msg("no attributes to set")
+			os.Exit(1)
+		}
+		applyTime, err := bios.ParseApplyTime(biosApplyTime)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to parse '--apply-time'")
+			os.Exit(1)
+		}
+		if biosVerify && biosResetType == "" && applyTime != schemas.ImmediateSettingsApplyTime {
+			log.Warn().Msgf("'--verify' without '--reset' waits for the nodes to be reset by other means before the %s apply time", applyTime)
+		}
+
+		targets := loadInventoryTargets(biosInventoryFile, args)
+		if concurrency <= 0 {
+			concurrency = mathutil.Clamp(len(targets), 1, 10000)
+		}
+
+		// PATCH the settings object of each node
+		results := concurrent_helper(concurrency, targets, func(target power.CrawlableNode) string {
+			if err := bios.SetNodeBios(target, attrs, applyTime); err != nil {
+				log.Error().Err(err).Msgf("failed to set BIOS attributes of node %s", target.ClusterID)
+				return "failure"
+			}
+			if applyTime == schemas.ImmediateSettingsApplyTime {
+				return "applied"
+			}
+			return "pending"
+		})
+
+		// only continue with the nodes that accepted the new settings
+		var pending []power.CrawlableNode
+		for _, target := range targets {
+			if results[target.ClusterID] != "failure" {
+				pending = append(pending, target)
+			}
+		}
+
+		// reset the nodes so that the pending values are applied
+		if biosResetType != "" && len(pending) > 0 {
+			resets := concurrent_helper(concurrency, pending, func(target power.CrawlableNode) string {
+				if _, err := power.ResetComputerSystem(target, schemas.ResetType(biosResetType)); err != nil {
+					log.Error().Err(err).Msgf("failed to reset node %s", target.ClusterID)
+					return "failure"
+				}
+				return "success"
+			})
+			for node, status := range resets {
+				if status == "failure" {
+					results[node] = "pending (reset failed)"
+				}
+			}
+		}
+		power.LogoutBMCSessions()
+
+		// check that the pending values were actually applied
+		if biosVerify && len(pending) > 0 {
+			verified := concurrent_helper(concurrency, pending, func(target power.CrawlableNode) string {
+				differences, err := bios.WaitForNodeBios(target, attrs, biosVerifyTimeout, biosVerifyPeriod)
+				if err != nil {
+					log.Error().Err(err).Msgf("failed to verify BIOS attributes of node %s", target.ClusterID)
+					return "unverified"
+				}
+				if len(differences) > 0 {
+					names := make([]string, 0, len(differences))
+					for _, d := range differences {
+						names = append(names, d.Attribute)
+					}
+					return "not applied: " + strings.Join(names, ", ")
+				}
+				return "verified"
+			})
+			power.LogoutBMCSessions()
+			for node, status := range verified {
+				results[node] = status
+			}
+		}
+
+		nodes := make([]string, 0, len(results))
+		for node := range results {
+			nodes = append(nodes, node)
+		}
+		sort.Strings(nodes)
+		for _, node := range nodes {
+			fmt.Printf("%s:\t%s\n", node, results[node])
+		}
+	},
+}
+
+// getBiosAttributes concurrently retrieves the BIOS attributes of each target
+// node. Nodes that fail are logged and left out of the result.
+func getBiosAttributes(targets []power.CrawlableNode) []bios.NodeBios {
+	var (
+		nodes []bios.NodeBios
+		mu    sync.Mutex
+	)
+
+	// Set the minimum/maximum number of concurrent processes
+	if concurrency <= 0 {
+		concurrency = mathutil.Clamp(len(targets), 1, 10000)
+	}
+	concurrent_helper(concurrency, targets, func(target power.CrawlableNode) string {
+		node, err := bios.GetNodeBios(target)
+		if err != nil {
+			log.Error().Err(err).Msgf("failed to get BIOS attributes of node %s", target.ClusterID)
+			return "failure"
+		}
+		mu.Lock()
+		nodes = append(nodes, *node)
+		mu.Unlock()
+		return "success"
+	})
+	power.LogoutBMCSessions()
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ClusterID < nodes[j].ClusterID
+	})
+	return nodes
+}
+
+func init() {
+	BiosCmd.PersistentFlags().StringVarP(&biosInventoryFile, "inventory-file", "f", "", "File containing node inventory from 'collect' ('-' for stdin)")
+	BiosCmd.PersistentFlags().StringVarP(&username, "username", "u", "", "Set the master BMC username")
+	BiosCmd.PersistentFlags().StringVarP(&password, "password", "p", "", "Set the master BMC password")
+	BiosCmd.PersistentFlags().StringVar(&secretsFile, "secrets-file", "", "Set path to the node secrets file")
+	BiosCmd.PersistentFlags().BoolVarP(&insecure, "insecure", "i", false, "Ignore SSL errors")
+	BiosCmd.PersistentFlags().VarP(&biosOutputFormat, "output-format", "F", "Set the output format (json|yaml)")
+	BiosCmd.PersistentFlags().StringVar(&node_key, "node-key", string(power.NodeKeyAuto), "Match node IDs against this property of the inventory (auto|xname|uuid|serial|uri)")
+	BiosCmd.PersistentFlags().StringVar(&node_map_file, "node-map", "", "YAML file mapping node names to an xname, UUID, serial number or System URI")
+	BiosCmd.PersistentFlags().StringVarP(&outputPath, "output-file", "o", "", "Set the path to write the output (defaults to standard output)")
+
+	biosDiffCmd.Flags().StringVar(&biosReferenceNode, "reference", "", "Compare nodes against the BIOS attributes of this node")
+	biosDiffCmd.Flags().StringVar(&biosGoldenFile, "golden", "", "Compare nodes against the BIOS attributes in this YAML file")
+	biosDiffCmd.MarkFlagsMutuallyExclusive("reference", "golden")
+	biosDiffCmd.MarkFlagsOneRequired("reference", "golden")
+
+	biosSetCmd.Flags().StringVar(&biosSetFile, "file", "", "YAML file containing the BIOS attributes to set")
+	biosSetCmd.Flags().StringVar(&biosApplyTime, "apply-time", string(schemas.OnResetSettingsApplyTime), "Set when the attributes are applied (OnReset|MaintenanceWindow|Immediate)")
+	biosSetCmd.Flags().StringVarP(&biosResetType, "reset", "r", "", "Redfish reset type to perform after setting the attributes (e.g. ForceRestart)")
+	biosSetCmd.Flags().BoolVar(&biosVerify, "verify", false, "Wait until the new attribute values are reported as applied")
+	biosSetCmd.Flags().DurationVar(&biosVerifyTimeout, "verify-timeout", 15*time.Minute, "Set how long to wait for the attributes to be applied")
+	biosSetCmd.Flags().DurationVar(&biosVerifyPeriod, "verify-interval", 30*time.Second, "Set how often to check whether the attributes were applied")
+	checkMarkFlagRequiredError(biosSetCmd.MarkFlagRequired("file"))
+
+	checkRegisterFlagCompletionError(BiosCmd.RegisterFlagCompletionFunc("output-format", completionFormatData))
+	checkRegisterFlagCompletionError(BiosCmd.RegisterFlagCompletionFunc("node-key", completionNodeKey))
+	checkRegisterFlagCompletionError(biosSetCmd.RegisterFlagCompletionFunc("apply-time", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"OnReset", "MaintenanceWindow", "Immediate"}, cobra.ShellCompDirectiveNoFileComp
+	}))
+
+	BiosCmd.AddCommand(biosGetCmd)
+	BiosCmd.AddCommand(biosDiffCmd)
+	BiosCmd.AddCommand(biosSetCmd)
+	rootCmd.AddCommand(BiosCmd)
+}
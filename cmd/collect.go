@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -9,8 +8,7 @@ import (
 	"github.com/OpenCHAMI/magellan/internal/cache/sqlite"
 	"github.com/OpenCHAMI/magellan/internal/format"
 	magellan "github.com/OpenCHAMI/magellan/pkg"
-	"github.com/OpenCHAMI/magellan/pkg/bmc"
-	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/OpenCHAMI/magellan/pkg/idmap"
 	"github.com/cznic/mathutil"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -22,6 +20,13 @@ var (
 	collectInputFormat  format.DataFormat = format.FORMAT_JSON
 	collectOutputFormat format.DataFormat = format.FORMAT_JSON
 	collectDataArgs     []string
+	collectSensors      bool
+	sensorsOutputPath   string
+	idTemplate          string
+	idFromLocation      string
+	idMappers           []string
+	idReportPath        string
+	writeBackCreds      bool
 )
 
 // The `collect` command fetches data from a collection of BMC nodes.
@@ -36,11 +41,25 @@ var CollectCmd = &cobra.Command{
   // data in a file called 'nodes.yaml'
   magellan collect -u $bmc_username -p $bmc_password -o nodes.yaml
 
+  // name BMCs after the rack and slot reported in their Redfish Location
+  magellan collect --id-template '{{required "rack" .Rack}}-{{required "slot" .Slot}}' -o nodes.yaml
+
+  // build XNAMEs from the rack and rack offset reported by each chassis, and
+  // fall back to the generated XNAMEs for chassis that don't report them
+  magellan collect --id-from-location -o nodes.yaml
+
+  // try the mapping file first, then the chassis location and finally the
+  // generated XNAME, and list the BMCs with ID problems before sending
+  magellan collect -m @mappings.yaml --id-mappers user-map,location,xname --id-report ids.yaml -o nodes.yaml
+
   // run a collect using secrets from the secrets manager
   export MASTER_KEY=$(magellan secrets generatekey)
   magellan secrets store $node_creds_json -f nodes.json
   magellan collect -o nodes.yaml
 
+  // also take a snapshot of temperatures, fans, PSUs and voltage rails
+  magellan collect -o nodes.yaml --sensors --sensors-file sensors.yaml
+
   // Take the output of 'scan' and input directly into 'collect'
   magellan scan --subnet 172.18.0.0/24 --port 5000 -l info -i -F json | ./magellan collect -f json --show-output -i
   
@@ -134,60 +153,7 @@ var CollectCmd = &cobra.Command{
 		}
 
 		// use secret store for BMC credentials, and/or credential CLI flags
-		var store secrets.SecretStore
-		if username != "" && password != "" {
-			// First, try and load credentials from --username and --password if both are set.
-			log.Debug().Msgf("--username and --password specified, using them for BMC credentials")
-			store = secrets.NewStaticStore(username, password)
-		} else {
-			// Alternatively, locate specific credentials (falling back to default) and override those
-			// with --username or --password if either are passed.
-			log.Debug().Msgf("one or both of --username and --password NOT passed, attempting to obtain missing credentials from secret store at %s", secretsFile)
-			if store, err = secrets.OpenStore(secretsFile); err != nil {
-				log.Error().Err(err).Msg("failed to open local secrets store")
-			}
-
-			// Temporarily override username/password of each BMC if one of those
-			// flags is passed. The expectation is that if the flag is specified
-			// on the command line, it should be used.
-			if username != "" {
-				log.Info().Msg("--username passed, temporarily overriding all usernames from secret store with value")
-			}
-			if password != "" {
-				log.Info().Msg("--password passed, temporarily overriding all passwords from secret store with value")
-			}
-			switch s := store.(type) {
-			case *secrets.StaticStore:
-				if username != "" {
-					s.Username = username
-				}
-				if password != "" {
-					s.Password = password
-				}
-			case *secrets.LocalSecretStore:
-				for k := range s.Secrets {
-					if creds, err := bmc.GetBMCCredentials(store, k); err != nil {
-						log.Error().Str("id", k).Err(err).Msg("failed to override BMC credentials")
-					} else {
-						if username != "" {
-							creds.Username = username
-						}
-						if password != "" {
-							creds.Password = password
-						}
-
-						if newCreds, err := json.Marshal(creds); err != nil {
-							log.Error().Str("id", k).Err(err).Msg("failed to override BMC credentials: marshal error")
-						} else {
-							err = s.StoreSecretByID(k, string(newCreds))
-							if err != nil {
-								log.Error().Err(err).Str("id", k).Msg("failed to store secret by ID")
-							}
-						}
-					}
-				}
-			}
-		}
+		store := loadBMCSecretStore()
 
 		// set the collect parameters from CLI params
 		params := &magellan.CollectParams{
@@ -200,6 +166,15 @@ var CollectCmd = &cobra.Command{
 			InputFormat:  collectInputFormat,
 			SecretStore:  store,
 			BMCIDMap:     idMap,
+			IDTemplate:   viper.GetString("collect.id-template"),
+			IDLocation:   viper.GetString("collect.id-from-location"),
+			IDMappers:    viper.GetStringSlice("collect.id-mappers"),
+			IDReportPath: viper.GetString("collect.id-report"),
+
+			WriteBackCredentials: viper.GetBool("collect.write-back-creds"),
+
+			CollectSensors:    viper.GetBool("collect.sensors"),
+			SensorsOutputPath: viper.GetString("collect.sensors-file"),
 		}
 
 		// show all of the 'collect' parameters being set from CLI if verbose
@@ -234,7 +209,16 @@ func init() {
 	CollectCmd.Flags().VarP(&collectInputFormat, "input-format", "f", "Set the default input data format (json|yaml)")
 	CollectCmd.Flags().VarP(&collectOutputFormat, "output-format", "F", "Set the default output data format (json|yaml; can be overridden by file extensions)")
 	CollectCmd.Flags().StringVarP(&idMap, "bmc-id-map", "m", "", "Set the BMC ID mapping from raw json data or use @<path> to specify a file path (json or yaml input)")
+	CollectCmd.Flags().StringVar(&idTemplate, "id-template", "", "Set a Go template to build BMC IDs from, e.g. 'rack{{.Octet3}}-node{{.Octet4}}' (ignored with --bmc-id-map)")
+	CollectCmd.Flags().StringVar(&idFromLocation, "id-from-location", "", "Build BMC IDs from the Redfish Location of the chassis as 'xname' or with a Go template, falling back to the other ID flags")
+	CollectCmd.Flags().Lookup("id-from-location").NoOptDefVal = idmap.LocationFormatXname
+	CollectCmd.Flags().StringSliceVar(&idMappers, "id-mappers", []string{}, fmt.Sprintf("Set the BMC ID mappers to try in order (%s; defaults to those implied by the other ID flags)", strings.Join(idmap.MapperNames, "|")))
+	CollectCmd.Flags().StringVar(&idReportPath, "id-report", "", "Set the path to write the skipped, conflicting and duplicate BMC IDs to ('-' for stderr)")
+	CollectCmd.Flags().Lookup("id-report").NoOptDefVal = "-"
+	CollectCmd.Flags().BoolVar(&writeBackCreds, "write-back-creds", false, "Store the candidate credentials that authenticated with each BMC under its URI in the secrets file")
 	CollectCmd.Flags().StringArrayVarP(&collectDataArgs, "data", "d", []string{}, "Set the data as input for collect (prepend @ for files)")
+	CollectCmd.Flags().BoolVar(&collectSensors, "sensors", false, "Also collect a snapshot of chassis sensors (temperatures, fans, power supplies, voltages)")
+	CollectCmd.Flags().StringVar(&sensorsOutputPath, "sensors-file", "", "Set the path to store the sensor snapshot (defaults to the output file with a '.sensors' suffix)")
 
 	// set mutually exclusive flags
 	CollectCmd.MarkFlagsMutuallyExclusive("output-file", "output-dir")
@@ -247,6 +231,13 @@ func init() {
 	checkBindFlagError(viper.BindPFlag("collect.protocol", CollectCmd.Flags().Lookup("protocol")))
 	checkBindFlagError(viper.BindPFlag("collect.output-file", CollectCmd.Flags().Lookup("output-file")))
 	checkBindFlagError(viper.BindPFlag("collect.output-dir", CollectCmd.Flags().Lookup("output-dir")))
+	checkBindFlagError(viper.BindPFlag("collect.sensors", CollectCmd.Flags().Lookup("sensors")))
+	checkBindFlagError(viper.BindPFlag("collect.sensors-file", CollectCmd.Flags().Lookup("sensors-file")))
+	checkBindFlagError(viper.BindPFlag("collect.id-template", CollectCmd.Flags().Lookup("id-template")))
+	checkBindFlagError(viper.BindPFlag("collect.id-from-location", CollectCmd.Flags().Lookup("id-from-location")))
+	checkBindFlagError(viper.BindPFlag("collect.id-mappers", CollectCmd.Flags().Lookup("id-mappers")))
+	checkBindFlagError(viper.BindPFlag("collect.id-report", CollectCmd.Flags().Lookup("id-report")))
+	checkBindFlagError(viper.BindPFlag("collect.write-back-creds", CollectCmd.Flags().Lookup("write-back-creds")))
 	// checkBindFlagError(viper.BindPFlag("collect.force-update", CollectCmd.Flags().Lookup("force-update")))
 	// checkBindFlagError(viper.BindPFlag("collect.cacert", CollectCmd.Flags().Lookup("cacert")))
 	checkBindFlagError(viper.BindPFlags(CollectCmd.Flags()))
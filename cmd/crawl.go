@@ -1,14 +1,13 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/rs/zerolog/log"
 
 	"github.com/OpenCHAMI/magellan/internal/format"
 	urlx "github.com/OpenCHAMI/magellan/internal/url"
-	"github.com/OpenCHAMI/magellan/pkg/bmc"
+	"github.com/OpenCHAMI/magellan/internal/util"
 	"github.com/OpenCHAMI/magellan/pkg/crawler"
 	"github.com/OpenCHAMI/magellan/pkg/secrets"
 	"github.com/spf13/cobra"
@@ -58,55 +57,37 @@ var CrawlCmd = &cobra.Command{
 				log.Error().Str("uri", uri).Err(err).Msg("failed to open local secrets store")
 				return
 			}
+			store = newSecretMatcher(store)
+
+			// With neither flag passed, the crawler gets the credentials
+			// matching the URI from the store itself, trying every
+			// candidate credential of the matching secret.
+			if username != "" || password != "" {
+				// Only one of the flags was passed; get credentials from
+				// secrets store to fill in the gaps.
+				//
+				// Attempt to get the credentials matching the URI (see
+				// bmc.MatchSecretID), falling back to default.
+				creds := util.GetBMCCredentials(store, uri, func() string {
+					return crawler.GetBMCVendor(crawler.CrawlerConfig{URI: uri, Insecure: insecure})
+				})
+				nodeCreds := secrets.StaticStore{
+					Username: creds.Username,
+					Password: creds.Password,
+				}
 
-			// Either none of the flags were passed or only one of them were; get
-			// credentials from secrets store to fill in the gaps.
-			//
-			// Attempt to get URI-specific credentials.
-			var nodeCreds secrets.StaticStore
-			if uriCreds, err := store.GetSecretByID(uri); err != nil {
-				// Specific credentials for URI not found, fetch default.
-				log.Warn().Str("uri", uri).Msg("specific credentials not found, falling back to default")
-				defaultSecret, err := store.GetSecretByID(secrets.DEFAULT_KEY)
-				if err != nil {
-					// We've exhausted all options, the credentials will be blank unless
-					// overridden by a CLI flag.
-					log.Warn().Str("uri", uri).Err(err).Msg("no default credentials were set, they will be blank unless overridden by CLI flags")
-				} else {
-					// Default credentials found, use them.
-					var creds bmc.BMCCredentials
-					if err = json.Unmarshal([]byte(defaultSecret), &creds); err != nil {
-						log.Warn().Str("uri", uri).Err(err).Msg("failed to unmarshal default secrets store credentials")
-					} else {
-						log.Info().Str("uri", uri).Msg("default credentials found, using")
-						nodeCreds.Username = creds.Username
-						nodeCreds.Password = creds.Password
-					}
+				// Override the fetched credentials with the flag that was passed.
+				if username != "" {
+					log.Info().Str("uri", uri).Msg("--username was set, overriding username for this BMC")
+					nodeCreds.Username = username
 				}
-			} else {
-				// Specific URI credentials found, use them.
-				var creds bmc.BMCCredentials
-				if err = json.Unmarshal([]byte(uriCreds), &creds); err != nil {
-					log.Warn().Str("uri", uri).Err(err).Msg("failed to unmarshal uri credentials")
-				} else {
-					nodeCreds.Username = creds.Username
-					nodeCreds.Password = creds.Password
-					log.Info().Str("uri", uri).Msg("specific credentials found, using")
+				if password != "" {
+					log.Info().Str("uri", uri).Msg("--password was set, overriding password for this BMC")
+					nodeCreds.Password = password
 				}
-			}
 
-			// If either of the flags were passed, override the fetched
-			// credentials with them.
-			if username != "" {
-				log.Info().Str("uri", uri).Msg("--username was set, overriding username for this BMC")
-				nodeCreds.Username = username
-			}
-			if password != "" {
-				log.Info().Str("uri", uri).Msg("--password was set, overriding password for this BMC")
-				nodeCreds.Password = password
+				store = &nodeCreds
 			}
-
-			store = &nodeCreds
 		}
 
 		var (
@@ -117,6 +98,8 @@ var CrawlCmd = &cobra.Command{
 				CredentialStore: store,
 				Insecure:        insecure,
 				UseDefault:      true,
+
+				WriteBackCredentials: writeBackCreds,
 			}
 		)
 
@@ -152,6 +135,7 @@ func init() {
 	CrawlCmd.Flags().BoolVar(&showOutput, "show", false, "Show the output of a crawl")
 	CrawlCmd.Flags().BoolVar(&showOutput, "show-output", false, "Show the output of a collect run")
 	CrawlCmd.Flags().VarP(&crawlOutputFormat, "output-format", "F", "Set the output format (json|yaml)")
+	CrawlCmd.Flags().BoolVar(&writeBackCreds, "write-back-creds", false, "Store the candidate credentials that authenticated under the BMC URI in the secrets file")
 
 	checkRegisterFlagCompletionError(CrawlCmd.RegisterFlagCompletionFunc("output-format", completionFormatData))
 
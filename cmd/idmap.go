@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/OpenCHAMI/magellan/internal/format"
+	"github.com/OpenCHAMI/magellan/pkg/idmap"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var idMapInputFormat format.DataFormat = format.FORMAT_YAML
+
+// The `idmap` command groups subcommands that work on the BMC ID maps
+// passed to `collect` with --bmc-id-map.
+var IDMapCmd = &cobra.Command{
+	Use:   "idmap",
+	Short: "Work with BMC ID maps",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cmd.Help(); err != nil {
+			log.Error().Err(err).Msg("failed to print help")
+		}
+	},
+}
+
+var idMapConvertCmd = &cobra.Command{
+	Use: "convert <path>",
+	Example: `  // turn a spreadsheet export with a 'bmc-mac-addr' and an 'xname' column into a BMC ID map
+  magellan idmap convert bmcs.csv -o mappings.yaml
+  // turn an /etc/hosts-style file into a BMC ID map by IP address
+  magellan idmap convert /etc/hosts -o mappings.yaml
+  // check a BMC ID map and show it with normalized keys and IDs
+  magellan idmap convert mappings.json`,
+	Args:  cobra.ExactArgs(1),
+	Short: "Convert a BMC ID map to canonical YAML",
+	Long: "Convert a BMC ID map in JSON, YAML, CSV or /etc/hosts format to the canonical YAML form used by 'collect --bmc-id-map'.\n" +
+		"CSV files need a header with a column named after a map key (" + strings.Join(idmap.MapKeys, ", ") + ") and an 'id' or\n" +
+		"'xname' column; other columns are ignored. Files named 'hosts' or ending in '.hosts' map the IPv4 address of each\n" +
+		"line to its first name. Keys and IDs are normalized, and invalid or duplicate entries are reported with their lines.",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, err := idmap.ConvertBMCIDMap("@"+strings.TrimPrefix(args[0], "@"), idMapInputFormat)
+		if err != nil {
+			log.Error().Err(err).Str("path", args[0]).Msg("failed to convert BMC ID map")
+			os.Exit(1)
+		}
+		if outputPath == "" {
+			fmt.Print(string(output))
+			return
+		}
+		if err = os.WriteFile(outputPath, output, 0o644); err != nil {
+			log.Error().Err(err).Str("path", outputPath).Msg("failed to write output")
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	idMapConvertCmd.Flags().StringVarP(&outputPath, "output-file", "o", "", "Set the path to write the BMC ID map (defaults to standard output)")
+	idMapConvertCmd.Flags().VarP(&idMapInputFormat, "input-format", "f", "Set the input format of JSON or YAML files without an extension (json|yaml)")
+
+	checkRegisterFlagCompletionError(idMapConvertCmd.RegisterFlagCompletionFunc("input-format", completionFormatData))
+
+	IDMapCmd.AddCommand(idMapConvertCmd)
+	rootCmd.AddCommand(IDMapCmd)
+}
@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/internal/format"
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
+	"github.com/OpenCHAMI/magellan/pkg/logs"
+	"github.com/OpenCHAMI/magellan/pkg/power"
+	"github.com/cznic/mathutil"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/schemas"
+)
+
+var (
+	logsInventoryFile  string
+	logsInputFormat    format.DataFormat = format.FORMAT_JSON
+	logsOutputFormat   format.DataFormat = format.FORMAT_JSON
+	logsSince          string
+	logsSeverity       string
+	logsClearAfterSave bool
+)
+
+// The `logs` command downloads the entries of every Redfish LogService (such
+// as the SEL) found on a collection of BMCs. Hosts can be passed directly as
+// arguments or read from the output of a previous `collect`.
+var LogsCmd = &cobra.Command{
+	Use: "logs [hosts...]",
+	Example: `  // download all log entries from a single BMC
+  magellan logs -u $bmc_username -p $bmc_password https://172.16.0.101
+
+  // download only warnings and critical entries from the last day
+  magellan logs --since 24h --severity Warning -f nodes.json -F ndjson
+
+  // archive the logs of all collected BMCs to a file, then clear them
+  magellan logs -f nodes.json -o sel-archive.yaml --clear`,
+	Short: "Download the event logs of BMCs",
+	Long: "Download the entries of all Redfish LogServices (such as the SEL) of the Managers and Systems of BMCs.\n" +
+		"Hosts can be passed as arguments and/or read from the output of 'collect' with '--inventory-file'.\n\n" +
+		"When '--clear' is passed, logs are only cleared after they were successfully written to the output file.",
+	Run: func(cmd *cobra.Command, args []string) {
+		// build the list of hosts from arguments and collected inventory
+		hosts := make([]string, 0, len(args))
+		seen := make(map[string]bool)
+		addHost := func(host string) {
+			if !strings.Contains(host, "://") {
+				host = "https://" + host
+			}
+			host = strings.TrimSuffix(host, "/")
+			if !seen[host] {
+				seen[host] = true
+				hosts = append(hosts, host)
+			}
+		}
+		for _, arg := range args {
+			addHost(arg)
+		}
+		if logsInventoryFile != "" {
+			nodes, err := power.ParseInventory(logsInventoryFile, logsInputFormat)
+			if err != nil {
+				log.Fatal().Err(err).Msgf("failed to parse inventory file %s", logsInventoryFile)
+			}
+			for _, node := range nodes {
+				addHost(node.BmcIP)
+			}
+		}
+		if len(hosts) == 0 {
+			log.Error().Msg("requires at least one host as argument or an inventory file with '--inventory-file'")
+			os.Exit(1)
+		}
+
+		// clearing is only safe once the logs have been archived somewhere
+		if logsClearAfterSave && outputPath == "" {
+			log.Error().Msg("'--clear' requires '--output-file' to archive the logs before clearing")
+			os.Exit(1)
+		}
+		// entries left out by a filter would be cleared without being archived
+		if logsClearAfterSave && (viper.GetString("logs.since") != "" || viper.GetString("logs.severity") != "") {
+			log.Error().Msg("'--clear' cannot be used with '--since' or '--severity', since filtered out entries would be lost")
+			os.Exit(1)
+		}
+
+		// set up the filter from CLI flags
+		var (
+			filter logs.LogFilter
+			err    error
+		)
+		filter.Since, err = logs.ParseSince(viper.GetString("logs.since"), time.Now())
+		if err != nil {
+			log.Error().Err(err).Msg("failed to parse '--since'")
+			os.Exit(1)
+		}
+		filter.Severity, err = logs.ParseSeverity(viper.GetString("logs.severity"))
+		if err != nil {
+			log.Error().Err(err).Msg("failed to parse '--severity'")
+			os.Exit(1)
+		}
+		var outputFormat format.DataFormat
+		if err = outputFormat.Set(viper.GetString("logs.output-format")); err != nil {
+			log.Error().Err(err).Msg("invalid output format (see --output-format flag for options)")
+			os.Exit(1)
+		}
+
+		// set the minimum/maximum number of concurrent processes
+		if concurrency <= 0 {
+			concurrency = mathutil.Clamp(len(hosts), 1, 10000)
+		}
+
+		// use secret store for BMC credentials, and/or credential CLI flags
+		store := loadBMCSecretStore()
+
+		// download logs from each host concurrently, keeping the clients open
+		// until after the logs are cleared
+		type hostLogs struct {
+			client   *gofish.APIClient
+			services []*schemas.LogService
+		}
+		var (
+			entries   []logs.LogEntry
+			collected = make(map[string]hostLogs, len(hosts))
+			failed    []string
+			mu        sync.Mutex
+			wg        sync.WaitGroup
+			chHosts   = make(chan string, len(hosts))
+		)
+		for _, host := range hosts {
+			chHosts <- host
+		}
+		close(chHosts)
+
+		wg.Add(concurrency)
+		for i := 0; i < concurrency; i++ {
+			go func() {
+				defer wg.Done()
+				for host := range chHosts {
+					client, err := crawler.GetBMCClient(crawler.CrawlerConfig{
+						URI:             host,
+						CredentialStore: store,
+						Insecure:        insecure,
+						UseDefault:      true,
+					})
+					if err != nil {
+						log.Error().Err(err).Str("host", host).Msg("failed to connect to BMC")
+						mu.Lock()
+						failed = append(failed, host)
+						mu.Unlock()
+						continue
+					}
+					hostEntries, services, err := logs.CollectLogs(client, host, filter)
+					if err != nil {
+						log.Error().Err(err).Str("host", host).Msg("failed to collect logs")
+						client.Logout()
+						mu.Lock()
+						failed = append(failed, host)
+						mu.Unlock()
+						continue
+					}
+					log.Debug().Str("host", host).Int("entries", len(hostEntries)).Int("log_services", len(services)).Msg("collected logs")
+					mu.Lock()
+					entries = append(entries, hostEntries...)
+					collected[host] = hostLogs{client: client, services: services}
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		defer func() {
+			for _, h := range collected {
+				h.client.Logout()
+			}
+		}()
+
+		// keep output stable regardless of the order hosts finished in
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].Host != entries[j].Host {
+				return entries[i].Host < entries[j].Host
+			}
+			if entries[i].LogService != entries[j].LogService {
+				return entries[i].LogService < entries[j].LogService
+			}
+			return entries[i].Created.Before(entries[j].Created)
+		})
+
+		// write the entries to a file or standard output
+		if entries == nil {
+			entries = []logs.LogEntry{}
+		}
+		if outputPath != "" {
+			outputFormat = format.DataFormatFromFileExt(outputPath, outputFormat)
+		}
+		output, err := format.MarshalData(entries, outputFormat)
+		if err != nil {
+			log.Error().Err(err).Msgf("failed to marshal log entries to %s", strings.ToUpper(outputFormat.String()))
+			os.Exit(1)
+		}
+		if outputPath == "" {
+			fmt.Println(string(output))
+			return
+		}
+		if err = os.WriteFile(outputPath, output, 0o644); err != nil {
+			log.Error().Err(err).Str("path", outputPath).Msg("failed to write log entries, logs will not be cleared")
+			os.Exit(1)
+		}
+		log.Info().Str("path", outputPath).Int("entries", len(entries)).Msg("saved log entries")
+
+		// clear the logs only after they were saved
+		if logsClearAfterSave {
+			for host, h := range collected {
+				if err := logs.ClearLogs(h.services); err != nil {
+					log.Error().Err(err).Str("host", host).Msg("failed to clear logs")
+					continue
+				}
+				log.Info().Str("host", host).Msg("cleared logs")
+			}
+		}
+		if len(failed) > 0 {
+			log.Warn().Strs("hosts", failed).Msg("failed to collect logs from some hosts")
+		}
+	},
+}
+
+func init() {
+	LogsCmd.Flags().StringVarP(&username, "username", "u", "", "Set the master BMC username")
+	LogsCmd.Flags().StringVarP(&password, "password", "p", "", "Set the master BMC password")
+	LogsCmd.Flags().StringVar(&secretsFile, "secrets-file", "", "Set path to the node secrets file")
+	LogsCmd.Flags().BoolVarP(&insecure, "insecure", "i", false, "Skip TLS certificate verification")
+	LogsCmd.Flags().StringVarP(&logsInventoryFile, "inventory-file", "f", "", "Read hosts from the output of 'collect' ('-' for stdin)")
+	LogsCmd.Flags().Var(&logsInputFormat, "input-format", "Set the format of the inventory file (json|yaml)")
+	LogsCmd.Flags().VarP(&logsOutputFormat, "output-format", "F", "Set the output format (json|yaml|ndjson; can be overridden by file extensions)")
+	LogsCmd.Flags().StringVarP(&outputPath, "output-file", "o", "", "Set the path to write the log entries (defaults to standard output)")
+	LogsCmd.Flags().StringVar(&logsSince, "since", "", "Only include entries created since a duration ago (e.g. 24h) or a timestamp (e.g. 2025-01-31)")
+	LogsCmd.Flags().StringVar(&logsSeverity, "severity", "", "Only include entries of at least this severity (OK|Warning|Critical)")
+	LogsCmd.Flags().BoolVar(&logsClearAfterSave, "clear", false, "Clear the logs on each BMC after they were written to the output file")
+
+	checkRegisterFlagCompletionError(LogsCmd.RegisterFlagCompletionFunc("input-format", completionFormatData))
+	checkRegisterFlagCompletionError(LogsCmd.RegisterFlagCompletionFunc("output-format", completionFormatData))
+	checkRegisterFlagCompletionError(LogsCmd.RegisterFlagCompletionFunc("severity", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"OK", "Warning", "Critical"}, cobra.ShellCompDirectiveNoFileComp
+	}))
+
+	// bind flags to config properties
+	checkBindFlagError(viper.BindPFlag("logs.since", LogsCmd.Flags().Lookup("since")))
+	checkBindFlagError(viper.BindPFlag("logs.severity", LogsCmd.Flags().Lookup("severity")))
+	checkBindFlagError(viper.BindPFlag("logs.output-format", LogsCmd.Flags().Lookup("output-format")))
+
+	rootCmd.AddCommand(LogsCmd)
+}
@@ -4,10 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 	"unicode"
 
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
 	"github.com/OpenCHAMI/magellan/pkg/jaws"
 	"github.com/OpenCHAMI/magellan/pkg/pdu"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/OpenCHAMI/magellan/pkg/snmp"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
@@ -33,11 +37,17 @@ func transformToSMDFormat(inventory *pdu.PDUInventory) []map[string]any {
 		idSuffix := fmt.Sprintf("p%dv%s", pValue, numberPart)
 
 		rawOutlet := map[string]any{
-			"original_id": outlet.ID,
-			"id_suffix":   idSuffix,
-			"name":        outlet.Name,
-			"state":       outlet.PowerState,
-			"socket_type": outlet.SocketType,
+			"original_id":  outlet.ID,
+			"id_suffix":    idSuffix,
+			"name":         outlet.Name,
+			"state":        outlet.PowerState,
+			"socket_type":  outlet.SocketType,
+			"current":      outlet.Current,
+			"voltage":      outlet.Voltage,
+			"active_power": outlet.ActivePower,
+		}
+		if outlet.Node != "" {
+			rawOutlet["node"] = outlet.Node
 		}
 		smdOutlets = append(smdOutlets, rawOutlet)
 	}
@@ -50,53 +60,86 @@ func transformToSMDFormat(inventory *pdu.PDUInventory) []map[string]any {
 		"Enabled":            true,
 		"RediscoverOnUpdate": false,
 		"PDUInventory": map[string]any{
-			"Outlets": smdOutlets,
+			"Model":            inventory.Model,
+			"SerialNumber":     inventory.SerialNumber,
+			"FirmwareVersion":  inventory.FirmwareVersion,
+			"TotalActivePower": inventory.TotalActivePower(),
+			"Phases":           inventory.Phases,
+			"Branches":         inventory.Branches,
+			"Outlets":          smdOutlets,
 		},
 	}
 
 	return []map[string]any{pduRecord}
 }
 
-var pduCmd = &cobra.Command{
+var pduProtocol string
+
+var collectPDUCmd = &cobra.Command{
 	Use:   "pdu [hosts...]",
-	Short: "Collect inventory from JAWS-based PDUs",
-	Long:  `Connects to one or more PDUs with a JAWS interface to collect hardware inventory.`,
+	Short: "Collect inventory from JAWS-based, Redfish and SNMP PDUs",
+	Long: "Connects to one or more PDUs to collect hardware inventory. PDUs with a Redfish PowerEquipment\n" +
+		"resource are crawled through Redfish, all others through their JAWS interface, unless --protocol is set.\n" +
+		"APC and Raritan PDUs can be crawled over SNMP with --protocol snmp. The SNMP credentials are read\n" +
+		"from the secrets file, stored under the PDU host or as the default, e.g. {\"community\": \"public\"}\n" +
+		"for SNMPv2c or {\"username\": \"...\", \"auth_password\": \"...\", \"priv_password\": \"...\"} for SNMPv3.",
 	Example: `  // Collect inventory from a single PDU using credentials
   magellan collect pdu x3000m0 --username admin --password initial0
 
   // Collect from multiple PDUs and send to SMD
-  magellan collect pdu x3000m0 x3000m1 -u admin -p initial0 | magellan send <smd-endpoint>`,
+  magellan collect pdu x3000m0 x3000m1 -u admin -p initial0 | magellan send <smd-endpoint>
+
+  // Skip detection for PDUs known to support Redfish
+  magellan collect pdu x3000m2 --protocol redfish -u admin -p initial0
+
+  // Include the node powered by each outlet
+  magellan collect pdu x3000m0 --outlet-map outlets.yaml -u admin -p initial0
+
+  // Collect from a PDU over SNMP with credentials from the secrets file
+  export MASTER_KEY=$(magellan secrets generatekey)
+  magellan secrets store x3000m3 '{"community": "public"}' --format json -f secrets.json
+  magellan collect pdu x3000m3 --protocol snmp --secrets-file secrets.json`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) == 0 {
 			log.Error().Msg("no PDU hosts provided")
 			return
 		}
 
-		if username == "" || password == "" {
+		if strings.ToLower(pduProtocol) == "snmp" {
+			if secretsFile == "" {
+				log.Error().Msg("--secrets-file is required for SNMP PDU collection")
+				return
+			}
+		} else if username == "" || password == "" {
 			log.Error().Msg("--username and --password are required for PDU collection")
 			return
 		}
 
+		// the nodes powered by each outlet are optional
+		outletMap := pdu.OutletMap{}
+		if outletMapFile != "" {
+			m, err := pdu.LoadOutletMap(outletMapFile)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to load outlet map")
+				return
+			}
+			outletMap = m
+		}
+
 		allSmdRecords := make([]map[string]any, 0)
 
 		for _, host := range args {
-			log.Debug().Str("host", host).Msg("collecting from PDU")
-			config := jaws.CrawlerConfig{
-				URI:      host,
-				Username: username,
-				Password: password,
-				Insecure: true,
-			}
-
-			inventory, err := jaws.CrawlPDU(config)
+			inventories, err := crawlPDU(host)
 			if err != nil {
 				log.Error().Err(err).Str("host", host).Msgf("failed to crawl PDU")
 				continue
 			}
 
-			smdRecords := transformToSMDFormat(inventory)
-
-			allSmdRecords = append(allSmdRecords, smdRecords...)
+			for i := range inventories {
+				outletMap.Apply(&inventories[i])
+				smdRecords := transformToSMDFormat(&inventories[i])
+				allSmdRecords = append(allSmdRecords, smdRecords...)
+			}
 		}
 
 		output, err := json.MarshalIndent(allSmdRecords, "", "  ")
@@ -107,9 +150,68 @@ var pduCmd = &cobra.Command{
 	},
 }
 
+// crawlPDU collects the inventory of a PDU through Redfish, JAWS or SNMP,
+// detecting whether to use Redfish or JAWS unless set with --protocol.
+func crawlPDU(host string) ([]pdu.PDUInventory, error) {
+	uri := host
+	if !strings.Contains(uri, "://") {
+		uri = "https://" + host
+	}
+	protocol := strings.ToLower(pduProtocol)
+	if protocol == "auto" {
+		protocol = "jaws"
+		if crawler.IsRedfishPDU(uri, true, time.Duration(timeout)*time.Second) {
+			protocol = "redfish"
+		}
+	}
+
+	log.Debug().Str("host", host).Str("protocol", protocol).Msg("collecting from PDU")
+	switch protocol {
+	case "redfish":
+		return crawler.CrawlRackPDUs(crawler.CrawlerConfig{
+			URI:             uri,
+			Insecure:        true,
+			CredentialStore: secrets.NewStaticStore(username, password),
+		}, host)
+	case "snmp":
+		store, err := secrets.OpenStore(secretsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open secrets store: %w", err)
+		}
+		inventory, err := snmp.CrawlPDU(snmp.CrawlerConfig{
+			Host:            host,
+			CredentialStore: store,
+			Timeout:         time.Duration(timeout) * time.Second,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []pdu.PDUInventory{*inventory}, nil
+	case "jaws":
+		inventory, err := jaws.CrawlPDU(jaws.CrawlerConfig{
+			URI:      host,
+			Username: username,
+			Password: password,
+			Insecure: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []pdu.PDUInventory{*inventory}, nil
+	}
+	return nil, fmt.Errorf("invalid PDU protocol '%s' (must be one of auto, jaws, redfish or snmp)", pduProtocol)
+}
+
 func init() {
-	pduCmd.Flags().StringVarP(&username, "username", "u", "", "Set the PDU username")
-	pduCmd.Flags().StringVarP(&password, "password", "p", "", "Set the PDU password")
+	collectPDUCmd.Flags().StringVarP(&username, "username", "u", "", "Set the PDU username")
+	collectPDUCmd.Flags().StringVarP(&password, "password", "p", "", "Set the PDU password")
+	collectPDUCmd.Flags().StringVar(&pduProtocol, "protocol", "auto", "Set the protocol used to collect from the PDUs (auto|jaws|redfish|snmp)")
+	collectPDUCmd.Flags().StringVar(&outletMapFile, "outlet-map", "", "Set the path to a file mapping outlets to the nodes they power (see 'pdu map')")
+	collectPDUCmd.Flags().StringVar(&secretsFile, "secrets-file", "", "Set path to the secrets file with the SNMP credentials")
+
+	checkRegisterFlagCompletionError(collectPDUCmd.RegisterFlagCompletionFunc("protocol", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"auto", "jaws", "redfish", "snmp"}, cobra.ShellCompDirectiveNoFileComp
+	}))
 
-	CollectCmd.AddCommand(pduCmd)
+	CollectCmd.AddCommand(collectPDUCmd)
 }
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/internal/format"
+	"github.com/OpenCHAMI/magellan/pkg/jaws"
+	"github.com/OpenCHAMI/magellan/pkg/pdu"
+	"github.com/OpenCHAMI/magellan/pkg/power"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	outletMapFile       string
+	pduMapNode          string
+	pduMapDiscover      bool
+	pduMapDryRun        bool
+	pduMapSettleTime    time.Duration
+	pduMapInventoryFile string
+)
+
+var pduMapCmd = &cobra.Command{
+	Use: "map [pdu] [outlet...]",
+	Example: `  // show which outlets feed a node
+  magellan pdu map --map-file outlets.yaml --node x3000c0s3b0
+  // show the mapping of a PDU
+  magellan pdu map x3000m0 --map-file outlets.yaml
+  // show which outlets discovery would cycle, without switching any
+  magellan pdu map x3000m0 --discover -f collect.json -u admin -p initial0
+  // cycle two outlets, one at a time, and add what was found to the mapping file
+  magellan pdu map x3000m0 AA1 AA2 --discover --dry-run=false -f collect.json --map-file outlets.yaml -u admin -p initial0`,
+	Short: "Map PDU outlets to the nodes they power",
+	Long: "Show which nodes the outlets of PDUs power, or which outlets feed a node, from a mapping file. The mapping file\n" +
+		"is YAML (or JSON, by file extension) with the outlets of each PDU by hostname and outlet ID, e.g. 'x3000m0: {AA1: x3000c0s1b0}'.\n\n" +
+		"With --discover, the mapping is inferred instead by switching each outlet off in turn and probing the BMCs from a\n" +
+		"'collect' inventory; an outlet is mapped to the BMC that stops answering. Discovery is a dry run unless --dry-run=false\n" +
+		"is given, and then asks for confirmation unless --yes is given. Discovered mappings are added to the mapping file, if set.",
+	Run: func(cmd *cobra.Command, args []string) {
+		outletMap := pdu.OutletMap{}
+		if outletMapFile != "" {
+			if m, err := pdu.LoadOutletMap(outletMapFile); err == nil {
+				outletMap = m
+			} else if !pduMapDiscover || !errors.Is(err, os.ErrNotExist) {
+				log.Error().Err(err).Msg("failed to load outlet map")
+				os.Exit(1)
+			}
+		}
+
+		if pduMapDiscover {
+			if len(args) == 0 {
+				log.Error().Msg("--discover requires a PDU")
+				os.Exit(1)
+			}
+			discovered, err := discoverOutletMap(args[0], args[1:])
+			if err != nil {
+				log.Error().Err(err).Str("host", args[0]).Msg("failed to discover outlet map")
+				if discovered == nil {
+					os.Exit(1)
+				}
+			}
+			// keep the outlets mapped before a failure
+			outletMap.Merge(discovered)
+			if !pduMapDryRun && outletMapFile != "" {
+				if err := outletMap.Save(outletMapFile); err != nil {
+					log.Error().Err(err).Msg("failed to save outlet map")
+					os.Exit(1)
+				}
+			}
+			writeFormattedOutput(discovered, format.FORMAT_YAML)
+			if err != nil {
+				os.Exit(1)
+			}
+			return
+		}
+
+		if outletMapFile == "" {
+			log.Error().Msg("requires --map-file or --discover")
+			os.Exit(1)
+		}
+		if pduMapNode != "" {
+			refs := outletMap.OutletsOf(pduMapNode)
+			if len(refs) == 0 {
+				log.Error().Str("node", pduMapNode).Msg("no outlets found for node")
+				os.Exit(1)
+			}
+			writeFormattedOutput(refs, format.FORMAT_YAML)
+			return
+		}
+		if len(args) > 0 {
+			selected := pdu.OutletMap{}
+			for _, host := range args {
+				if outlets, ok := outletMap[host]; ok {
+					selected[host] = outlets
+				} else {
+					log.Warn().Str("host", host).Msg("no outlets mapped for PDU")
+				}
+			}
+			outletMap = selected
+		}
+		writeFormattedOutput(outletMap, format.FORMAT_YAML)
+	},
+}
+
+// discoverOutletMap cycles the given outlets of a JAWS PDU, or all of its
+// outlets if none are given, to find the BMCs from the inventory they power.
+func discoverOutletMap(host string, outlets []string) (pdu.OutletMap, error) {
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("--username and --password are required for PDU control")
+	}
+	if pduMapInventoryFile == "" {
+		return nil, fmt.Errorf("--discover requires the BMCs from a 'collect' inventory with --inventory-file")
+	}
+	nodes, err := power.ParseInventory(pduMapInventoryFile, format.DataFormatFromFileExt(pduMapInventoryFile, format.FORMAT_JSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse inventory file %s: %w", pduMapInventoryFile, err)
+	}
+	bmcs := map[string]string{}
+	for _, node := range nodes {
+		// the ID of a node is that of its BMC with the index of the system appended
+		if i := strings.LastIndex(node.ClusterID, "n"); i > 0 {
+			bmcs[node.ClusterID[:i]] = node.BmcIP
+		}
+	}
+
+	config := jaws.CrawlerConfig{
+		URI:      host,
+		Username: username,
+		Password: password,
+		Insecure: true,
+		Timeout:  time.Duration(timeout) * time.Second,
+	}
+	if len(outlets) == 0 {
+		inventory, err := jaws.CrawlPDU(config)
+		if err != nil {
+			return nil, err
+		}
+		for _, outlet := range inventory.Outlets {
+			outlets = append(outlets, outlet.ID)
+		}
+	}
+
+	if !pduMapDryRun && !pduAssumeYes {
+		if !confirm(fmt.Sprintf("Switch off outlets %s of PDU %s one at a time?", strings.Join(outlets, ", "), host)) {
+			return nil, fmt.Errorf("aborted")
+		}
+	}
+
+	return pdu.DiscoverOutletMap(pdu.DiscoveryConfig{
+		PDU:     host,
+		Outlets: outlets,
+		BMCs:    bmcs,
+		SwitchOutlet: func(outlet string, on bool) error {
+			action := jaws.OutletActionOff
+			if on {
+				action = jaws.OutletActionOn
+			}
+			if err := jaws.SetOutletState(config, outlet, action); err != nil {
+				return err
+			}
+			_, err := jaws.WaitForOutletState(config, outlet, action.ExpectedState(), pduWaitTimeout, pduWaitInterval)
+			return err
+		},
+		IsReachable: isBMCReachable,
+		SettleTime:  pduMapSettleTime,
+		DryRun:      pduMapDryRun,
+	})
+}
+
+// isBMCReachable checks whether a BMC accepts connections on its HTTPS (or
+// given) port.
+func isBMCReachable(address string) bool {
+	host := address
+	if u, err := url.Parse(address); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "443")
+	}
+	conn, err := net.DialTimeout("tcp", host, time.Duration(timeout)*time.Second)
+	if err != nil {
+		log.Debug().Err(err).Str("address", address).Msg("BMC is not reachable")
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func init() {
+	pduMapCmd.Flags().StringVarP(&username, "username", "u", "", "Set the PDU username")
+	pduMapCmd.Flags().StringVarP(&password, "password", "p", "", "Set the PDU password")
+	pduMapCmd.Flags().StringVar(&outletMapFile, "map-file", "", "Set the path to the outlet mapping file")
+	pduMapCmd.Flags().StringVar(&pduMapNode, "node", "", "Show the outlets that feed a node")
+	pduMapCmd.Flags().BoolVar(&pduMapDiscover, "discover", false, "Infer the mapping by switching outlets off one at a time and probing the BMCs")
+	pduMapCmd.Flags().BoolVar(&pduMapDryRun, "dry-run", true, "Only show which outlets discovery would switch off")
+	pduMapCmd.Flags().DurationVar(&pduMapSettleTime, "settle-time", 30*time.Second, "Set how long to wait after switching an outlet before probing the BMCs")
+	pduMapCmd.Flags().StringVarP(&pduMapInventoryFile, "inventory-file", "f", "", "File containing the BMCs from 'collect' to probe during discovery")
+	pduMapCmd.Flags().DurationVar(&pduWaitTimeout, "wait-timeout", time.Minute, "Set how long to wait for each outlet to reach the expected state")
+	pduMapCmd.Flags().DurationVar(&pduWaitInterval, "wait-interval", 2*time.Second, "Set how often to poll the outlet state while waiting")
+	pduMapCmd.Flags().BoolVarP(&pduAssumeYes, "yes", "y", false, "Run discovery without asking for confirmation")
+
+	PDUCmd.AddCommand(pduMapCmd)
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/pkg/jaws"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pduWait         bool
+	pduWaitTimeout  time.Duration
+	pduWaitInterval time.Duration
+	pduAssumeYes    bool
+)
+
+// The `pdu` command groups subcommands that act on PDUs directly, as opposed
+// to `collect pdu` which only reads their inventory.
+var PDUCmd = &cobra.Command{
+	Use:   "pdu",
+	Short: "Control PDU outlets and map them to the nodes they power",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cmd.Help(); err != nil {
+			log.Error().Err(err).Msg("failed to print help")
+		}
+	},
+}
+
+var pduPowerCmd = &cobra.Command{
+	Use: "power <pdu> <outlet>... [on|off|reboot]",
+	Example: `  // show the state of two outlets
+  magellan pdu power x3000m0 AA1 AA2 -u admin -p initial0
+  // power-cycle the outlet of a hung BMC and wait for it to come back on
+  magellan pdu power x3000m0 BA35 reboot --wait -u admin -p initial0
+  // switch off outlets without asking for confirmation
+  magellan pdu power x3000m0 AA1 AA2 off --yes -u admin -p initial0`,
+	Short: "Get and set the power state of PDU outlets",
+	Long: "Get the power state of PDU outlets, or switch them on, off or power-cycle them through the JAWS control API.\n" +
+		"Without an action, the current state of each outlet is printed. Switching outlets off or rebooting them\n" +
+		"asks for confirmation unless --yes is given.",
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if username == "" || password == "" {
+			log.Error().Msg("--username and --password are required for PDU control")
+			os.Exit(1)
+		}
+
+		// the action is optional, so only treat the last argument as one if it parses
+		var (
+			host    = args[0]
+			outlets = args[1:]
+			action  jaws.OutletAction
+		)
+		if a, err := jaws.ParseOutletAction(outlets[len(outlets)-1]); err == nil {
+			action = a
+			outlets = outlets[:len(outlets)-1]
+		}
+		if len(outlets) == 0 {
+			log.Error().Msg("no outlets provided")
+			os.Exit(1)
+		}
+
+		config := jaws.CrawlerConfig{
+			URI:      host,
+			Username: username,
+			Password: password,
+			Insecure: true,
+			Timeout:  time.Duration(timeout) * time.Second,
+		}
+
+		// cutting power to the wrong outlet takes down whatever is plugged into it
+		if (action == jaws.OutletActionOff || action == jaws.OutletActionReboot) && !pduAssumeYes {
+			verb := "Switch off"
+			if action == jaws.OutletActionReboot {
+				verb = "Reboot"
+			}
+			if !confirm(fmt.Sprintf("%s outlets %s of PDU %s?", verb, strings.Join(outlets, ", "), host)) {
+				log.Info().Msg("aborted")
+				os.Exit(1)
+			}
+		}
+
+		failed := false
+		for _, outlet := range outlets {
+			var result string
+			if action == "" {
+				o, err := jaws.GetOutlet(config, outlet)
+				if err != nil {
+					log.Error().Err(err).Str("host", host).Msgf("failed to get state of outlet %s", outlet)
+					failed = true
+					result = "unknown"
+				} else {
+					result = o.State
+				}
+			} else {
+				result = setOutletState(config, outlet, action)
+				failed = failed || !strings.HasPrefix(result, "success")
+			}
+			fmt.Printf("%s:\t%s\n", outlet, result)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// setOutletState performs the action on a single outlet and, with --wait,
+// waits until the outlet reports the state expected after the action.
+func setOutletState(config jaws.CrawlerConfig, outlet string, action jaws.OutletAction) string {
+	if err := jaws.SetOutletState(config, outlet, action); err != nil {
+		log.Error().Err(err).Str("host", config.URI).Msgf("failed to %s outlet %s", action, outlet)
+		return "failure"
+	}
+	if !pduWait {
+		return "success"
+	}
+	var (
+		expected = action.ExpectedState()
+		deadline = time.Now().Add(pduWaitTimeout)
+	)
+	if action == jaws.OutletActionReboot {
+		// a rebooting outlet still reports 'On' until the PDU has switched
+		// it off, so only count it as back on once it was seen off
+		if state, err := jaws.WaitForOutletState(config, outlet, jaws.OutletActionOff.ExpectedState(), pduWaitTimeout, pduWaitInterval); err != nil {
+			log.Error().Err(err).Str("host", config.URI).Msgf("outlet %s was not seen switching off for the reboot", outlet)
+			return fmt.Sprintf("timeout (last state: %s)", state)
+		}
+	}
+	state, err := jaws.WaitForOutletState(config, outlet, expected, time.Until(deadline), pduWaitInterval)
+	if err != nil {
+		log.Error().Err(err).Str("host", config.URI).Msgf("outlet %s did not reach state %s", outlet, expected)
+		return fmt.Sprintf("timeout (last state: %s)", state)
+	}
+	return fmt.Sprintf("success (%s)", state)
+}
+
+// confirm asks a yes/no question on standard error and reads the answer from
+// standard input. Anything but 'y' or 'yes' is taken as no.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	input := bufio.NewScanner(os.Stdin)
+	if !input.Scan() {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(input.Text()))
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	pduPowerCmd.Flags().StringVarP(&username, "username", "u", "", "Set the PDU username")
+	pduPowerCmd.Flags().StringVarP(&password, "password", "p", "", "Set the PDU password")
+	pduPowerCmd.Flags().BoolVarP(&pduWait, "wait", "w", false, "Wait until the outlets report the state expected after the action")
+	pduPowerCmd.Flags().DurationVar(&pduWaitTimeout, "wait-timeout", time.Minute, "Set how long to wait for each outlet to reach the expected state")
+	pduPowerCmd.Flags().DurationVar(&pduWaitInterval, "wait-interval", 2*time.Second, "Set how often to poll the outlet state while waiting")
+	pduPowerCmd.Flags().BoolVarP(&pduAssumeYes, "yes", "y", false, "Switch outlets off or reboot them without asking for confirmation")
+
+	PDUCmd.AddCommand(pduPowerCmd)
+	rootCmd.AddCommand(PDUCmd)
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/internal/format"
+	"github.com/OpenCHAMI/magellan/pkg/power"
+	"github.com/cznic/mathutil"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	powerCapInventoryFile  string
+	powerCapOutputFormat   format.DataFormat = format.FORMAT_YAML
+	powerCapWatts          float64
+	powerCapGroupWatts     float64
+	powerCapException      string
+	powerCapCorrectionTime time.Duration
+)
+
+// The `power cap` command groups subcommands that read and set the power
+// limits of nodes found by a previous inventory crawl.
+var powerCapCmd = &cobra.Command{
+	Use: "cap",
+	Example: `  // show the power limit and consumption of all nodes in the inventory
+  magellan power cap get -f nodes.json
+  // limit two nodes to 450 W each, powering them off if the limit can't be kept within 2 seconds
+  magellan power cap set -f nodes.json --watts 450 --limit-exception HardPowerOff --correction-time 2s x1000c0s0b3n0 x1000c0s0b3n1
+  // share a 3 kW budget evenly between a group of nodes
+  magellan power cap set -f nodes.json --group-watts 3000 --node-map rack1.yaml compute-001 compute-002 compute-003`,
+	Short: "Get and set node power limits",
+	Long: "Get and set the power limits of nodes found by a previous inventory crawl.\n" +
+		"Limits are read from and written to the Chassis EnvironmentMetrics PowerLimitWatts control where available,\n" +
+		"and to the legacy Chassis Power PowerControl PowerLimit otherwise.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cmd.Help(); err != nil {
+			log.Error().Err(err).Msg("failed to print help")
+		}
+	},
+}
+
+var powerCapGetCmd = &cobra.Command{
+	Use:   "get [node-id...]",
+	Short: "Show the power limit and current consumption of nodes (all nodes in the inventory if none are given)",
+	Run: func(cmd *cobra.Command, args []string) {
+		var (
+			targets = loadInventoryTargets(powerCapInventoryFile, args)
+			caps    []power.PowerCap
+			mu      sync.Mutex
+		)
+		if concurrency <= 0 {
+			concurrency = mathutil.Clamp(len(targets), 1, 10000)
+		}
+		concurrent_helper(concurrency, targets, func(target power.CrawlableNode) string {
+			powerCap, err := power.GetPowerCap(target)
+			if err != nil {
+				log.Error().Err(err).Msgf("failed to get power limit of node %s", target.ClusterID)
+				return "failure"
+			}
+			mu.Lock()
+			caps = append(caps, *powerCap)
+			mu.Unlock()
+			return "success"
+		})
+		power.LogoutBMCSessions()
+
+		sort.Slice(caps, func(i, j int) bool {
+			return caps[i].ClusterID < caps[j].ClusterID
+		})
+		writeFormattedOutput(caps, powerCapOutputFormat)
+	},
+}
+
+var powerCapSetCmd = &cobra.Command{
+	Use:   "set --watts <watts> <node-id>...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Set the power limit of nodes, individually or as a group budget",
+	Run: func(cmd *cobra.Command, args []string) {
+		var settings power.PowerCapSettings
+		if powerCapException != "" {
+			exception, err := power.ParsePowerLimitException(powerCapException)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to parse '--limit-exception'")
+				os.Exit(1)
+			}
+			settings.LimitException = exception
+		}
+		if cmd.Flags().Changed("correction-time") {
+			correction := int(powerCapCorrectionTime.Milliseconds())
+			settings.CorrectionInMs = &correction
+		}
+
+		targets := loadInventoryTargets(powerCapInventoryFile, args)
+		if len(targets) == 0 {
+			log.Error().Msg("no nodes to set the power limit of")
+			os.Exit(1)
+		}
+
+		// a group budget is shared evenly between the nodes
+		settings.LimitWatts = powerCapWatts
+		if powerCapGroupWatts > 0 {
+			settings.LimitWatts = float64(int(powerCapGroupWatts) / len(targets))
+			log.Info().Msgf("limiting each of %d nodes to %.0f W of the %.0f W group budget", len(targets), settings.LimitWatts, powerCapGroupWatts)
+		}
+		if settings.LimitWatts <= 0 {
+			log.Error().Msg("the power limit must be greater than 0 W")
+			os.Exit(1)
+		}
+
+		if concurrency <= 0 {
+			concurrency = mathutil.Clamp(len(targets), 1, 10000)
+		}
+		results := concurrent_helper(concurrency, targets, func(target power.CrawlableNode) string {
+			powerCap, err := power.SetPowerCap(target, settings)
+			if err != nil {
+				log.Error().Err(err).Msgf("failed to set power limit of node %s", target.ClusterID)
+				return "failure"
+			}
+			return fmt.Sprintf("success (limit: %s, consumed: %s)", formatWatts(powerCap.LimitWatts), formatWatts(powerCap.ConsumedWatts))
+		})
+		power.LogoutBMCSessions()
+
+		for _, node := range slices.Sorted(maps.Keys(results)) {
+			fmt.Printf("%s:\t%s\n", node, results[node])
+		}
+	},
+}
+
+// formatWatts formats an optional Redfish power reading.
+func formatWatts(watts *float64) string {
+	if watts == nil {
+		return "unknown"
+	}
+	return fmt.Sprintf("%.0f W", *watts)
+}
+
+func init() {
+	powerCapCmd.PersistentFlags().StringVarP(&powerCapInventoryFile, "inventory-file", "f", "", "File containing node inventory from 'collect' ('-' for stdin)")
+	powerCapCmd.PersistentFlags().StringVarP(&username, "username", "u", "", "Set the master BMC username")
+	powerCapCmd.PersistentFlags().StringVarP(&password, "password", "p", "", "Set the master BMC password")
+	powerCapCmd.PersistentFlags().StringVar(&secretsFile, "secrets-file", "", "Set path to the node secrets file")
+	powerCapCmd.PersistentFlags().BoolVarP(&insecure, "insecure", "i", false, "Ignore SSL errors")
+	powerCapCmd.PersistentFlags().StringVar(&node_key, "node-key", string(power.NodeKeyAuto), "Match node IDs against this property of the inventory (auto|xname|uuid|serial|uri)")
+	powerCapCmd.PersistentFlags().StringVar(&node_map_file, "node-map", "", "YAML file mapping node names to an xname, UUID, serial number or System URI")
+
+	powerCapGetCmd.Flags().VarP(&powerCapOutputFormat, "output-format", "F", "Set the output format (json|yaml)")
+	powerCapGetCmd.Flags().StringVarP(&outputPath, "output-file", "o", "", "Set the path to write the output (defaults to standard output)")
+
+	powerCapSetCmd.Flags().Float64Var(&powerCapWatts, "watts", 0, "Set the power limit of each node, in watts")
+	powerCapSetCmd.Flags().Float64Var(&powerCapGroupWatts, "group-watts", 0, "Set a power budget, in watts, shared evenly between all of the given nodes")
+	powerCapSetCmd.Flags().StringVar(&powerCapException, "limit-exception", "", "Set the action taken when the limit can't be kept (NoAction|HardPowerOff|LogEventOnly|Oem)")
+	powerCapSetCmd.Flags().DurationVar(&powerCapCorrectionTime, "correction-time", 0, "Set how long the limit may be exceeded before the limit exception is taken")
+	powerCapSetCmd.MarkFlagsMutuallyExclusive("watts", "group-watts")
+	powerCapSetCmd.MarkFlagsOneRequired("watts", "group-watts")
+
+	checkRegisterFlagCompletionError(powerCapCmd.RegisterFlagCompletionFunc("node-key", completionNodeKey))
+	checkRegisterFlagCompletionError(powerCapGetCmd.RegisterFlagCompletionFunc("output-format", completionFormatData))
+	checkRegisterFlagCompletionError(powerCapSetCmd.RegisterFlagCompletionFunc("limit-exception", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"NoAction", "HardPowerOff", "LogEventOnly", "Oem"}, cobra.ShellCompDirectiveNoFileComp
+	}))
+
+	powerCapCmd.AddCommand(powerCapGetCmd)
+	powerCapCmd.AddCommand(powerCapSetCmd)
+	PowerCmd.AddCommand(powerCapCmd)
+}
@@ -1,9 +1,12 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/OpenCHAMI/magellan/internal/format"
 	"github.com/OpenCHAMI/magellan/pkg/bmc"
@@ -22,6 +25,15 @@ import (
 var (
 	list_reset_types bool
 	reset_type       string
+	boot_once        string
+	boot_persistent  string
+	node_key         string
+	node_map_file    string
+	power_wait       bool
+	wait_timeout     time.Duration
+	wait_interval    time.Duration
+	stagger          time.Duration
+	group_size       int
 	powerFormat      format.DataFormat = format.FORMAT_JSON
 )
 
@@ -35,7 +47,16 @@ var PowerCmd = &cobra.Command{
   magellan power x1000c0s0b3n0 -r On
   magellan power x1000c0s0b3n0 -r PowerCycle
   // list supported reset types
-  magellan power x1000c0s0b3n0 -l
+  magellan power x1000c0s0b3n0 --list-reset-types
+  // network boot once, then power cycle
+  magellan power x1000c0s0b3n0 --boot-once Pxe -r ForceRestart
+  // always boot from disk
+  magellan power x1000c0s0b3n0 --boot-persistent Hdd
+  // power on in waves of 8 nodes, 30 seconds apart, waiting for each node to be on
+  magellan power -r On --wait --group-size 8 --stagger 30s x1000c0s0b3n0 x1000c0s0b3n1 ...
+  // select nodes by serial number, or by names from a mapping file
+  magellan power --node-key serial -f collect.json CN7475166M0123
+  magellan power --node-map nodes.yaml -f collect.json compute-001
   // more realistic usage
   magellan power -u USER -p PASS -f collect.json x1000c0s0b3n0 x1000c0s0b3n1 x1000c0s0b3n2
   // inventory from stdin
@@ -64,83 +85,44 @@ var PowerCmd = &cobra.Command{
 		}
 
 		// Use secret store for BMC credentials, and/or credential CLI flags
-		var store secrets.SecretStore
-		if username != "" && password != "" {
-			// First, try and load credentials from --username and --password if both are set.
-			log.Debug().Msgf("--username and --password specified, using them for BMC credentials")
-			store = secrets.NewStaticStore(username, password)
-		} else {
-			// Alternatively, locate specific credentials (falling back to default) and override those
-			// with --username or --password if either are passed.
-			log.Debug().Msgf("one or both of --username and --password NOT passed, attempting to obtain missing credentials from secret store at %s", secretsFile)
-			if store, err = secrets.OpenStore(secretsFile); err != nil {
-				log.Error().Err(err).Msg("failed to open local secrets store")
-			}
+		store := loadBMCSecretStore()
 
-			// Temporarily override username/password of each BMC if one of those
-			// flags is passed. The expectation is that if the flag is specified
-			// on the command line, it should be used.
-			if username != "" {
-				log.Info().Msg("--username passed, temporarily overriding all usernames from secret store with value")
-			}
-			if password != "" {
-				log.Info().Msg("--password passed, temporarily overriding all passwords from secret store with value")
-			}
-			switch s := store.(type) {
-			case *secrets.StaticStore:
-				if username != "" {
-					s.Username = username
-				}
-				if password != "" {
-					s.Password = password
-				}
-			case *secrets.LocalSecretStore:
-				for k := range s.Secrets {
-					if creds, err := bmc.GetBMCCredentials(store, k); err != nil {
-						log.Error().Str("id", k).Err(err).Msg("failed to override BMC credentials")
-					} else {
-						if username != "" {
-							creds.Username = username
-						}
-						if password != "" {
-							creds.Password = password
-						}
+		// Select the nodes requested by the user
+		target_nodes, unresolved := selectTargetNodes(nodes, args, store)
 
-						if newCreds, err := json.Marshal(creds); err != nil {
-							log.Error().Str("id", k).Err(err).Msg("failed to override BMC credentials: marshal error")
-						} else {
-							err = s.StoreSecretByID(k, string(newCreds))
-							if err != nil {
-								log.Error().Err(err).Str("id", k).Msg("failed to store secret by ID")
-							}
-						}
-					}
-				}
+		// Parse the boot source override, if any
+		var (
+			boot_override schemas.BootSource
+			boot_enabled  schemas.BootSourceOverrideEnabled
+		)
+		if boot_once != "" || boot_persistent != "" {
+			boot_enabled = schemas.OnceBootSourceOverrideEnabled
+			source := boot_once
+			if boot_persistent != "" {
+				boot_enabled = schemas.ContinuousBootSourceOverrideEnabled
+				source = boot_persistent
+			}
+			if boot_override, err = power.ParseBootSource(source); err != nil {
+				log.Fatal().Err(err).Msg("failed to parse boot source override")
 			}
 		}
 
-		// Index nodes by xname, for faster lookup...
-		nodemap := make(map[string]bmc.Node, len(nodes))
-		for i := range nodes {
-			nodemap[nodes[i].ClusterID] = nodes[i]
+		// Determine the power state to wait for after a reset
+		var (
+			expected_state schemas.PowerState
+			reached        = make(map[string]bool, len(target_nodes))
+			reached_mu     sync.Mutex
+		)
+		if power_wait && reset_type == "" {
+			log.Warn().Msg("--wait requires --reset-type; not waiting")
+			power_wait = false
 		}
-		// ...and select the ones requested by the user
-		target_nodes := make([]power.CrawlableNode, 0, len(args))
-		for i := range args {
-			node, found := nodemap[args[i]]
-			if !found {
-				log.Error().Msgf("target node '%s' not found in inventory; skipping", args[i])
-				continue
+		if power_wait {
+			var known bool
+			if expected_state, known = power.ExpectedPowerState(schemas.ResetType(reset_type)); !known {
+				// e.g. a warm restart may never be seen in the power state
+				log.Fatal().Msgf("--wait is not supported for reset type '%s', since it has no expected power state", reset_type)
 			}
-			target_nodes = append(target_nodes, power.CrawlableNode{
-				ClusterID: node.ClusterID,
-				NodeID:    node.NodeID,
-				ConnConfig: crawler.CrawlerConfig{
-					URI:             "https://" + node.BmcIP,
-					CredentialStore: store,
-					Insecure:        insecure,
-				},
-			})
 		}
 
 		// Create the appropriate "action function" based on CLI flags (or lack thereof)
@@ -156,6 +138,13 @@ var PowerCmd = &cobra.Command{
 			}
 		} else if reset_type != "" {
 			action_func = func(target power.CrawlableNode) string {
+				// Set the boot source override first, so it applies to this reset
+				if boot_override != "" {
+					if err := power.SetBootOverride(target, boot_override, boot_enabled); err != nil {
+						log.Error().Err(err).Msgf("failed to set boot source override of node %s; not resetting", target.ClusterID)
+						return "failure"
+					}
+				}
 				// TODO: Some kind of validation might be nice here, but ResetType
 				// is a custom string type, so a direct typecast works fine for now.
 				// TODO: Update this function here to report the TaskMonitorInfo
@@ -164,6 +153,32 @@ var PowerCmd = &cobra.Command{
 					log.Error().Err(err).Msgf("failed to reset node %s", target.ClusterID)
 					return "failure"
 				}
+				if !power_wait {
+					return "success"
+				}
+				// Wait until the node actually reaches the expected state
+				var state schemas.PowerState
+				if power.CyclesPower(schemas.ResetType(reset_type)) {
+					// the node is still On when the reset is accepted
+					state, err = power.WaitForPowerCycle(target, wait_timeout, wait_interval)
+				} else {
+					state, err = power.WaitForPowerState(target, expected_state, wait_timeout, wait_interval)
+				}
+				if err != nil {
+					log.Error().Err(err).Msgf("node %s did not reach power state %s", target.ClusterID, expected_state)
+					return fmt.Sprintf("timeout (last state: %s)", state)
+				}
+				reached_mu.Lock()
+				reached[target.ClusterID] = true
+				reached_mu.Unlock()
+				return fmt.Sprintf("success (%s)", state)
+			}
+		} else if boot_override != "" {
+			action_func = func(target power.CrawlableNode) string {
+				if err := power.SetBootOverride(target, boot_override, boot_enabled); err != nil {
+					log.Error().Err(err).Msgf("failed to set boot source override of node %s", target.ClusterID)
+					return "failure"
+				}
 				return "success"
 			}
 		} else {
@@ -177,15 +192,117 @@ var PowerCmd = &cobra.Command{
 			}
 		}
 
-		// Actual node operations, in parallel
-		results := concurrent_helper(concurrency, target_nodes, action_func)
+		// Actual node operations, in parallel, and in waves if requested
+		if stagger > 0 && group_size <= 0 {
+			group_size = 1
+		}
+		if group_size <= 0 {
+			group_size = len(target_nodes)
+		}
+		results := make(map[string]string, len(target_nodes))
+		for start := 0; start < len(target_nodes); start += group_size {
+			if start > 0 && stagger > 0 {
+				log.Info().Msgf("waiting %s before the next group of nodes", stagger)
+				time.Sleep(stagger)
+			}
+			end := min(start+group_size, len(target_nodes))
+			log.Debug().Msgf("operating on nodes %d-%d of %d", start+1, end, len(target_nodes))
+			maps.Copy(results, concurrent_helper(concurrency, target_nodes[start:end], action_func))
+		}
 		power.LogoutBMCSessions()
-		for node, status := range results {
-			fmt.Printf("%s:\t%s\n", node, status)
+		for node := range unresolved {
+			results[node] = "unresolved"
+		}
+
+		node_ids := slices.Sorted(maps.Keys(results))
+		for _, node := range node_ids {
+			fmt.Printf("%s:\t%s\n", node, results[node])
+		}
+
+		// Summarize which nodes reached the expected state
+		if power_wait {
+			var not_reached []string
+			for _, node := range node_ids {
+				if !reached[node] {
+					not_reached = append(not_reached, node)
+				}
+			}
+			fmt.Printf("\n%d/%d nodes reached power state %s\n", len(node_ids)-len(not_reached), len(node_ids), expected_state)
+			if len(not_reached) > 0 {
+				fmt.Printf("not reached: %s\n", strings.Join(not_reached, " "))
+			}
 		}
 	},
 }
 
+// selectTargetNodes resolves each of the requested node IDs in the parsed
+// inventory (using the --node-key and --node-map flags) and pairs it with the
+// connection details of its BMC. Nodes that can't be resolved are logged and
+// returned separately along with the reason.
+func selectTargetNodes(nodes []bmc.Node, ids []string, store secrets.SecretStore) ([]power.CrawlableNode, map[string]error) {
+	key, err := power.ParseNodeKey(node_key)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to parse node key")
+	}
+	var node_map map[string]string
+	if node_map_file != "" {
+		if node_map, err = power.LoadNodeMap(node_map_file); err != nil {
+			log.Fatal().Err(err).Str("path", node_map_file).Msg("failed to load node mapping file")
+		}
+	}
+
+	resolved, unresolved := power.ResolveNodes(nodes, ids, key, node_map)
+	for id, err := range unresolved {
+		log.Error().Err(err).Str("node", id).Msg("failed to resolve target node; skipping")
+	}
+
+	target_nodes := make([]power.CrawlableNode, 0, len(resolved))
+	for _, r := range resolved {
+		log.Debug().Msgf("resolved node '%s' to system '%s' on %s", r.ID, r.Node.NodeID, r.Node.BmcIP)
+		target_nodes = append(target_nodes, power.CrawlableNode{
+			ClusterID: r.ID,
+			NodeID:    r.Node.NodeID,
+			ConnConfig: crawler.CrawlerConfig{
+				URI:             "https://" + r.Node.BmcIP,
+				CredentialStore: store,
+				Insecure:        insecure,
+			},
+		})
+	}
+	return target_nodes, unresolved
+}
+
+// loadInventoryTargets reads the node inventory and selects the requested
+// nodes, or every node in the inventory if none were requested.
+func loadInventoryTargets(datafile string, ids []string) []power.CrawlableNode {
+	// Read node inventory from CLI flag, or default `collect` output
+	if datafile == "" {
+		datafile = viper.GetString("collect.output-file")
+		log.Info().Msgf("parsing default inventory file from 'collect': %s", datafile)
+	}
+	nodes, err := power.ParseInventory(datafile, format.DataFormatFromFileExt(datafile, format.FORMAT_JSON))
+	if err != nil {
+		log.Fatal().Err(err).Msgf("failed to parse inventory file %s", datafile)
+	}
+	if len(ids) == 0 {
+		for _, node := range nodes {
+			ids = append(ids, node.ClusterID)
+		}
+	}
+	targets, _ := selectTargetNodes(nodes, ids, loadBMCSecretStore())
+	return targets
+}
+
+// completionNodeKey is the cobra completion function for the --node-key flag.
+func completionNodeKey(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"auto", "xname", "uuid", "serial", "uri"}, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionBootSource is the cobra completion function for the boot source override flags.
+func completionBootSource(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"Pxe", "Hdd", "Cd", "Usb", "BiosSetup", "UefiHttp", "UefiShell"}, cobra.ShellCompDirectiveNoFileComp
+}
+
 func concurrent_helper(concurrency int, targets []power.CrawlableNode, runner func(power.CrawlableNode) string) map[string]string {
 	type NodeInfo struct {
 		ClusterID string
@@ -240,11 +357,21 @@ func concurrent_helper(concurrency int, targets []power.CrawlableNode, runner fu
 
 func init() {
 	// Alternative actions from the default power-state query
-	PowerCmd.Flags().BoolVarP(&list_reset_types, "list-reset-types", "l", false, "List supported Redfish reset types")
+	PowerCmd.Flags().BoolVar(&list_reset_types, "list-reset-types", false, "List supported Redfish reset types")
 	PowerCmd.Flags().StringVarP(&reset_type, "reset-type", "r", "", "Redfish reset type to perform")
+	PowerCmd.Flags().StringVar(&boot_once, "boot-once", "", "Boot from this source on the next boot only (Pxe|Hdd|Cd|BiosSetup|UefiHttp|...), set before any reset")
+	PowerCmd.Flags().StringVar(&boot_persistent, "boot-persistent", "", "Boot from this source on every boot (Pxe|Hdd|Cd|BiosSetup|UefiHttp|...), set before any reset")
+	PowerCmd.Flags().BoolVarP(&power_wait, "wait", "w", false, "Wait until nodes reach the power state expected after the reset (not for ForceRestart or GracefulRestart)")
+	PowerCmd.Flags().DurationVar(&wait_timeout, "wait-timeout", 5*time.Minute, "Set how long to wait for each node to reach the expected power state")
+	PowerCmd.Flags().DurationVar(&wait_interval, "wait-interval", 5*time.Second, "Set how often to poll the power state while waiting")
+	PowerCmd.Flags().DurationVar(&stagger, "stagger", 0, "Set the delay between groups of nodes (implies a group size of 1 unless --group-size is set)")
+	PowerCmd.Flags().IntVar(&group_size, "group-size", 0, "Operate on this many nodes at a time, finishing each group before starting the next")
 	PowerCmd.MarkFlagsMutuallyExclusive("reset-type", "list-reset-types")
+	PowerCmd.MarkFlagsMutuallyExclusive("boot-once", "boot-persistent", "list-reset-types")
 
 	// Normal config options
+	PowerCmd.Flags().StringVar(&node_key, "node-key", string(power.NodeKeyAuto), "Match node IDs against this property of the inventory (auto|xname|uuid|serial|uri)")
+	PowerCmd.Flags().StringVar(&node_map_file, "node-map", "", "YAML file mapping node names to an xname, UUID, serial number or System URI")
 	PowerCmd.Flags().StringP("inventory-file", "f", "", "YAML file containing node inventory")
 	PowerCmd.Flags().StringVarP(&username, "username", "u", "", "Set the master BMC username")
 	PowerCmd.Flags().StringVarP(&password, "password", "p", "", "Set the master BMC password")
@@ -254,6 +381,9 @@ func init() {
 	PowerCmd.Flags().VarP(&powerFormat, "output-format", "F", "Set the output format (json|yaml)")
 
 	checkRegisterFlagCompletionError(PowerCmd.RegisterFlagCompletionFunc("output-format", completionFormatData))
+	checkRegisterFlagCompletionError(PowerCmd.RegisterFlagCompletionFunc("node-key", completionNodeKey))
+	checkRegisterFlagCompletionError(PowerCmd.RegisterFlagCompletionFunc("boot-once", completionBootSource))
+	checkRegisterFlagCompletionError(PowerCmd.RegisterFlagCompletionFunc("boot-persistent", completionBootSource))
 
 	// Bind flags to config properties
 	checkBindFlagError(viper.BindPFlag("power.cacert", PowerCmd.Flags().Lookup("cacert")))
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strings"
 
 	"github.com/OpenCHAMI/magellan/internal/format"
 	logger "github.com/OpenCHAMI/magellan/internal/log"
@@ -85,6 +86,8 @@ func init() {
 	cobra.OnInitialize(
 		InitializeLogger,
 		InitializeConfig,
+		initializeVaultConfig,
+		initializeMasterKeySource,
 	)
 	rootCmd.PersistentFlags().IntVarP(&concurrency, "concurrency", "j", -1, "Set the number of concurrent processes")
 	rootCmd.PersistentFlags().IntVarP(&timeout, "timeout", "t", 5, "Set the timeout for requests in seconds")
@@ -93,6 +96,8 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cachePath, "cache", fmt.Sprintf("/tmp/%s/magellan/assets.db", util.GetCurrentUsername()), "Set the scanning result cache path")
 	rootCmd.PersistentFlags().VarP(&logLevel, "log-level", "l", "Set the logger log-level (debug|info|warn|error|trace|disabled)")
 	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Set the path to store a log file")
+	rootCmd.PersistentFlags().String("master-key-file", "", "Read the master key of the secrets file from a file instead of MASTER_KEY")
+	rootCmd.PersistentFlags().String("master-key-command", "", "Read the master key of the secrets file from the output of a shell command instead of MASTER_KEY")
 
 	// bind viper config flags with cobra
 	checkBindFlagError(viper.BindPFlag("concurrency", rootCmd.PersistentFlags().Lookup("concurrency")))
@@ -100,6 +105,8 @@ func init() {
 	checkBindFlagError(viper.BindPFlag("log-level", rootCmd.PersistentFlags().Lookup("log-level")))
 	checkBindFlagError(viper.BindPFlag("access-token", rootCmd.PersistentFlags().Lookup("access-token")))
 	checkBindFlagError(viper.BindPFlag("cache", rootCmd.PersistentFlags().Lookup("cache")))
+	checkBindFlagError(viper.BindPFlag("secrets.master-key-file", rootCmd.PersistentFlags().Lookup("master-key-file")))
+	checkBindFlagError(viper.BindPFlag("secrets.master-key-command", rootCmd.PersistentFlags().Lookup("master-key-command")))
 
 }
 
@@ -115,6 +122,12 @@ func checkRegisterFlagCompletionError(err error) {
 	}
 }
 
+func checkMarkFlagRequiredError(err error) {
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to mark flag as required")
+	}
+}
+
 func helpMapToSlice(help map[string]string) []string {
 	var helpSlice []string
 	for k, v := range help {
@@ -129,6 +142,24 @@ func completionFormatData(cmd *cobra.Command, args []string, toComplete string)
 	return helpMapToSlice(format.DataFormatHelpMap), cobra.ShellCompDirectiveDefault
 }
 
+// writeFormattedOutput marshals data in the selected format and writes it to the
+// output file or standard output.
+func writeFormattedOutput(data any, dataFormat format.DataFormat) {
+	output, err := format.MarshalData(data, dataFormat)
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to marshal output to %s", strings.ToUpper(dataFormat.String()))
+		os.Exit(1)
+	}
+	if outputPath == "" {
+		fmt.Println(string(output))
+		return
+	}
+	if err = os.WriteFile(outputPath, output, 0o644); err != nil {
+		log.Error().Err(err).Str("path", outputPath).Msg("failed to write output")
+		os.Exit(1)
+	}
+}
+
 // InitializeConfig() initializes a new config object by loading it
 // from a file given a non-empty string.
 func InitializeConfig() {
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/OpenCHAMI/magellan/internal/format"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat    string
+	exportBundleKey secrets.MasterKeySource
+	exportPlaintext bool
+	exportAssumeYes bool
+)
+
+// The `secrets export` command writes every secret of the secrets store to
+// an encrypted bundle that `secrets import` reads, e.g. to migrate secrets
+// to another store, or to a plaintext file of records.
+var secretsExportCmd = &cobra.Command{
+	Use: "export path",
+	Example: `  // export secrets to a bundle encrypted with its own key
+  export BUNDLE_KEY=$(magellan secrets generatekey)
+  magellan secrets export secrets.bundle --bundle-key $BUNDLE_KEY
+
+  // migrate secrets from Vault to a local file
+  magellan secrets export -f vault://secret/magellan/bmcs secrets.bundle --bundle-key $BUNDLE_KEY
+  magellan secrets import -f secrets.json secrets.bundle --bundle-key $BUNDLE_KEY
+
+  // print secrets in plaintext as CSV
+  magellan secrets export - --plaintext -F csv`,
+	Short: "Export secrets to an encrypted bundle or a plaintext file",
+	Long: "Export every secret of the secrets store to an encrypted bundle, if a bundle key is set, or to a plaintext file of records\n" +
+		"with '--plaintext' after confirming it. A bundle is a secrets file encrypted with the bundle key instead of the master key.\n" +
+		"Plaintext files are written in the same formats 'secrets import' reads. Existing files are never overwritten.\n\n" +
+		"Pass '-' as path to write plaintext records to standard output.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := args[0]
+		switch {
+		case isBundleKeySet(exportBundleKey) && exportPlaintext:
+			log.Error().Msg("cannot use '--plaintext' with a bundle key")
+			os.Exit(1)
+		case !isBundleKeySet(exportBundleKey) && !exportPlaintext:
+			log.Error().Msg("set a bundle key to export an encrypted bundle, or '--plaintext' to export secrets unencrypted")
+			os.Exit(1)
+		}
+
+		store, err := secrets.OpenStore(secretsFile)
+		if err != nil {
+			log.Error().Err(err).Str("path", secretsFile).Msg("failed to open secrets store")
+			os.Exit(1)
+		}
+		exported, err := secrets.ReadAllSecrets(store)
+		if err != nil {
+			log.Error().Err(err).Str("path", secretsFile).Msg("failed to read secrets")
+			os.Exit(1)
+		}
+
+		if !exportPlaintext {
+			bundleKey, err := exportBundleKey.Load()
+			if err != nil {
+				log.Error().Err(err).Msg("failed to get bundle key")
+				os.Exit(1)
+			}
+			if err = secrets.WriteBundle(path, bundleKey, exported); err != nil {
+				log.Error().Err(err).Str("path", path).Msg("failed to write secrets bundle")
+				os.Exit(1)
+			}
+			log.Info().Str("path", path).Int("secrets", len(exported)).Msg("exported secrets to encrypted bundle")
+			return
+		}
+
+		outFormat, err := secretsFileFormat(path, exportFormat)
+		if err != nil {
+			log.Error().Err(err).Msg("invalid format (see --format flag for options)")
+			os.Exit(1)
+		}
+		output, err := secrets.WriteRecords(secrets.SecretsToRecords(exported), outFormat)
+		if err != nil {
+			log.Error().Err(err).Msgf("failed to marshal secrets to %s", outFormat)
+			os.Exit(1)
+		}
+		if !exportAssumeYes && !confirm(fmt.Sprintf("Write %d secret(s) in plaintext to %s?", len(exported), path)) {
+			log.Info().Msg("aborted")
+			return
+		}
+		if path == "-" {
+			fmt.Print(string(output))
+			return
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_, err = file.Write(output)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			log.Error().Err(err).Str("path", path).Msg("failed to write secrets")
+			os.Exit(1)
+		}
+		log.Warn().Str("path", path).Int("secrets", len(exported)).Msg("exported secrets in plaintext, delete the file once it is no longer needed")
+	},
+}
+
+// addBundleKeyFlags adds the flags that set the key of an encrypted bundle,
+// in the same ways as the master key can be set.
+func addBundleKeyFlags(cmd *cobra.Command, source *secrets.MasterKeySource, usage string) {
+	cmd.Flags().StringVar(&source.Key, "bundle-key", "", usage+" this key in hex")
+	cmd.Flags().StringVar(&source.File, "bundle-key-file", "", usage+" the key in a file")
+	cmd.Flags().StringVar(&source.Command, "bundle-key-command", "", usage+" the key printed by a shell command")
+	cmd.MarkFlagsMutuallyExclusive("bundle-key", "bundle-key-file", "bundle-key-command")
+}
+
+// isBundleKeySet reports whether any of the bundle key flags was set.
+func isBundleKeySet(source secrets.MasterKeySource) bool {
+	return source != (secrets.MasterKeySource{})
+}
+
+// completionFormatSecrets is the cobra completion function for the format
+// flags of 'secrets import' and 'secrets export'.
+func completionFormatSecrets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{
+		string(secrets.FORMAT_CSV) + "\tCSV format (with a header row)",
+		string(format.FORMAT_JSON) + "\tJSON format",
+		string(format.FORMAT_YAML) + "\tYAML format",
+		string(format.FORMAT_NDJSON) + "\tNewline-delimited JSON format (one object per line)",
+	}, cobra.ShellCompDirectiveDefault
+}
+
+func init() {
+	secretsExportCmd.Flags().StringVarP(&exportFormat, "format", "F", "", "Set the format of plaintext secrets (csv|json|yaml|ndjson; defaults to the file extension)")
+	secretsExportCmd.Flags().BoolVar(&exportPlaintext, "plaintext", false, "Export secrets unencrypted")
+	secretsExportCmd.Flags().BoolVarP(&exportAssumeYes, "yes", "y", false, "Export secrets in plaintext without asking for confirmation")
+	addBundleKeyFlags(secretsExportCmd, &exportBundleKey, "Encrypt the bundle with")
+
+	checkRegisterFlagCompletionError(secretsExportCmd.RegisterFlagCompletionFunc("format", completionFormatSecrets))
+
+	secretsCmd.AddCommand(secretsExportCmd)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/OpenCHAMI/magellan/internal/format"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importFormat    string
+	importBundleKey secrets.MasterKeySource
+	importOverwrite bool
+)
+
+// The `secrets import` command stores many secrets at once, read from a
+// CSV, JSON or YAML file of IDs, usernames and passwords, or from an
+// encrypted bundle written by `secrets export`.
+var secretsImportCmd = &cobra.Command{
+	Use: "import path",
+	Example: `  // store the credentials of every BMC of a new cluster
+  cat bmcs.csv
+  id,username,password
+  https://172.16.0.101,root,hunter2
+  172.16.1.0/24,admin,rack-password
+  default,ADMIN,ADMIN
+  default,root,calvin
+  magellan secrets import bmcs.csv
+
+  // migrate secrets from a local file to Vault
+  export BUNDLE_KEY=$(magellan secrets generatekey)
+  magellan secrets export -f secrets.json secrets.bundle --bundle-key $BUNDLE_KEY
+  magellan secrets import -f vault://secret/magellan/bmcs secrets.bundle --bundle-key $BUNDLE_KEY`,
+	Short: "Store secrets from a CSV, JSON or YAML file or an encrypted bundle",
+	Long: "Store the secrets of a file in the secrets store. The file is either a list of records with an 'id', 'username' and 'password'\n" +
+		"in CSV (with a header row), JSON, YAML or NDJSON, or an encrypted bundle written by 'secrets export' if a bundle key is set.\n" +
+		"Several records with the same ID are stored as candidate credentials to try in order, and records with a 'secret' instead\n" +
+		"of a username and password are stored as is. Secrets that already exist are skipped unless '--overwrite' is set.\n\n" +
+		"Pass '-' as path to read records from standard input.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var (
+			path        = args[0]
+			importedMap map[string]string
+		)
+		if isBundleKeySet(importBundleKey) {
+			bundleKey, err := importBundleKey.Load()
+			if err != nil {
+				log.Error().Err(err).Msg("failed to get bundle key")
+				os.Exit(1)
+			}
+			if importedMap, err = secrets.ReadBundle(path, bundleKey); err != nil {
+				log.Error().Err(err).Str("path", path).Msg("failed to read secrets bundle")
+				os.Exit(1)
+			}
+		} else {
+			inFormat, err := secretsFileFormat(path, importFormat)
+			if err != nil {
+				log.Error().Err(err).Msg("invalid format (see --format flag for options)")
+				os.Exit(1)
+			}
+			var input []byte
+			if path == "-" {
+				input, err = io.ReadAll(os.Stdin)
+			} else {
+				input, err = os.ReadFile(path)
+			}
+			if err != nil {
+				log.Error().Err(err).Str("path", path).Msg("failed to read secrets to import")
+				os.Exit(1)
+			}
+			records, err := secrets.ReadRecords(input, inFormat)
+			if err == nil {
+				importedMap, err = secrets.RecordsToSecrets(records)
+			}
+			if err != nil {
+				log.Error().Err(err).Str("path", path).Msg("failed to parse secrets to import")
+				os.Exit(1)
+			}
+		}
+
+		store, err := secrets.OpenStore(secretsFile)
+		if err != nil {
+			log.Error().Err(err).Str("path", secretsFile).Msg("failed to open secrets store")
+			os.Exit(1)
+		}
+		if _, ok := store.(*secrets.StaticStore); ok {
+			log.Error().Msg("importing secrets requires a secrets store to save them in")
+			os.Exit(1)
+		}
+		stored, skipped, err := secrets.StoreSecrets(store, importedMap, importOverwrite)
+		if len(skipped) > 0 {
+			log.Warn().Strs("ids", skipped).Msg("skipped secrets that already exist, pass '--overwrite' to replace them")
+		}
+		if err != nil {
+			log.Error().Err(err).Str("path", secretsFile).Int("stored", len(stored)).Msg("failed to import secrets")
+			os.Exit(1)
+		}
+		log.Info().Str("path", secretsFile).Int("stored", len(stored)).Int("skipped", len(skipped)).Msg("imported secrets")
+	},
+}
+
+// secretsFileFormat returns the format of a file of secret records, from
+// the '--format' flag if set or else from its extension, defaulting to JSON.
+func secretsFileFormat(path string, flag string) (format.DataFormat, error) {
+	if flag == "" {
+		return secrets.FormatFromFileExt(path, format.FORMAT_JSON), nil
+	}
+	switch f := format.DataFormat(flag); f {
+	case secrets.FORMAT_CSV, format.FORMAT_JSON, format.FORMAT_YAML, format.FORMAT_NDJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown secrets format '%s'", flag)
+}
+
+func init() {
+	secretsImportCmd.Flags().StringVarP(&importFormat, "format", "F", "", "Set the format of the secrets to import (csv|json|yaml|ndjson; defaults to the file extension)")
+	secretsImportCmd.Flags().BoolVar(&importOverwrite, "overwrite", false, "Replace secrets that already exist in the secrets store")
+	addBundleKeyFlags(secretsImportCmd, &importBundleKey, "Read an encrypted bundle with")
+
+	checkRegisterFlagCompletionError(secretsImportCmd.RegisterFlagCompletionFunc("format", completionFormatSecrets))
+
+	secretsCmd.AddCommand(secretsImportCmd)
+}
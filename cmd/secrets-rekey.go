@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rekeyNewKey        string
+	rekeyNewKeyFile    string
+	rekeyNewKeyCommand string
+	rekeyBackupPath    string
+)
+
+// The `secrets rekey` command changes the master key of a local secrets file
+// by decrypting and encrypting again every secret in it.
+var secretsRekeyCmd = &cobra.Command{
+	Use: "rekey",
+	Example: `  // re-encrypt the secrets file with a new key
+  export NEW_MASTER_KEY=$(magellan secrets generatekey)
+  magellan secrets rekey -f secrets.json --new-key $NEW_MASTER_KEY
+  export MASTER_KEY=$NEW_MASTER_KEY
+
+  // read the current and new keys from a password manager
+  magellan secrets rekey --master-key-command 'pass show magellan/old' --new-key-command 'pass show magellan/new'`,
+	Short: "Re-encrypt the secrets file with a new master key",
+	Long: "Decrypt every secret of a local secrets file with the current master key and encrypt it again with a new one.\n" +
+		"The secrets file is copied to a backup first, and then replaced at once, so that it is never left half re-encrypted.\n" +
+		"Nothing is changed if any secret can't be decrypted with the current master key.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		newKey, err := secrets.MasterKeySource{
+			Key:     rekeyNewKey,
+			Command: rekeyNewKeyCommand,
+			File:    rekeyNewKeyFile,
+		}.Load()
+		if err != nil {
+			log.Error().Err(err).Msg("failed to get new master key, set one of '--new-key', '--new-key-file' or '--new-key-command'")
+			os.Exit(1)
+		}
+
+		store, err := secrets.OpenStore(secretsFile)
+		if err != nil {
+			log.Error().Err(err).Str("path", secretsFile).Msg("failed to open secrets store")
+			os.Exit(1)
+		}
+		localStore, ok := store.(*secrets.LocalSecretStore)
+		if !ok {
+			log.Error().Str("path", secretsFile).Msg("only local secrets files can be rekeyed")
+			os.Exit(1)
+		}
+
+		backupPath := rekeyBackupPath
+		if backupPath == "" {
+			backupPath = fmt.Sprintf("%s.%s.bak", secretsFile, time.Now().UTC().Format("20060102T150405Z"))
+		}
+		if err = localStore.Rekey(newKey, backupPath); err != nil {
+			log.Error().Err(err).Str("path", secretsFile).Msg("failed to rekey secrets file, it was left unchanged")
+			os.Exit(1)
+		}
+		log.Info().Str("path", secretsFile).Str("backup", backupPath).Int("secrets", len(localStore.Secrets)).Msg("re-encrypted secrets file with the new master key, use it from now on")
+	},
+}
+
+func init() {
+	secretsRekeyCmd.Flags().StringVar(&rekeyNewKey, "new-key", "", "Set the new master key in hex")
+	secretsRekeyCmd.Flags().StringVar(&rekeyNewKeyFile, "new-key-file", "", "Read the new master key from a file")
+	secretsRekeyCmd.Flags().StringVar(&rekeyNewKeyCommand, "new-key-command", "", "Read the new master key from the output of a shell command")
+	secretsRekeyCmd.Flags().StringVar(&rekeyBackupPath, "backup", "", "Set the path to copy the secrets file to before rekeying (defaults to '<file>.<timestamp>.bak')")
+	secretsRekeyCmd.MarkFlagsMutuallyExclusive("new-key", "new-key-file", "new-key-command")
+
+	secretsCmd.AddCommand(secretsRekeyCmd)
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/OpenCHAMI/magellan/internal/format"
+	"github.com/OpenCHAMI/magellan/pkg/power"
+	"github.com/OpenCHAMI/magellan/pkg/rotate"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/cznic/mathutil"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	rotateOutputFormat   format.DataFormat = format.FORMAT_JSON
+	rotatePasswordLength int
+	rotateDryRun         bool
+	rotateAssumeYes      bool
+)
+
+// The `secrets rotate` command changes the passwords of BMC accounts through
+// the Redfish AccountService and stores the new passwords once they were
+// verified. Hosts can be passed directly as arguments or read from the
+// output of a previous `collect` with '@<path>'.
+var secretsRotateCmd = &cobra.Command{
+	Use: "rotate [hosts|@inventory]...",
+	Example: `  // rotate the password of a single BMC
+  magellan secrets rotate https://172.16.0.101
+
+  // rotate the passwords of all collected BMCs and keep an audit report
+  magellan secrets rotate @nodes.yaml -o rotate-audit.yaml -y
+
+  // check that the accounts of all collected BMCs can be found
+  magellan secrets rotate @nodes.yaml --dry-run`,
+	Short: "Rotate the passwords of BMC accounts",
+	Long: "Rotate the password of the account used to log in to each BMC through the Redfish AccountService.\n" +
+		"Each new password is only stored in the secrets store once logging in with it succeeded. Otherwise,\n" +
+		"the old password is restored on that BMC. An audit report of every BMC is written, without passwords.\n\n" +
+		"Hosts can be passed as arguments and/or read from the output of 'collect' with '@<path>'.",
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// build the list of hosts from arguments and collected inventory
+		hosts := make([]string, 0, len(args))
+		seen := make(map[string]bool)
+		addHost := func(host string) {
+			if !strings.Contains(host, "://") {
+				host = "https://" + host
+			}
+			host = strings.TrimSuffix(host, "/")
+			if !seen[host] {
+				seen[host] = true
+				hosts = append(hosts, host)
+			}
+		}
+		for _, arg := range args {
+			path, isInventory := strings.CutPrefix(arg, "@")
+			if !isInventory {
+				addHost(arg)
+				continue
+			}
+			nodes, err := power.ParseInventory(path, format.DataFormatFromFileExt(path, format.FORMAT_JSON))
+			if err != nil {
+				log.Fatal().Err(err).Msgf("failed to parse inventory file %s", path)
+			}
+			for _, node := range nodes {
+				addHost(node.BmcIP)
+			}
+		}
+		if len(hosts) == 0 {
+			log.Error().Msg("no hosts to rotate passwords for")
+			os.Exit(1)
+		}
+
+		if length := viper.GetInt("secrets.rotate.length"); length < rotate.MinPasswordLength {
+			log.Error().Msgf("'--length' must be at least %d", rotate.MinPasswordLength)
+			os.Exit(1)
+		}
+
+		store, err := secrets.OpenStore(secretsFile)
+		if err != nil {
+			log.Error().Err(err).Str("path", secretsFile).Msg("failed to open secrets store")
+			os.Exit(1)
+		}
+		if _, ok := store.(*secrets.StaticStore); ok {
+			log.Error().Msg("rotating passwords requires a secrets store to save the new passwords in")
+			os.Exit(1)
+		}
+		store = newSecretMatcher(store)
+		if !rotateDryRun && !rotateAssumeYes && !confirm(fmt.Sprintf("Rotate the passwords of %d BMC(s)?", len(hosts))) {
+			log.Info().Msg("aborted")
+			return
+		}
+
+		// set the minimum/maximum number of concurrent processes
+		if concurrency <= 0 {
+			concurrency = mathutil.Clamp(len(hosts), 1, 10000)
+		}
+
+		// rotate the password of each host concurrently
+		var (
+			params = &rotate.RotateParams{
+				Store:          store,
+				Insecure:       insecure,
+				PasswordLength: viper.GetInt("secrets.rotate.length"),
+				DryRun:         rotateDryRun,
+			}
+			report  = make([]rotate.AuditEntry, 0, len(hosts))
+			mu      sync.Mutex
+			wg      sync.WaitGroup
+			chHosts = make(chan string, len(hosts))
+		)
+		for _, host := range hosts {
+			chHosts <- host
+		}
+		close(chHosts)
+
+		wg.Add(concurrency)
+		for i := 0; i < concurrency; i++ {
+			go func() {
+				defer wg.Done()
+				for host := range chHosts {
+					entry := rotate.RotateBMC(host, params)
+					event := log.Info()
+					if entry.Status != rotate.StatusRotated && entry.Status != rotate.StatusVerified {
+						event = log.Error()
+					}
+					event.Str("host", host).Str("status", string(entry.Status)).Str("error", entry.Error).Msg("finished BMC password rotation")
+					mu.Lock()
+					report = append(report, entry)
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		// keep the report stable regardless of the order hosts finished in
+		sort.Slice(report, func(i, j int) bool {
+			return report[i].Host < report[j].Host
+		})
+		outputFormat := rotateOutputFormat
+		if outputPath != "" {
+			outputFormat = format.DataFormatFromFileExt(outputPath, rotateOutputFormat)
+		}
+		writeFormattedOutput(report, outputFormat)
+
+		var failed []string
+		for _, entry := range report {
+			if entry.Status == rotate.StatusRollbackFailed {
+				log.Error().Str("host", entry.Host).Str("secret ID", entry.PendingSecretID).Msg("the new password could not be verified nor rolled back, it is kept under the pending secret ID")
+			}
+			if entry.Status != rotate.StatusRotated && entry.Status != rotate.StatusVerified {
+				failed = append(failed, entry.Host)
+			}
+		}
+		if len(failed) > 0 {
+			log.Warn().Strs("hosts", failed).Msg("failed to rotate the passwords of some hosts")
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	secretsRotateCmd.Flags().IntVar(&rotatePasswordLength, "length", 16, "Set the length of the generated passwords")
+	secretsRotateCmd.Flags().BoolVar(&rotateDryRun, "dry-run", false, "Only check that the account of each BMC can be found")
+	secretsRotateCmd.Flags().BoolVarP(&rotateAssumeYes, "yes", "y", false, "Rotate passwords without asking for confirmation")
+	secretsRotateCmd.Flags().BoolVarP(&insecure, "insecure", "i", false, "Skip TLS certificate verification")
+	secretsRotateCmd.Flags().StringVarP(&outputPath, "output-file", "o", "", "Set the path to write the audit report (defaults to standard output)")
+	secretsRotateCmd.Flags().VarP(&rotateOutputFormat, "output-format", "F", "Set the format of the audit report (json|yaml; can be overridden by file extensions)")
+
+	checkRegisterFlagCompletionError(secretsRotateCmd.RegisterFlagCompletionFunc("output-format", completionFormatData))
+
+	checkBindFlagError(viper.BindPFlag("secrets.rotate.length", secretsRotateCmd.Flags().Lookup("length")))
+
+	secretsCmd.AddCommand(secretsRotateCmd)
+}
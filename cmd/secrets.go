@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/OpenCHAMI/magellan/pkg/bmc"
 	"github.com/OpenCHAMI/magellan/pkg/secrets"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -31,9 +32,22 @@ var secretsCmd = &cobra.Command{
   magellan secrets retrieve $bmc_host -f secrets.json
 
   // list creds from specific secrets
-  magellan secrets list -f nodes.json`,
+  magellan secrets list -f nodes.json
+
+  // store creds in Vault instead of a local file
+  export VAULT_ADDR=https://vault.example.com:8200 VAULT_TOKEN=$token
+  magellan secrets store default $bmc_creds -f vault://secret/magellan/bmcs`,
 	Short: "Manage credentials for BMC nodes",
-	Long:  "Manage credentials for BMC nodes to for querying information through redfish. This requires generating a key and setting the 'MASTER_KEY' environment variable for the secrets store.",
+	Long: "Manage credentials for BMC nodes to for querying information through redfish. This requires generating a key and setting the 'MASTER_KEY' environment variable for the secrets store,\n" +
+		"or passing '--master-key-file' or '--master-key-command' to read it from a file or the output of a command.\n\n" +
+		"Paths starting with 'vault://<mount>/<path>' keep the credentials in a Vault KV version 2 secret instead, configured with the\n" +
+		"VAULT_* environment variables or the 'secrets.vault' section of the config file.",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// the config file can select the store, e.g. in Vault
+		if !cmd.Flags().Changed("file") && viper.IsSet("secrets.store") {
+			secretsFile = viper.GetString("secrets.store")
+		}
+	},
 }
 
 var secretsGenerateKeyCmd = &cobra.Command{
@@ -74,17 +88,21 @@ var secretsStoreCmd = &cobra.Command{
 
 		// handle input file format
 		switch secretsStoreFormat {
-		case "basic": // format: $username:$password
-			var (
-				values   []string
-				username string
-				password string
-			)
-
-			// seperate username and password provided
-			values = strings.Split(secretValue, ":")
-			if len(values) != 2 {
-				log.Error().Msgf("expected 2 arguments in [username:password] format but got %d", len(values))
+		case "basic": // format: $username:$password [$username:$password...]
+			var candidates []bmc.BMCCredentials
+
+			// seperate username and password provided, with several of them
+			// being candidates to try in order
+			for _, value := range args[1:] {
+				values := strings.Split(value, ":")
+				if len(values) != 2 {
+					log.Error().Msgf("expected 2 arguments in [username:password] format but got %d", len(values))
+					return
+				}
+				candidates = append(candidates, bmc.BMCCredentials{Username: values[0], Password: values[1]})
+			}
+			if len(candidates) == 0 {
+				log.Error().Msg("expected credentials in [username:password] format")
 				return
 			}
 
@@ -95,12 +113,17 @@ var secretsStoreCmd = &cobra.Command{
 				return
 			}
 
-			// extract username/password from input (for clarity)
-			username = values[0]
-			password = values[1]
-
 			// create JSON formatted string from input
-			secretValue = fmt.Sprintf("{\"username\": \"%s\", \"password\": \"%s\"}", username, password)
+			var data any = candidates[0]
+			if len(candidates) > 1 {
+				data = map[string]any{"candidates": candidates}
+			}
+			encoded, err := json.Marshal(data)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to marshal credentials")
+				return
+			}
+			secretValue = string(encoded)
 
 		case "base64": // format: ($encoded_base64_string)
 			decoded, err := base64.StdEncoding.DecodeString(secretValue)
@@ -180,6 +203,9 @@ var secretsStoreCmd = &cobra.Command{
 	},
 }
 
+// isValidCredsJSON checks that a secret holds either BMC credentials with a
+// username and password, or SNMP credentials with a community or a username
+// and authentication password.
 func isValidCredsJSON(val string) bool {
 	var (
 		validUsername bool
@@ -187,13 +213,31 @@ func isValidCredsJSON(val string) bool {
 		creds         map[string]string
 		err           error
 	)
+	// each candidate of a list of candidate credentials must be valid
+	var list struct {
+		Candidates []json.RawMessage `json:"candidates"`
+	}
+	if err = json.Unmarshal([]byte(val), &list); err == nil && len(list.Candidates) > 0 {
+		for _, candidate := range list.Candidates {
+			if !isValidCredsJSON(string(candidate)) {
+				return false
+			}
+		}
+		return true
+	}
 	err = json.Unmarshal([]byte(val), &creds)
 	if err != nil {
 		return false
 	}
+	if _, ok := creds["community"]; ok {
+		return true
+	}
 	_, validUsername = creds["username"]
 	_, validPassword = creds["password"]
-	return !json.Valid([]byte(val)) && validUsername && validPassword
+	if _, ok := creds["auth_password"]; ok {
+		validPassword = true
+	}
+	return validUsername && validPassword
 }
 
 var secretsRetrieveCmd = &cobra.Command{
@@ -273,14 +317,17 @@ var secretsRemoveCmd = &cobra.Command{
 				return
 			}
 
-			// update store by saving to original file
-			err = secrets.SaveSecrets(secretsFile, store.(*secrets.LocalSecretStore).Secrets)
-			if err != nil {
-				log.Error().
-					Err(err).
-					Str("path", secretsFile).
-					Msg("failed to save secrets to file")
-				return
+			// update store by saving to original file, other stores
+			// remove the secret right away
+			if local, ok := store.(*secrets.LocalSecretStore); ok {
+				err = secrets.SaveSecrets(secretsFile, local.Secrets)
+				if err != nil {
+					log.Error().
+						Err(err).
+						Str("path", secretsFile).
+						Msg("failed to save secrets to file")
+					return
+				}
 			}
 		}
 	},
@@ -306,3 +353,134 @@ func init() {
 	checkBindFlagError(viper.BindPFlags(secretsGenerateKeyCmd.Flags()))
 	checkBindFlagError(viper.BindPFlags(secretsGenerateKeyCmd.Flags()))
 }
+
+// loadBMCSecretStore builds the secret store used to look up BMC credentials
+// from the --username, --password and --secrets-file flags. If both the username
+// and password are set, they are used for every BMC. Otherwise, the secrets file
+// is opened and either flag that was passed temporarily overrides the matching
+// value of every credential in the store.
+func loadBMCSecretStore() secrets.SecretStore {
+	var (
+		store secrets.SecretStore
+		err   error
+	)
+	if username != "" && password != "" {
+		// First, try and load credentials from --username and --password if both are set.
+		log.Debug().Msgf("--username and --password specified, using them for BMC credentials")
+		return secrets.NewStaticStore(username, password)
+	}
+
+	// Alternatively, locate specific credentials (falling back to default) and override those
+	// with --username or --password if either are passed.
+	if secretsFile == "" && viper.IsSet("secrets.store") {
+		secretsFile = viper.GetString("secrets.store")
+	}
+	log.Debug().Msgf("one or both of --username and --password NOT passed, attempting to obtain missing credentials from secret store at %s", secretsFile)
+	if store, err = secrets.OpenStore(secretsFile); err != nil {
+		log.Error().Err(err).Msg("failed to open local secrets store")
+	}
+
+	// Temporarily override username/password of each BMC if one of those
+	// flags is passed. The expectation is that if the flag is specified
+	// on the command line, it should be used.
+	if username != "" {
+		log.Info().Msg("--username passed, temporarily overriding all usernames from secret store with value")
+	}
+	if password != "" {
+		log.Info().Msg("--password passed, temporarily overriding all passwords from secret store with value")
+	}
+	switch s := store.(type) {
+	case *secrets.StaticStore:
+		if username != "" {
+			s.Username = username
+		}
+		if password != "" {
+			s.Password = password
+		}
+	case *secrets.LocalSecretStore, *secrets.VaultStore:
+		// never write the overrides back to the store
+		if username != "" || password != "" {
+			return newSecretMatcher(&overrideStore{SecretStore: s, username: username, password: password})
+		}
+	}
+	return newSecretMatcher(store)
+}
+
+// newSecretMatcher wraps a secret store in a bmc.SecretMatcher, so that its
+// secret IDs are listed once for all of the BMCs of a command instead of for
+// each of them. Static stores, which hold the credentials of every BMC, are
+// returned as is, as are stores whose secret IDs can't be listed, which then
+// fail to match each BMC instead.
+func newSecretMatcher(store secrets.SecretStore) secrets.SecretStore {
+	if _, ok := store.(*secrets.StaticStore); ok || store == nil {
+		return store
+	}
+	matcher, err := bmc.NewSecretMatcher(store)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to list secret IDs to match BMCs with")
+		return store
+	}
+	return matcher
+}
+
+// overrideStore replaces the username and password of every credential read
+// from a secret store without changing the store itself. Next to a list of
+// candidate credentials, they replace those of every candidate (see
+// bmc.ParseBMCCredentialCandidates).
+type overrideStore struct {
+	secrets.SecretStore
+	username string
+	password string
+}
+
+func (s *overrideStore) GetSecretByID(secretID string) (string, error) {
+	secret, err := s.SecretStore.GetSecretByID(secretID)
+	if err != nil {
+		return secret, err
+	}
+	var creds map[string]any
+	if err := json.Unmarshal([]byte(secret), &creds); err != nil {
+		return secret, err
+	}
+	if s.username != "" {
+		creds["username"] = s.username
+	}
+	if s.password != "" {
+		creds["password"] = s.password
+	}
+	overridden, err := json.Marshal(creds)
+	return string(overridden), err
+}
+
+// initializeVaultConfig overrides the Vault settings from the environment
+// with those in the 'secrets.vault' section of the config file.
+func initializeVaultConfig() {
+	config := &secrets.DefaultVaultConfig
+	for key, value := range map[string]*string{
+		"secrets.vault.address":     &config.Address,
+		"secrets.vault.namespace":   &config.Namespace,
+		"secrets.vault.auth-method": &config.AuthMethod,
+		"secrets.vault.auth-mount":  &config.AuthMount,
+		"secrets.vault.role-id":     &config.RoleID,
+		"secrets.vault.role":        &config.Role,
+		"secrets.vault.jwt-path":    &config.JWTPath,
+		"secrets.vault.cacert":      &config.CACert,
+	} {
+		if viper.IsSet(key) {
+			*value = viper.GetString(key)
+		}
+	}
+	if viper.IsSet("secrets.vault.insecure") {
+		config.Insecure = viper.GetBool("secrets.vault.insecure")
+	}
+}
+
+// initializeMasterKeySource reads the master key of local secrets files from
+// the file or command set with flags or in the config file, if any, instead
+// of the MASTER_KEY environment variable.
+func initializeMasterKeySource() {
+	secrets.DefaultMasterKeySource = secrets.MasterKeySource{
+		File:    viper.GetString("secrets.master-key-file"),
+		Command: viper.GetString("secrets.master-key-command"),
+	}
+}
@@ -56,10 +56,11 @@ var updateCmd = &cobra.Command{
 			if store, err = secrets.OpenStore(secretsFile); err != nil {
 				log.Error().Str("id", uri).Err(err).Msg("failed to open local secrets store")
 			}
+			store = newSecretMatcher(store)
 
 			// Either none of the flags were passed or only one of them were; get
 			// credentials from secrets store to fill in the gaps.
-			bmcCreds, _ := bmc.GetBMCCredentials(store, uri)
+			bmcCreds := bmc.GetBMCCredentialsOrDefault(store, uri)
 			nodeCreds := secrets.StaticStore{
 				Username: bmcCreds.Username,
 				Password: bmcCreds.Password,
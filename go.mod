@@ -16,6 +16,7 @@ require (
 
 require (
 	github.com/Cray-HPE/hms-xname v1.4.0
+	github.com/gosnmp/gosnmp v1.38.0
 	github.com/lestrrat-go/jwx v1.2.31
 	github.com/ncruces/go-strftime v1.0.0
 	github.com/rs/zerolog v1.35.1
@@ -1,9 +1,11 @@
 package format
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"reflect"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,9 +16,10 @@ type (
 )
 
 const (
-	FORMAT_LIST DataFormat = "list"
-	FORMAT_JSON DataFormat = "json"
-	FORMAT_YAML DataFormat = "yaml"
+	FORMAT_LIST   DataFormat = "list"
+	FORMAT_JSON   DataFormat = "json"
+	FORMAT_YAML   DataFormat = "yaml"
+	FORMAT_NDJSON DataFormat = "ndjson"
 )
 
 func (df DataFormat) String() string {
@@ -25,20 +28,21 @@ func (df DataFormat) String() string {
 
 var (
 	DataFormatHelpMap = HelpMap{
-		string(FORMAT_LIST): "List format",
-		string(FORMAT_JSON): "JSON format",
-		string(FORMAT_YAML): "YAML format",
+		string(FORMAT_LIST):   "List format",
+		string(FORMAT_JSON):   "JSON format",
+		string(FORMAT_YAML):   "YAML format",
+		string(FORMAT_NDJSON): "Newline-delimited JSON format (one object per line)",
 	}
 )
 
 func (df *DataFormat) Set(v string) error {
 	switch DataFormat(v) {
-	case FORMAT_LIST, FORMAT_JSON, FORMAT_YAML:
+	case FORMAT_LIST, FORMAT_JSON, FORMAT_YAML, FORMAT_NDJSON:
 		*df = DataFormat(v)
 		return nil
 	default:
 		return fmt.Errorf("must be one of %v", []DataFormat{
-			FORMAT_LIST, FORMAT_JSON, FORMAT_YAML,
+			FORMAT_LIST, FORMAT_JSON, FORMAT_YAML, FORMAT_NDJSON,
 		})
 	}
 }
@@ -50,7 +54,7 @@ func (df DataFormat) Type() string {
 // MarshalData marshals arbitrary data into a byte slice formatted as outFormat.
 // If a marshalling error occurs or outFormat is unknown, an error is returned.
 //
-// Supported values are: json, list, yaml
+// Supported values are: json, list, ndjson, yaml
 func MarshalData(data any, outFormat DataFormat) ([]byte, error) {
 	switch outFormat {
 	case FORMAT_JSON:
@@ -65,6 +69,12 @@ func MarshalData(data any, outFormat DataFormat) ([]byte, error) {
 		} else {
 			return bytes, nil
 		}
+	case FORMAT_NDJSON:
+		if bytes, err := marshalNDJSON(data); err != nil {
+			return nil, fmt.Errorf("failed to marshal data into NDJSON: %w", err)
+		} else {
+			return bytes, nil
+		}
 	case FORMAT_LIST:
 		return nil, fmt.Errorf("this data format cannot be marshaled")
 	default:
@@ -72,11 +82,33 @@ func MarshalData(data any, outFormat DataFormat) ([]byte, error) {
 	}
 }
 
+// marshalNDJSON writes each element of a slice or array as a single line of
+// JSON. Any other value is written as a single line.
+func marshalNDJSON(data any) ([]byte, error) {
+	var (
+		buf     bytes.Buffer
+		encoder = json.NewEncoder(&buf)
+		value   = reflect.ValueOf(data)
+	)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		if err := encoder.Encode(data); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	for i := 0; i < value.Len(); i++ {
+		if err := encoder.Encode(value.Index(i).Interface()); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
 // UnmarshalData unmarshals a byte slice formatted as inFormat into an interface
 // v. If an unmarshalling error occurs or inFormat is unknown, an error is
 // returned.
 //
-// Supported values are: json, list, yaml
+// Supported values are: json, list, ndjson, yaml
 func UnmarshalData(data []byte, v any, inFormat DataFormat) error {
 	switch inFormat {
 	case FORMAT_JSON:
@@ -87,6 +119,18 @@ func UnmarshalData(data []byte, v any, inFormat DataFormat) error {
 		if err := yaml.Unmarshal(data, v); err != nil {
 			return fmt.Errorf("failed to unmarshal data into YAML: %w", err)
 		}
+	case FORMAT_NDJSON:
+		// join the lines into a JSON array so that v is expected to be a slice
+		var lines [][]byte
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				lines = append(lines, line)
+			}
+		}
+		array := append(append([]byte("["), bytes.Join(lines, []byte(","))...), ']')
+		if err := json.Unmarshal(array, v); err != nil {
+			return fmt.Errorf("failed to unmarshal data into NDJSON: %w", err)
+		}
 	case FORMAT_LIST:
 		return fmt.Errorf("this data format cannot be unmarshaled")
 	default:
@@ -110,6 +154,9 @@ func DataFormatFromFileExt(path string, defaultFmt DataFormat) DataFormat {
 	case ".yaml", ".yml", ".YAML", ".YML":
 		// The file is a YAML file
 		return FORMAT_YAML
+	case ".ndjson", ".jsonl", ".NDJSON", ".JSONL":
+		// The file is a newline-delimited JSON file
+		return FORMAT_NDJSON
 	}
 	return defaultFmt
 }
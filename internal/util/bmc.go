@@ -6,42 +6,46 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func GetBMCCredentials(store secrets.SecretStore, id string) bmc.BMCCredentials {
-	var (
-		creds bmc.BMCCredentials
-		err   error
-	)
+// GetBMCCredentials gets the credentials of the BMC with the given ID from
+// the secret ID that matches it best, trying the secret IDs that select
+// BMCs by vendor with the vendor returned by vendor if it is not nil. See
+// bmc.MatchSecretID for the order the secret IDs are tried in. If the secret
+// holds a list of candidates, the first one is returned.
+func GetBMCCredentials(store secrets.SecretStore, id string, vendor bmc.VendorFunc) bmc.BMCCredentials {
+	candidates, _ := GetBMCCredentialCandidates(store, id, vendor)
+	if len(candidates) == 0 {
+		return bmc.BMCCredentials{}
+	}
+	return candidates[0]
+}
 
+// GetBMCCredentialCandidates is like GetBMCCredentials, but returns every
+// candidate credential of the BMC in the order they are to be tried, along
+// with the secret ID they were found under.
+func GetBMCCredentialCandidates(store secrets.SecretStore, id string, vendor bmc.VendorFunc) ([]bmc.BMCCredentials, bmc.CredentialMatch) {
 	if id == "" {
 		log.Error().Msg("failed to get BMC credentials: id was empty")
-		return creds
+		return nil, bmc.CredentialMatch{}
 	}
 
 	if id == secrets.DEFAULT_KEY {
 		log.Info().Msg("fetching default credentials")
-		if creds, err = bmc.GetBMCCredentialsDefault(store); err != nil {
+		creds, err := bmc.GetBMCCredentialsDefault(store)
+		if err != nil {
 			log.Warn().Err(err).Msg("failed to get default credentials")
-		} else {
-			log.Info().Msg("default credentials found, using")
+			return nil, bmc.CredentialMatch{}
 		}
-		return creds
+		log.Info().Msg("default credentials found, using")
+		return []bmc.BMCCredentials{creds}, bmc.CredentialMatch{SecretID: secrets.DEFAULT_KEY, Rule: bmc.RuleDefault}
 	}
 
-	if creds, err = bmc.GetBMCCredentials(store, id); err != nil {
-		// Specific credentials for URI not found, fetch default.
-		log.Warn().Str("id", id).Msg("specific credentials not found, falling back to default")
-		if defaultSecret, err := bmc.GetBMCCredentialsDefault(store); err != nil {
-			// We've exhausted all options, the credentials will be blank unless
-			// overridden by a CLI flag.
-			log.Warn().Str("id", id).Err(err).Msg("no default credentials were set, they will be blank unless overridden by CLI flags")
-		} else {
-			// Default credentials found, use them.
-			log.Info().Str("id", id).Msg("default credentials found, using")
-			creds = defaultSecret
-		}
-	} else {
-		log.Info().Str("id", id).Msg("specific credentials found, using")
+	candidates, match, err := bmc.GetMatchingBMCCredentialCandidates(store, id, vendor)
+	if err != nil {
+		// We've exhausted all options, the credentials will be blank unless
+		// overridden by a CLI flag.
+		log.Warn().Str("id", id).Err(err).Msg("no matching credentials were found, they will be blank unless overridden by CLI flags")
+		return nil, match
 	}
-
-	return creds
+	log.Info().Str("id", id).Str("rule", string(match.Rule)).Int("candidates", len(candidates)).Msg("matching credentials found, using")
+	return candidates, match
 }
@@ -0,0 +1,123 @@
+MAGELLAN-BIOS(1) "OpenCHAMI" "Manual Page for magellan-bios"
+
+# NAME
+
+magellan-bios - Get, compare and set BIOS attributes of nodes
+
+# SYNOPSIS
+
+magellan bios get [OPTIONS] [_node-id_...]++
+magellan bios diff [OPTIONS] (--reference _node-id_ | --golden _path_) [_node-id_...]++
+magellan bios set [OPTIONS] --file _path_ _node-id_...
+
+# EXAMPLES
+
+magellan bios get -f nodes.json -o bios.yaml++
+magellan bios diff -f nodes.json --reference x1000c0s0b3n0++
+magellan bios diff -f nodes.json --golden golden.yaml x1000c0s0b3n1++
+magellan bios set -f nodes.json --file attrs.yaml -r ForceRestart --verify x1000c0s0b3n1
+
+# DESCRIPTION
+
+Read the attributes of the Redfish Bios resource of nodes found by a previous
+*magellan-collect*(1). Nodes are identified the same way as with
+*magellan power*. When no _node-id_ is given, every node in the inventory is
+used.
+
+# COMMANDS
+
+*get*
+	Dump the BIOS attributes of each node along with its manufacturer, model,
+	BIOS version and attribute registry.
+
+*diff*
+	Compare the BIOS attributes of each node against a reference and report only
+	the attributes that differ, grouped by node model. Nodes without any
+	differences are left out. Attributes that are missing on either side are
+	reported with a null value.
+
+*set*
+	PATCH the attributes in the file passed with *--file* to the settings
+	object (usually Bios/Settings) of each node. The BMC holds the new values
+	as pending until the apply time. Optionally reset the nodes so that the
+	values are applied, and verify that the values were actually applied.
+	Prints the status of each node: _applied_, _pending_, _verified_,
+	_not applied_ or _failure_.
+
+# FLAGS
+
+*--apply-time* _time_
+	(set) Set when the BMC applies the new values. One of _OnReset_ (default),
+	_MaintenanceWindow_ or _Immediate_. The Redfish values
+	_AtMaintenanceWindowStart_ and _InMaintenanceWindowOnReset_ are also
+	accepted. Nodes that do not support the apply time are skipped.
+
+*--file* _path_
+	(set) Set the path to a YAML or JSON file with the attributes to set, in the
+	same format as a golden file.
+
+*-f, --inventory-file* _path_
+	Set the path to the output of *magellan-collect*(1). Use "-" to read from
+	standard input. Defaults to the 'collect.output-file' config value.
+
+*--golden* _path_
+	(diff) Compare nodes against the attributes in a YAML or JSON file. The file
+	can either be a flat map of attributes or a single node from the output of
+	*get*.
+
+*-i, --insecure*
+	Skip TLS verification when making HTTP requests.
+
+*--node-key* _key_
+	Match node IDs against this property of the inventory: _auto_ (default),
+	_xname_, _uuid_, _serial_ or _uri_. With _auto_, each is tried in order.
+
+*--node-map* _path_
+	Set the path to a YAML file mapping node names to an xname, UUID, serial
+	number or System URI.
+
+*-o, --output-file* _path_
+	Set the path to write the output. Defaults to standard output.
+
+*-F, --output-format* _format_
+	Set the output data format (_json_ or _yaml_). Default is _yaml_.
+
+*-p, --password* _value_
+	Set the password for basic authentication for requests to the BMC nodes.
+
+*-r, --reset* _type_
+	(set) Perform a Redfish reset of this type (e.g. _ForceRestart_ or
+	_GracefulRestart_) on each node after the attributes were accepted.
+
+*--reference* _node-id_
+	(diff) Compare nodes against the attributes of this node. The node does not
+	need to be one of the compared nodes.
+
+*--secrets-file* _path_
+	Set the path to a secrets file. See *magellan-secrets*(1).
+
+*--verify*
+	(set) Wait until each node reports the new attribute values as applied.
+
+*--verify-interval* _duration_
+	(set) Set how often to check whether the attributes were applied. Default is
+	30s.
+
+*--verify-timeout* _duration_
+	(set) Set how long to wait for the attributes to be applied. Default is 15m.
+
+*-u, --username* _value_
+	Set the username for basic authentication for requests to the BMC nodes.
+
+See *magellan*(1) for information about global flags used for all commands.
+
+# AUTHOR
+
+Written by the OpenCHAMI developers.
+
+# SEE ALSO
+
+*magellan*(1), *magellan-collect*(1)
+
+; Vim modeline settings
+; vim: set tw=80 noet sts=4 ts=4 sw=4 syntax=scdoc:
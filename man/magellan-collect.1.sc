@@ -29,6 +29,10 @@ magellan collect pdu x3000m0 --username admin --password initial0
 // Collect from multiple PDUs and send to SMD++
 magellan collect pdu x3000m0 x3000m1 -u admin -p initial0 | magellan send https://smd.example.com
 
+// Collect from a PDU over SNMPv2c++
+magellan secrets store x3000m3 '{"community": "public"}' --format json -f secrets.json++
+magellan collect pdu x3000m3 --protocol snmp --secrets-file secrets.json
+
 // Take the output of 'scan' and input directly into 'collect'
 magellan scan --subnet 172.18.0.0/24 --port 5000 -l info -i -F json | ./magellan collect -f json --show-output -i
 
@@ -40,7 +44,8 @@ magellan scan --subnet 172.18.0.0/24 --port 5000 -l info -i -F json | ./magellan
 *-m, --bmc-id-map* (_data_ | @_path_)
 	Set the BMC ID mapping from raw JSON data or use @<path> to specify a file
 	path. The specified file can either be JSON or YAML and is determined by the
-	file extension.
+	file extension. CSV files (_.csv_) and files in /etc/hosts format (named
+	_hosts_ or ending in _.hosts_) are also accepted; see *magellan-idmap*(1).
 
 	An example of a valid BMC ID map would look like the following specified in
 	YAML format:
@@ -54,6 +59,63 @@ magellan scan --subnet 172.18.0.0/24 --port 5000 -l info -i -F json | ./magellan
 		...
 	```
 
+	The _map_key_ selects what identifies a BMC in the map. One of
+	_bmc-ip-addr_ (the IPv4 address of the BMC), _bmc-mac-addr_ (the MAC
+	address of the BMC interface with that address), _manager-uuid_ (the UUID
+	of the BMC's Redfish Manager), _chassis-serial_ (the serial number of the
+	chassis of its first system) or _hostname_ (the name the BMC was scanned
+	by, or the reverse DNS name of its address, which is only looked up when
+	a mapper uses it). MAC addresses, UUIDs and
+	hostnames are compared regardless of case and MAC address notation. All
+	keys but _bmc-ip-addr_ and _hostname_ are only known after the BMC has
+	been crawled, so unmapped BMCs are crawled before they are skipped.
+
+	With _id_format: xname_, or when any ID parses or starts like an XNAME,
+	every ID must be the XNAME of a BMC and is normalized to its canonical
+	form, e.g. _x1000c0s0b03_ to _x1000c0s0b3_. Set _id_format: any_ to use
+	such IDs as they are.
+	Maps with invalid or empty IDs, duplicate keys or the same ID for two keys
+	are rejected with the line of each offending entry.
+
+*--id-from-location* [_xname_|_template_]
+	Build BMC IDs from the Redfish Location of the chassis. With _xname_, the
+	default when no value is given, a node or BMC XNAME in
+	PartLocation.ServiceLabel is used as is, or the XNAME is built from the
+	number in Placement.Rack, Placement.RackOffset as the slot and
+	PartLocation.LocationOrdinalValue as the BMC. Any other value is an ID
+	template, as for *--id-template*. BMCs without enough of a location get
+	their ID from *--bmc-id-map*, *--id-template* or the generated XNAME
+	instead.
+
+*--id-mappers* _mapper_,...
+	Set the BMC ID mappers to try in order. Each BMC gets its ID from the first
+	mapper that produces one. The mappers are _user-map_ (*--bmc-id-map*),
+	_location_ (*--id-from-location*), _template_ (*--id-template*) and _xname_,
+	the XNAME generated from the IP address. Defaults to _location_ if
+	*--id-from-location* is set, followed by the first of _user-map_,
+	_template_ and _xname_ whose flag is set. An unknown or misconfigured
+	mapper, or a BMC ID map with invalid or duplicate entries, fails before
+	any BMC is contacted.
+
+*--id-report* [_path_]
+	Write the BMCs that no mapper produced an ID for, that the user map and
+	chassis location disagree on, or that got an ID already given to another
+	BMC to a file, or to stderr if no path is given. The report is written
+	before the collected output.
+
+*--id-template* _template_
+	Set a Go text/template to build BMC IDs from when no *--bmc-id-map* is
+	given. The template is executed after a BMC has been crawled, and can use
+	the fields _.IP_, _.Octet1_ to _.Octet4_, _.Hostname_, _.ShortName_,
+	_.MAC_, _.UUID_, _.Serial_, _.Rack_, _.Row_, _.RackOffset_, _.Slot_ and
+	_.ServiceLabel_, and the functions _lower_, _upper_ and _required_, which
+	skips BMCs that do not report a field. BMCs that would get an ID already
+	given to another BMC in the same run are skipped.
+
+	```
+	magellan collect --id-template 'rack{{.Octet3}}-node{{.Octet4}}'
+	```
+
 *--cacert* _path_
 	Set the path to a certificate file. This certificate is NOT included in requests
 	made to BMC nodes. When this flag is not provided, the default system
@@ -87,7 +149,7 @@ magellan scan --subnet 172.18.0.0/24 --port 5000 -l info -i -F json | ./magellan
 	*protocol* - Protocol TCP or UDP. (tcp|udp)
 	*state* - State of the remote asset. (true|false)
 	*timestamp* - Last time the remote asset was scanned or pinged.
-	*service_type* - Type of service. (Redfish|PDU)
+	*service_type* - Type of service. (Redfish|RedfishPDU|JAWS)
 
 
 *-F, --output-format* _format_
@@ -147,6 +209,23 @@ magellan scan --subnet 172.18.0.0/24 --port 5000 -l info -i -F json | ./magellan
 
 	See *magellan-secrets*(1) for more details.
 
+*--sensors*
+	Also take a snapshot of the environmental sensors of every chassis. This
+	includes temperatures, fan speeds, power supply input/output watts and
+	voltage rails, each with their thresholds and health.
+
+	The newer Redfish *Sensors* collection (with *ThermalSubsystem* and
+	*PowerSubsystem*) is used when available, otherwise the deprecated *Thermal*
+	and *Power* resources are read instead.
+
+	The snapshot is a separate, timestamped dataset from the inventory. It is
+	written to the path set with *--sensors-file*, next to the *--output-file*
+	with a *.sensors* suffix (e.g. _nodes.sensors.yaml_), or in a *sensors*
+	directory for each BMC when using *--output-dir*.
+
+*--sensors-file* _path_
+	Set the path to store the sensor snapshot collected with *--sensors*.
+
 *--show*
 	Show the output of a collect run.
 
@@ -158,6 +237,11 @@ magellan scan --subnet 172.18.0.0/24 --port 5000 -l info -i -F json | ./magellan
 	When this flag is set, the value overrides all of the values loaded from the
 	secrets file.
 
+*--write-back-creds*
+	Store the candidate credentials that authenticated with a BMC under its URI
+	in the secrets file, so that the other candidates are not tried again on
+	later runs. See *magellan-secrets*(1) for storing candidate credentials.
+
 
 See *magellan*(1) for information about global flags used for all commands.
 
@@ -165,11 +249,36 @@ See *magellan*(1) for information about global flags used for all commands.
 
 These are the subcommands for *collect*:
 
-*pdu* _host_,... [-u _username_] [-p _password]
-	Retrieve PDU-related data using JAWS instead of Redfish service. The _host_
+*pdu* _host_,... [-u _username_] [-p _password] [--protocol _protocol_] [--secrets-file _path_] [--outlet-map _path_]
+	Retrieve PDU-related data using JAWS, Redfish or SNMP. The _host_
 	argument expects a list of valid URI strings. A request is made to each of
 	the _host_ provided similar to the base *collect* command and returns a list
-	of dictionaries.
+	of dictionaries. Each dictionary includes the outlets with their state and
+	current, voltage and active power readings, the readings of the input
+	phases and outlet branches, and the model, serial number and firmware
+	version of the PDU.
+
+	*--protocol* _protocol_
+		Set the protocol used to collect from the PDUs. One of _auto_ (default),
+		_jaws_ or _redfish_. With _auto_, PDUs whose Redfish ServiceRoot links
+		to a PowerEquipment resource are crawled through their RackPDUs, and all
+		others through JAWS. With _snmp_, the outlet tables of APC (PowerNet-MIB)
+		and Raritan (PDU2-MIB) PDUs are walked over SNMPv2c or SNMPv3, and the
+		identity of the PDU is read from the ENTITY-MIB. A _host_ may include a
+		port when the agent does not listen on 161.
+
+	*--secrets-file* _path_
+		Set the path to the secrets file with the SNMP credentials, which is
+		required with *--protocol snmp*. The credentials of each PDU are stored
+		under its _host_, falling back to the default secret, as JSON with a
+		_community_ for SNMPv2c, or a _username_ with an optional _auth_password_,
+		_auth_protocol_ (default SHA), _priv_password_ and _priv_protocol_
+		(default AES) for SNMPv3.
+
+	*--outlet-map* _path_
+		Set the path to a YAML or JSON file mapping the outlets of each PDU, by
+		_host_ and outlet ID, to the nodes they power. Mapped outlets get a
+		_node_ in the output. See *magellan pdu map*.
 
 	*-p, --password* _value_
 		Set the password to _value_ used for basic authentication to the PDU node.
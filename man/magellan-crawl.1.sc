@@ -42,6 +42,11 @@ magellan crawl https://bmc.example.com -i -u username -p password
 *-u, --username* _value_
 	Set the username for basic authentication for requests to the BMC node.
 
+*--write-back-creds*
+	Store the candidate credentials that authenticated with the BMC under its
+	URI in the secrets file. See *magellan-secrets*(1) for storing candidate
+	credentials.
+
 See *magellan*(1) for information about global flags used for all commands.
 
 # AUTHOR
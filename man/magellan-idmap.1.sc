@@ -0,0 +1,74 @@
+MAGELLAN-IDMAP(1) "OpenCHAMI" "Manual Page for magellan-idmap"
+
+# NAME
+
+magellan-idmap - Work with BMC ID maps
+
+# SYNOPSIS
+
+magellan idmap convert [OPTIONS] _path_
+
+# DESCRIPTION
+
+The *convert* subcommand reads a BMC ID map, as used by *magellan collect
+--bmc-id-map*, and writes it in the canonical YAML form with its keys and IDs
+normalized. Invalid or duplicate entries are reported with their lines, and
+nothing is written.
+
+The map can be JSON or YAML, chosen by the file extension, or one of:
+
+*CSV* (_.csv_)
+	A header row names the columns. The first column named after a map key
+	(_bmc-ip-addr_, _bmc-mac-addr_, _manager-uuid_, _chassis-serial_ or
+	_hostname_) is the key, and the column named _id_ or _xname_ holds the
+	IDs; an _xname_ column validates and normalizes them as XNAMEs. Other
+	columns are ignored, and lines starting with _#_ are comments.
+
+	```
+	rack,bmc-mac-addr,xname
+	R1,a4:bf:01:5c:29:10,x1000c0s0b0
+	```
+
+*hosts* (named _hosts_ or ending in _.hosts_)
+	The /etc/hosts format, mapping the IPv4 address of each line to the first
+	name after it. Aliases, comments, and IPv6 and loopback addresses are
+	ignored.
+
+	```
+	172.21.0.1  x1000c0s0b0  bmc1
+	```
+
+Both forms can also be passed to *magellan collect --bmc-id-map* directly.
+
+# FLAGS
+
+*-o, --output-file* _path_
+	Set the path to write the BMC ID map to. Defaults to standard output.
+
+*-f, --input-format* _format_
+	Set the format of JSON or YAML files without an extension.
+
+	Possible _format_ values:
+
+	- _json_
+	- _yaml_ (default)
+
+See *magellan*(1) for information about global flags used for all commands.
+
+# EXAMPLES
+
+```
+magellan idmap convert bmcs.csv -o mappings.yaml
+magellan idmap convert /etc/hosts -o mappings.yaml
+```
+
+# AUTHOR
+
+Written by David J. Allen and maintained by the OpenCHAMI developers.
+
+# SEE ALSO
+
+*magellan*(1), *magellan-collect*(1)
+
+; Vim modeline settings
+; vim: set tw=80 noet sts=4 ts=4 sw=4 syntax=scdoc:
@@ -0,0 +1,94 @@
+MAGELLAN-LOGS(1) "OpenCHAMI" "Manual Page for magellan-logs"
+
+# NAME
+
+magellan-logs - Download the event logs of BMC nodes
+
+# SYNOPSIS
+
+magellan logs [OPTIONS] [_host_...]
+
+# EXAMPLES
+
+magellan logs -u username -p password https://172.16.0.101++
+magellan logs -f nodes.json --since 24h --severity Warning -F ndjson++
+magellan logs -f nodes.json -o sel-archive.yaml --clear
+
+# DESCRIPTION
+
+Download the entries of every Redfish LogService (such as the SEL) found under
+the Managers and Systems of each _host_. Hosts can be passed as arguments and/or
+read from the output of *magellan-collect*(1) with *--inventory-file*. Hosts
+without a scheme are assumed to use HTTPS.
+
+Each entry contains the host, the URI of its LogService, its ID, creation time,
+severity, MessageId, message and entry type. Entries are written to standard
+output unless *--output-file* is set.
+
+# FLAGS
+
+*--clear*
+	Clear the logs on each BMC after the entries were successfully written to
+	the output file. Requires *--output-file*, and cannot be used with
+	*--since* or *--severity*, since entries left out by them would be cleared
+	without being archived. Only the LogServices that were
+	read completely are cleared, and LogServices that do not support the
+	ClearLog action are skipped.
+
+*-f, --inventory-file* _path_
+	Read hosts from the output of *magellan-collect*(1). Use "-" to read from
+	standard input.
+
+*--input-format* _format_
+	Set the format of the inventory file (_json_ or _yaml_). Default is _json_.
+
+*-i, --insecure*
+	Skip TLS verification when making HTTP requests.
+
+*-o, --output-file* _path_
+	Set the path to write the log entries. The format is determined by the
+	file extension if it is one of .json, .yaml, .yml, .ndjson or .jsonl.
+
+*-F, --output-format* _format_
+	Set the output data format.
+
+	Possible output formats:
+
+	- _json_ (default)
+	- _yaml_
+	- _ndjson_ (one entry per line)
+
+*-p, --password* _value_
+	Set the password for basic authentication for requests to the BMC nodes.
+
+*--secrets-file* _path_
+	Set the path to a secrets file. The MASTER_KEY environment variable must be
+	set first.
+
+	See *magellan-secrets*(1) for more information about using the secrets file.
+
+*--severity* _severity_
+	Only include entries of at least this severity. One of _OK_, _Warning_ or
+	_Critical_. Entries without a known severity are always included.
+
+*--since* _time_
+	Only include entries created since _time_, which is either a duration
+	relative to now (e.g. "24h") or a timestamp (e.g. "2025-01-31" or
+	"2025-01-31T08:00:00Z"). Entries without a valid timestamp are always
+	included.
+
+*-u, --username* _value_
+	Set the username for basic authentication for requests to the BMC nodes.
+
+See *magellan*(1) for information about global flags used for all commands.
+
+# AUTHOR
+
+Written by the OpenCHAMI developers.
+
+# SEE ALSO
+
+*magellan*(1), *magellan-collect*(1), *magellan-secrets*(1)
+
+; Vim modeline settings
+; vim: set tw=80 noet sts=4 ts=4 sw=4 syntax=scdoc:
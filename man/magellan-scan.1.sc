@@ -61,8 +61,10 @@ magellan scan --subnet 10.0.0.0 --subnet 172.16.0.0 --subnet-mask 255.255.0.0 --
 
 *--include* _type_...
 	Set which asset types to include in the scan. BMC nodes are detected using
-	Redfish where as PDU nodes are found using JAWS. Multiple values can be set
-	for a single scan (e.g. *--include=bmcs,pdus*).
+	Redfish where as PDU nodes are found using JAWS, or using Redfish if the
+	ServiceRoot links to a PowerEquipment resource. Multiple values can be set
+	for a single scan (e.g. *--include=bmcs,pdus*). Redfish PDUs are reported
+	with the _RedfishPDU_ service type, even when BMCs are included as well.
 
 	Possible _type_ values:
 
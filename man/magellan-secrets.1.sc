@@ -6,11 +6,15 @@ magellan-secrets - Manage BMC credentials in a flat file
 
 # SYNOPSIS
 
+magellan secrets export [OPTIONS] _path_++
 magellan secrets generatekey++
+magellan secrets import [OPTIONS] _path_++
 magellan secrets list [OPTIONS]++
+magellan secrets rekey [OPTIONS]++
 magellan secrets remove [OPTIONS] _secret_id_...++
 magellan secrets retrieve [OPTIONS] _secret_id_++
-magellan secrets store [OPTIONS] _secret_id_ _data_
+magellan secrets rotate [OPTIONS] _host_|@_inventory_...++
+magellan secrets store [OPTIONS] _secret_id_ _data_...
 
 # EXAMPLES
 
@@ -36,35 +40,149 @@ magellan secrets list -f nodes.json
 // perform a collect using the secret store++
 magellan collect --secrets-file node.json
 
+// store factory default creds to try in order on BMCs without other creds++
+magellan secrets store default ADMIN:ADMIN root:calvin
+
+// store creds for every BMC in a subnet, and for every Supermicro BMC++
+magellan secrets store 172.16.1.0/24 $rack_creds++
+magellan secrets store vendor:Supermicro $supermicro_creds
+
+// store the creds of many BMCs from a CSV file with id, username and password columns++
+magellan secrets import bmcs.csv
+
+// move creds from a secrets file to Vault through an encrypted bundle++
+export BUNDLE_KEY=$(magellan secrets generatekey)++
+magellan secrets export -f nodes.json nodes.bundle --bundle-key $BUNDLE_KEY++
+magellan secrets import -f vault://secret/magellan/bmcs nodes.bundle --bundle-key $BUNDLE_KEY
+
+// re-encrypt the secrets file with a new master key++
+magellan secrets rekey -f nodes.json --new-key $(magellan secrets generatekey)
+
+// store and use creds in a Vault KV version 2 secrets engine++
+export VAULT_ADDR=https://vault.example.com:8200 VAULT_TOKEN=$token++
+magellan secrets store -f vault://secret/magellan/bmcs $bmc_host $bmc_creds++
+magellan collect --secrets-file vault://secret/magellan/bmcs
+
 # FLAGS
 
 *-f, --file* _path_
 	Set path to a secrets file to manage secrets.
 
 	Requires the *MASTER_KEY* environment variable to be set. This can be set by
-	generating a new key with the *magellan secrets generatekey* command.
+	generating a new key with the *magellan secrets generatekey* command. The
+	key can also be read from a file or a command with the *--master-key-file*
+	and *--master-key-command* global flags, see *magellan*(1).
 
 	Credentials from the secrets file can only be accessed using the same key
 	initially used to store the credential.
 
+	A _path_ of the form *vault://*_mount_/_secret_ stores the secrets as the
+	fields of the Vault secret _secret_ in the KV version 2 secrets engine
+	mounted at _mount_ instead. See *VAULT* below. Defaults to the
+	*secrets.store* config value, then *secrets.json*.
+
 See *magellan*(1) for information about global flags used for all commands.
 
 # COMMANDS
 
 Manage, list, retrieve, remove, and store BMC credentials.
 
+## export
+
+Exports every secret of the secrets store specified with _path_ to an
+encrypted bundle, if a bundle key is set, or to a plaintext file with
+*--plaintext*. A bundle is a secrets file encrypted with the bundle key instead
+of the master key, so the secret IDs are visible in it. Plaintext files are
+written in the formats *import* reads. Existing files are never overwritten.
+
+The format of this command is:
+
+*export* [-f _path_] _output_
+	*--bundle-key* _key_
+		Encrypt the bundle with _key_ in hex.
+
+	*--bundle-key-command* _command_
+		Encrypt the bundle with the key printed by a shell command.
+
+	*--bundle-key-file* _path_
+		Encrypt the bundle with the key in a file.
+
+	*-F, --format* _format_
+		Set the format of plaintext secrets (_csv_, _json_, _yaml_ or
+		_ndjson_). Defaults to the extension of _output_, then _json_.
+
+	*--plaintext*
+		Export secrets unencrypted. Requires confirmation unless *-y* is set.
+		An _output_ of *-* writes them to standard output.
+
+	*-y, --yes*
+		Export secrets in plaintext without asking for confirmation.
+
 ## generatekey
 
 Generates a new 32-byte master key (in hex).
 
+## import
+
+Stores the secrets of a file in the secrets store specified with _path_. The
+file is either an encrypted bundle written by *export*, if a bundle key is set,
+or a list of records with an _id_, _username_ and _password_ in CSV (with a
+header row), JSON, YAML or NDJSON. Other CSV columns are ignored.
+
+Several records with the same ID are stored as candidate credentials to try in
+order. Records with a _secret_ instead of a username and password are stored
+as is. Secrets that already exist are skipped unless *--overwrite* is set.
+
+The format of this command is:
+
+*import* [-f _path_] _input_
+	*--bundle-key*, *--bundle-key-command*, *--bundle-key-file*
+		Read an encrypted bundle with the key, as for *export*.
+
+	*-F, --format* _format_
+		Set the format of the records (_csv_, _json_, _yaml_ or _ndjson_).
+		Defaults to the extension of _input_, then _json_. An _input_ of *-*
+		reads them from standard input.
+
+	*--overwrite*
+		Replace secrets that already exist in the secrets store.
+
 ## list
 
 Lists all the secret IDs and their values for secrets file specified with _path_.
+Values are never shown in plaintext: they are encrypted for secrets files, and
+shown as *[redacted]* for Vault. Use *retrieve* to read a secret.
 
 The format of this command is:
 
 *list* [-f _path_]
 
+## rekey
+
+Re-encrypts every secret of the secrets file specified with _path_ with a new
+master key. The current master key is read as for every other command, and the
+new one from the flags below.
+
+The secrets file is first copied to a backup, and then replaced at once, so
+that it is never left with secrets encrypted with different keys. Nothing is
+changed if any secret can't be decrypted with the current master key.
+
+The format of this command is:
+
+*rekey* [-f _path_]
+	*--backup* _path_
+		Set the path to copy the secrets file to before rekeying. Defaults to
+		_path_._timestamp_.bak.
+
+	*--new-key* _key_
+		Set the new master key in hex.
+
+	*--new-key-command* _command_
+		Read the new master key from the output of a shell command.
+
+	*--new-key-file* _path_
+		Read the new master key from a file.
+
 ## remove
 
 Remove secrets by IDs from secrets file specified with _path_.
@@ -81,19 +199,119 @@ The format of this command is:
 
 *retrieve* [-f _path_] _secret_id_
 
+## rotate
+
+Rotates the password of the account used to log in to each BMC through the
+Redfish AccountService. Hosts are passed as arguments, or read from the output
+of *magellan collect* with @_inventory_.
+
+A random password is generated for each BMC and set on its account. It is only
+stored in the secrets file once logging in with it succeeded, under the hostname
+or IP address of the BMC so that it matches any scheme or port. Secrets stored
+under any URI of the BMC are replaced too, since they are matched first. Otherwise, the old password is restored on that BMC. If that fails
+too, the new password is stored under *rotate-pending:*_uri_ instead.
+
+An audit report with the outcome of each BMC is written to standard output or
+to the output file. It never includes passwords. The command exits with an
+error if the password of any BMC could not be rotated.
+
+The format of this command is:
+
+*rotate* [-f _path_] _host_|@_inventory_...
+	*--dry-run*
+		Only check that the account of each BMC can be found.
+
+	*-i, --insecure*
+		Skip TLS certificate verification.
+
+	*--length* _length_
+		Set the length of the generated passwords. Defaults to 16.
+
+	*-o, --output-file* _path_
+		Set the path to write the audit report to.
+
+	*-F, --output-format* _format_
+		Set the format of the audit report (_json_ or _yaml_).
+
+	*-y, --yes*
+		Rotate passwords without asking for confirmation.
+
 ## store
 
 Stores the given string value under secretID.
 
 The format of this command is:
 
-*store* [-f _path_] _secret_id_ _data_
+*store* [-f _path_] _secret_id_ _data_...
+
+With the _basic_ format, _data_ is _username_:_password_. Several of them are
+stored as a list of candidate credentials, which *collect*, *crawl* and other
+commands try in order until one authenticates with the BMC. With the _json_
+format, such a list is written as:
+
+	{"candidates": [{"username": "ADMIN", "password": "ADMIN"}, ...]}
+
+The candidate that authenticated is logged and, with *--write-back-creds*,
+stored under the URI of the BMC.
+
 	*-F, --format* _format_
 		Set the input data format to store secrets in the secrets file.
 
 	*-i, --input-file* _string_
 		Set the file to read as input.
 
+# MATCHING SECRETS TO BMCS
+
+The _secret_id_ of a secret can be the URI of a BMC or select a group of BMCs.
+*collect*, *crawl* and other commands use the credentials from the first of
+these rules that matches a BMC:
+
+. *exact*: the BMC URI, exactly as shown by *magellan list*.
+. *host*: the hostname or IP address of the BMC, e.g. _172.16.0.105_.
+. *glob*: a shell glob for the hostname or IP address, e.g. _x3000c0s\*b0_.
+  The glob with the most characters that are not wildcards wins.
+. *cidr*: a subnet holding the IP address of the BMC, e.g. _172.16.0.0/16_.
+  The longest prefix wins.
+. *vendor*: *vendor:* followed by a case-insensitive glob for the *Vendor* in
+  the Redfish service root of the BMC, e.g. _vendor:hpe_. The service root is
+  only read when no earlier rule matches.
+. *default*: the _default_ secret.
+
+The matching _secret_id_ and rule are logged with *--log-level debug*. The
+credentials are never logged.
+
+# VAULT
+
+The Vault server and the way *magellan* logs in to it are set with the
+following environment variables, or with the matching keys under
+*secrets.vault* in the config file. Tokens and secret IDs are only read from
+the environment.
+
+*VAULT_ADDR* (_address_)
+	The URL of the Vault server. Defaults to _http://127.0.0.1:8200_.
+
+*VAULT_NAMESPACE* (_namespace_)
+	The Vault Enterprise namespace.
+
+*VAULT_AUTH_METHOD* (_auth-method_)
+	One of *token* (default), *approle* or *kubernetes*.
+
+*VAULT_AUTH_MOUNT* (_auth-mount_)
+	The mount path of the auth method. Defaults to the name of the method.
+
+*VAULT_TOKEN*
+	The token used by the *token* auth method.
+
+*VAULT_ROLE_ID* (_role-id_), *VAULT_SECRET_ID*
+	The credentials used by the *approle* auth method.
+
+*VAULT_ROLE* (_role_), *VAULT_K8S_TOKEN_PATH* (_jwt-path_)
+	The role and the service account token file used by the *kubernetes* auth
+	method. The token defaults to the one mounted in the pod.
+
+*VAULT_CACERT* (_cacert_), *VAULT_SKIP_VERIFY* (_insecure_)
+	The CA certificate used to verify the server, or whether not to verify it.
+
 # AUTHOR
 
 Written by David J. Allen and maintained by the OpenCHAMI developers.
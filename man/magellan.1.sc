@@ -25,6 +25,8 @@ List of available commands:
 :  Send retrieve node data to specified host
 |  *list*
 :  Show nodes found from scan
+|  *idmap*
+:  Convert BMC ID maps to canonical YAML
 |  *secrets*
 :  Manage BMC credentials
 |  *update*
@@ -72,6 +74,16 @@ The *magellan* command accepts
 	- _error_
 	- _disabled_
 
+*--master-key-command* _command_
+	Read the master key of local secrets files from the output of a shell
+	command, e.g. 'pass show magellan/master-key', instead of the *MASTER_KEY*
+	environment variable. The command may prompt on the terminal.
+
+*--master-key-file* _path_
+	Read the master key of local secrets files from a file instead of the
+	*MASTER_KEY* environment variable. A warning is logged if the file can be
+	read by other users.
+
 *-t, --timeout* _time_in_secs_
 	Set the timeout for requests in seconds. This includes requests used in *scan*,
 	*crawl*, *collect*, and *send*. By default, the value of _time_in_secs_ is 5.
@@ -180,6 +192,7 @@ Written by David J. Allen and maintained by the OpenCHAMI developers.
 
 *magellan-scan*(1), *magellan-collect*(1), *magellan-crawl*(1),
 *magellan-list*(1), *magellan-secrets*(1), *magellan-update*(1)
-*magellan-send*(1)
+*magellan-send*(1), *magellan-logs*(1), *magellan-bios*(1),
+*magellan-idmap*(1)
 
 
@@ -0,0 +1,179 @@
+package bios
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/OpenCHAMI/magellan/pkg/power"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownModel is used to group nodes whose ComputerSystem does not report a model.
+const UnknownModel = "unknown"
+
+// NodeBios holds the BIOS attributes of a single node along with the details
+// used to group nodes together when comparing them.
+type NodeBios struct {
+	ClusterID         string         `json:"cluster_id" yaml:"cluster_id"`
+	Manufacturer      string         `json:"manufacturer,omitempty" yaml:"manufacturer,omitempty"`
+	Model             string         `json:"model,omitempty" yaml:"model,omitempty"`
+	BiosVersion       string         `json:"bios_version,omitempty" yaml:"bios_version,omitempty"`
+	AttributeRegistry string         `json:"attribute_registry,omitempty" yaml:"attribute_registry,omitempty"`
+	Attributes        map[string]any `json:"attributes" yaml:"attributes"`
+}
+
+// AttributeDiff is a single BIOS attribute whose value differs from the
+// reference. A nil value means the attribute is missing on that side.
+type AttributeDiff struct {
+	Attribute string `json:"attribute" yaml:"attribute"`
+	Expected  any    `json:"expected" yaml:"expected"`
+	Actual    any    `json:"actual" yaml:"actual"`
+}
+
+// NodeDiff holds all of the attributes of a node that differ from the reference.
+type NodeDiff struct {
+	ClusterID   string          `json:"cluster_id" yaml:"cluster_id"`
+	Differences []AttributeDiff `json:"differences" yaml:"differences"`
+}
+
+// GetNodeBios connects to the BMC of a node and retrieves the Bios resource of
+// its ComputerSystem.
+//
+// Parameters:
+//   - node: A CrawlableNode struct containing the node's xname, index within the BMC, and a CrawlerConfig to connect to the BMC.
+//
+// Returns:
+//   - *NodeBios: The BIOS attributes of the node.
+//   - error: An error object if the system or its Bios resource could not be retrieved.
+func GetNodeBios(node power.CrawlableNode) (*NodeBios, error) {
+	log.Debug().Msgf("getting BIOS attributes of %s from %s", node.ClusterID, node.ConnConfig.URI)
+
+	rf_system, err := power.GetComputerSystem(node)
+	if err != nil {
+		return nil, err
+	}
+	rf_bios, err := rf_system.Bios()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get BIOS of system %s: %w", rf_system.ID, err)
+	}
+	if rf_bios == nil {
+		return nil, fmt.Errorf("system %s does not have a Bios resource", rf_system.ID)
+	}
+
+	return &NodeBios{
+		ClusterID:         node.ClusterID,
+		Manufacturer:      rf_system.Manufacturer,
+		Model:             rf_system.Model,
+		BiosVersion:       rf_system.BiosVersion,
+		AttributeRegistry: rf_bios.AttributeRegistry,
+		Attributes:        rf_bios.Attributes,
+	}, nil
+}
+
+// LoadAttributesFile reads BIOS attributes from a YAML (or JSON) file, such as
+// a golden file to compare against or the attributes to set. The file can
+// either contain the attributes as a flat map, or a single node from the
+// output of 'bios get' with an 'attributes' key.
+func LoadAttributesFile(path string) (map[string]any, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attributes file: %w", err)
+	}
+	var attrs map[string]any
+	if err = yaml.Unmarshal(contents, &attrs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal attributes file: %w", err)
+	}
+	if nested, ok := attrs["attributes"].(map[string]any); ok {
+		return nested, nil
+	}
+	return attrs, nil
+}
+
+// Diff compares the BIOS attributes of each node against a reference set of
+// attributes and returns only the nodes that have differences, grouped by
+// node model. Attributes present on only one side are also reported.
+func Diff(reference map[string]any, nodes []NodeBios) map[string][]NodeDiff {
+	diffs := make(map[string][]NodeDiff)
+	for _, node := range nodes {
+		differences := compareAttributes(reference, node.Attributes, unionKeys(reference, node.Attributes))
+		if len(differences) == 0 {
+			continue
+		}
+		model := node.Model
+		if model == "" {
+			model = UnknownModel
+		}
+		diffs[model] = append(diffs[model], NodeDiff{
+			ClusterID:   node.ClusterID,
+			Differences: differences,
+		})
+	}
+	for model := range diffs {
+		sort.Slice(diffs[model], func(i, j int) bool {
+			return diffs[model][i].ClusterID < diffs[model][j].ClusterID
+		})
+	}
+	return diffs
+}
+
+// compareAttributes returns the differences between the expected and actual
+// attributes for each of the given attribute names.
+func compareAttributes(expected, actual map[string]any, names []string) []AttributeDiff {
+	var differences []AttributeDiff
+	for _, name := range names {
+		if !equalValues(expected[name], actual[name]) {
+			differences = append(differences, AttributeDiff{
+				Attribute: name,
+				Expected:  expected[name],
+				Actual:    actual[name],
+			})
+		}
+	}
+	return differences
+}
+
+// unionKeys returns the sorted set of keys found in either map.
+func unionKeys(a, b map[string]any) []string {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// equalValues compares two attribute values. Numbers are compared by value
+// since attributes decoded from JSON are always float64, while those decoded
+// from YAML golden files may be integers.
+func equalValues(a, b any) bool {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return fa == fb
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,72 @@
+package bios
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	golden := filepath.Join(dir, "golden.yaml")
+	require.NoError(t, os.WriteFile(golden, []byte(`
+cluster_id: x1000c0s0b0n0
+attributes:
+  BootMode: Uefi
+  ProcCStates: Disabled
+  MemFrequency: 3200
+`), 0o600))
+
+	reference, err := LoadAttributesFile(golden)
+	require.NoError(t, err)
+	require.Len(t, reference, 3)
+
+	// attributes decoded from Redfish JSON use float64 for numbers
+	diffs := Diff(reference, []NodeBios{
+		{
+			ClusterID: "x1000c0s0b0n1",
+			Model:     "R650",
+			Attributes: map[string]any{
+				"BootMode":     "Uefi",
+				"ProcCStates":  "Disabled",
+				"MemFrequency": 3200.0,
+			},
+		},
+		{
+			ClusterID: "x1000c0s0b1n0",
+			Model:     "R650",
+			Attributes: map[string]any{
+				"BootMode":     "Bios",
+				"ProcCStates":  "Disabled",
+				"MemFrequency": 3200.0,
+				"SriovGlobal":  "Enabled",
+			},
+		},
+		{
+			ClusterID: "x1000c0s0b2n0",
+			Attributes: map[string]any{
+				"BootMode":     "Uefi",
+				"MemFrequency": 2933.0,
+			},
+		},
+	})
+
+	// the identical node is left out and the others are grouped by model
+	require.Len(t, diffs, 2)
+	require.Len(t, diffs["R650"], 1)
+	require.Equal(t, "x1000c0s0b1n0", diffs["R650"][0].ClusterID)
+	require.Equal(t, []AttributeDiff{
+		{Attribute: "BootMode", Expected: "Uefi", Actual: "Bios"},
+		{Attribute: "SriovGlobal", Expected: nil, Actual: "Enabled"},
+	}, diffs["R650"][0].Differences)
+
+	require.Len(t, diffs[UnknownModel], 1)
+	require.Equal(t, []AttributeDiff{
+		{Attribute: "MemFrequency", Expected: 3200, Actual: 2933.0},
+		{Attribute: "ProcCStates", Expected: "Disabled", Actual: nil},
+	}, diffs[UnknownModel][0].Differences)
+}
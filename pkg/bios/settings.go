@@ -0,0 +1,116 @@
+package bios
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/pkg/power"
+	"github.com/rs/zerolog/log"
+	"github.com/stmcginnis/gofish/schemas"
+)
+
+// ParseApplyTime converts a case-insensitive apply time into a Redfish
+// settings apply time. 'MaintenanceWindow' is accepted as a shorthand for
+// 'AtMaintenanceWindowStart'.
+func ParseApplyTime(applyTime string) (schemas.SettingsApplyTime, error) {
+	for _, t := range []schemas.SettingsApplyTime{
+		schemas.ImmediateSettingsApplyTime,
+		schemas.OnResetSettingsApplyTime,
+		schemas.AtMaintenanceWindowStartSettingsApplyTime,
+		schemas.InMaintenanceWindowOnResetSettingsApplyTime,
+	} {
+		if strings.EqualFold(string(t), applyTime) {
+			return t, nil
+		}
+	}
+	if strings.EqualFold(applyTime, "MaintenanceWindow") {
+		return schemas.AtMaintenanceWindowStartSettingsApplyTime, nil
+	}
+	return "", fmt.Errorf("invalid apply time '%s' (must be one of OnReset, MaintenanceWindow or Immediate)", applyTime)
+}
+
+// SetNodeBios PATCHes the BIOS attributes of a node. The request is sent to the
+// settings object (usually Bios/Settings) advertised by the Bios resource, which
+// holds the values until they are applied at the requested time.
+//
+// Parameters:
+//   - node: A CrawlableNode struct containing the node's xname, index within the BMC, and a CrawlerConfig to connect to the BMC.
+//   - attrs: The BIOS attributes to set.
+//   - applyTime: When the BMC should apply the new values.
+//
+// Returns:
+//   - error: An error object if the apply time is not supported or the update failed.
+func SetNodeBios(node power.CrawlableNode, attrs map[string]any, applyTime schemas.SettingsApplyTime) error {
+	log.Debug().Msgf("setting %d BIOS attributes of %s to apply at %s", len(attrs), node.ClusterID, applyTime)
+
+	rf_system, err := power.GetComputerSystem(node)
+	if err != nil {
+		return err
+	}
+	rf_bios, err := rf_system.Bios()
+	if err != nil {
+		return fmt.Errorf("failed to get BIOS of system %s: %w", rf_system.ID, err)
+	}
+	if rf_bios == nil {
+		return fmt.Errorf("system %s does not have a Bios resource", rf_system.ID)
+	}
+	if allowed := rf_bios.AllowedAttributeUpdateApplyTimes(); !slices.Contains(allowed, applyTime) {
+		return fmt.Errorf("apply time %s is not supported by system %s (supported: %v)", applyTime, rf_system.ID, allowed)
+	}
+	if err = rf_bios.UpdateBiosAttributesApplyAt(attrs, applyTime); err != nil {
+		return fmt.Errorf("failed to update BIOS attributes of system %s: %w", rf_system.ID, err)
+	}
+	return nil
+}
+
+// VerifyNodeBios compares the current BIOS attributes of a node against the
+// expected ones. Unlike Diff, only the expected attributes are compared.
+//
+// Returns:
+//   - []AttributeDiff: The expected attributes that do not (yet) have the expected value.
+//   - error: An error object if the BIOS attributes could not be retrieved.
+func VerifyNodeBios(node power.CrawlableNode, expected map[string]any) ([]AttributeDiff, error) {
+	current, err := GetNodeBios(node)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return compareAttributes(expected, current.Attributes, names), nil
+}
+
+// WaitForNodeBios polls the BIOS attributes of a node until all of the expected
+// attributes have been applied or the timeout expires. Errors while polling are
+// expected while the node reboots and are only logged.
+//
+// Returns:
+//   - []AttributeDiff: The attributes that were still not applied when the timeout expired.
+//   - error: An error object if the attributes could not be retrieved before the timeout.
+func WaitForNodeBios(node power.CrawlableNode, expected map[string]any, timeout time.Duration, interval time.Duration) ([]AttributeDiff, error) {
+	var (
+		deadline    = time.Now().Add(timeout)
+		differences []AttributeDiff
+		err         error
+	)
+	for {
+		differences, err = VerifyNodeBios(node, expected)
+		if err == nil && len(differences) == 0 {
+			return nil, nil
+		}
+		if err != nil {
+			log.Debug().Err(err).Msgf("failed to verify BIOS attributes of %s, retrying", node.ClusterID)
+		} else {
+			log.Debug().Msgf("%d BIOS attributes of %s not applied yet", len(differences), node.ClusterID)
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return differences, err
+		}
+		time.Sleep(interval)
+	}
+}
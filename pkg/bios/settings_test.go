@@ -0,0 +1,120 @@
+package bios
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
+	"github.com/OpenCHAMI/magellan/pkg/power"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/stmcginnis/gofish/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseApplyTime(t *testing.T) {
+	t.Parallel()
+
+	for input, expected := range map[string]schemas.SettingsApplyTime{
+		"OnReset":           schemas.OnResetSettingsApplyTime,
+		"immediate":         schemas.ImmediateSettingsApplyTime,
+		"MaintenanceWindow": schemas.AtMaintenanceWindowStartSettingsApplyTime,
+	} {
+		applyTime, err := ParseApplyTime(input)
+		require.NoError(t, err)
+		require.Equal(t, expected, applyTime)
+	}
+	_, err := ParseApplyTime("later")
+	require.Error(t, err)
+}
+
+func TestSetNodeBios(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu         sync.Mutex
+		attributes = map[string]any{"BootMode": "Bios", "ProcCStates": "Enabled"}
+		patched    map[string]any
+	)
+	serveBios := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := json.Marshal(map[string]any{
+			"@odata.id":  "/redfish/v1/Systems/Node0/Bios",
+			"Id":         "Bios",
+			"Attributes": attributes,
+			"@Redfish.Settings": map[string]any{
+				"SettingsObject":      map[string]string{"@odata.id": "/redfish/v1/Systems/Node0/Bios/Settings"},
+				"SupportedApplyTimes": []string{"OnReset", "Immediate"},
+			},
+		})
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redfish/v1/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"@odata.id": "/redfish/v1/", "Systems": {"@odata.id": "/redfish/v1/Systems"}}`))
+	})
+	mux.HandleFunc("/redfish/v1/Systems", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Members": [{"@odata.id": "/redfish/v1/Systems/Node0"}]}`))
+	})
+	mux.HandleFunc("/redfish/v1/Systems/Node0", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"@odata.id": "/redfish/v1/Systems/Node0", "Id": "Node0", "Bios": {"@odata.id": "/redfish/v1/Systems/Node0/Bios"}}`))
+	})
+	mux.HandleFunc("/redfish/v1/Systems/Node0/Bios", serveBios)
+	mux.HandleFunc("/redfish/v1/Systems/Node0/Bios/Settings", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			_, _ = w.Write([]byte(`{"@odata.id": "/redfish/v1/Systems/Node0/Bios/Settings", "Attributes": {}}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		_ = json.Unmarshal(body, &patched)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	defer power.LogoutBMCSessions()
+
+	node := power.CrawlableNode{
+		ClusterID: "x1000c0s0b0n0",
+		NodeID:    "Node0",
+		ConnConfig: crawler.CrawlerConfig{
+			URI:             server.URL,
+			CredentialStore: secrets.NewStaticStore("user", "pass"),
+		},
+	}
+	expected := map[string]any{"BootMode": "Uefi"}
+
+	// unsupported apply times are rejected before anything is sent
+	err := SetNodeBios(node, expected, schemas.AtMaintenanceWindowStartSettingsApplyTime)
+	require.Error(t, err)
+
+	// the new values are sent to the settings object with the apply time
+	require.NoError(t, SetNodeBios(node, expected, schemas.OnResetSettingsApplyTime))
+	mu.Lock()
+	require.Equal(t, map[string]any{"BootMode": "Uefi"}, patched["Attributes"])
+	require.Equal(t, map[string]any{"ApplyTime": "OnReset"}, patched["@Redfish.SettingsApplyTime"])
+	mu.Unlock()
+
+	// the values are still pending until the node is reset
+	differences, err := VerifyNodeBios(node, expected)
+	require.NoError(t, err)
+	require.Equal(t, []AttributeDiff{{Attribute: "BootMode", Expected: "Uefi", Actual: "Bios"}}, differences)
+
+	// simulate the reset applying the pending values
+	mu.Lock()
+	attributes["BootMode"] = "Uefi"
+	mu.Unlock()
+	differences, err = WaitForNodeBios(node, expected, time.Second, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.Empty(t, differences)
+}
@@ -12,9 +12,52 @@ type BMCCredentials struct {
 	Password string `json:"password"`
 }
 type Node struct {
-	ClusterID string `yaml:"cluster_id"`
-	BmcIP     string `yaml:"bmc_ip"`
-	NodeID    string `yaml:"node_id"`
+	ClusterID    string `yaml:"cluster_id"`
+	BmcIP        string `yaml:"bmc_ip"`
+	NodeID       string `yaml:"node_id"`
+	UUID         string `yaml:"uuid,omitempty"`
+	SerialNumber string `yaml:"serial,omitempty"`
+	SystemURI    string `yaml:"system_uri,omitempty"`
+}
+
+// bmcSecret is a secret holding BMC credentials, either a single username
+// and password or an ordered list of candidates to try, e.g. the factory
+// default passwords that new hardware may ship with:
+//
+//	{"candidates": [{"username": "ADMIN", "password": "ADMIN"}, {"username": "root", "password": "calvin"}]}
+type bmcSecret struct {
+	BMCCredentials
+	Candidates []BMCCredentials `json:"candidates,omitempty"`
+}
+
+// ParseBMCCredentialCandidates parses a secret holding either a single
+// username and password or a list of candidate credentials. A username or
+// password next to the candidates replaces those of every candidate, which
+// is how --username and --password override them.
+//
+// Parameters:
+//   - secret: The JSON value of the secret.
+//
+// Returns:
+//   - []BMCCredentials: The credentials to try, in order. There is always at least one.
+//   - error: An error if the secret is not valid JSON.
+func ParseBMCCredentialCandidates(secret string) ([]BMCCredentials, error) {
+	var parsed bmcSecret
+	if err := json.Unmarshal([]byte(secret), &parsed); err != nil {
+		return nil, err
+	}
+	if len(parsed.Candidates) == 0 {
+		return []BMCCredentials{parsed.BMCCredentials}, nil
+	}
+	for i := range parsed.Candidates {
+		if parsed.Username != "" {
+			parsed.Candidates[i].Username = parsed.Username
+		}
+		if parsed.Password != "" {
+			parsed.Candidates[i].Password = parsed.Password
+		}
+	}
+	return parsed.Candidates, nil
 }
 
 func GetBMCCredentialsDefault(store secrets.SecretStore) (BMCCredentials, error) {
@@ -25,11 +68,12 @@ func GetBMCCredentialsDefault(store secrets.SecretStore) (BMCCredentials, error)
 	if strCreds, err := store.GetSecretByID(secrets.DEFAULT_KEY); err != nil {
 		return creds, fmt.Errorf("get default BMC credentials from secret store: %w", err)
 	} else {
-		// Default URI credentials found, use them.
-		if err = json.Unmarshal([]byte(strCreds), &creds); err != nil {
+		// Default URI credentials found, use the first candidate.
+		candidates, err := ParseBMCCredentialCandidates(strCreds)
+		if err != nil {
 			return creds, fmt.Errorf("get default BMC credentials from secret store: failed to unmarshal: %w", err)
 		}
-		return creds, nil
+		return candidates[0], nil
 	}
 }
 
@@ -41,36 +85,31 @@ func GetBMCCredentials(store secrets.SecretStore, id string) (BMCCredentials, er
 	if strCreds, err := store.GetSecretByID(id); err != nil {
 		return creds, fmt.Errorf("get BMC credentials from secret store: %w", err)
 	} else {
-		// Specific URI credentials found, use them.
-		if err = json.Unmarshal([]byte(strCreds), &creds); err != nil {
+		// Specific URI credentials found, use the first candidate.
+		candidates, err := ParseBMCCredentialCandidates(strCreds)
+		if err != nil {
 			return creds, fmt.Errorf("get BMC credentials from secret store: failed to unmarshal: %w", err)
 		}
+		creds = candidates[0]
 	}
 
 	return creds, nil
 }
 
+// GetBMCCredentialsOrDefault gets the credentials of the BMC with the
+// given ID from the secret ID that matches it best (see MatchSecretID),
+// without trying vendor selectors. The credentials are blank if no secret
+// ID matches.
 func GetBMCCredentialsOrDefault(store secrets.SecretStore, id string) BMCCredentials {
-	var (
-		creds BMCCredentials
-		err   error
-	)
-
 	if id == "" {
-		return creds
+		return BMCCredentials{}
 	}
 
 	if id == secrets.DEFAULT_KEY {
-		creds, _ = GetBMCCredentialsDefault(store)
+		creds, _ := GetBMCCredentialsDefault(store)
 		return creds
 	}
 
-	if creds, err = GetBMCCredentials(store, id); err != nil {
-		if defaultSecret, err := GetBMCCredentialsDefault(store); err == nil {
-			// Default credentials found, use them.
-			creds = defaultSecret
-		}
-	}
-
+	creds, _, _ := GetMatchingBMCCredentials(store, id, nil)
 	return creds
 }
@@ -0,0 +1,375 @@
+package bmc
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/rs/zerolog/log"
+)
+
+// VendorSelectorPrefix starts the secret IDs that select BMCs by the vendor
+// reported in their Redfish service root, e.g. 'vendor:HPE'.
+const VendorSelectorPrefix = "vendor:"
+
+// MatchRule is the kind of secret ID that the credentials of a BMC were
+// found under. The rules are tried in the order they are declared in.
+type MatchRule string
+
+const (
+	// RuleExact matches the secret ID that is exactly the BMC URI as
+	// shown by 'magellan list', e.g. 'https://172.16.0.105:443'.
+	RuleExact MatchRule = "exact"
+	// RuleHost matches the secret ID that is exactly the hostname or IP
+	// address of the BMC, e.g. 'x3000c0s1b0' or '172.16.0.105'.
+	RuleHost MatchRule = "host"
+	// RuleGlob matches secret IDs that are shell globs for the hostname
+	// or IP address of the BMC, e.g. 'x3000c0s*b0' or '172.16.0.1??'.
+	// The glob with the most characters that aren't wildcards wins.
+	RuleGlob MatchRule = "glob"
+	// RuleCIDR matches secret IDs that are subnets holding the IP address
+	// of the BMC, e.g. '172.16.0.0/24'. The longest prefix wins.
+	RuleCIDR MatchRule = "cidr"
+	// RuleVendor matches secret IDs that are a VendorSelectorPrefix
+	// followed by a case-insensitive glob for the vendor of the BMC.
+	RuleVendor MatchRule = "vendor"
+	// RuleDefault matches the 'default' secret ID.
+	RuleDefault MatchRule = "default"
+)
+
+// CredentialMatch is the secret ID that the credentials of a BMC were
+// found under and the rule that selected it.
+type CredentialMatch struct {
+	SecretID string
+	Rule     MatchRule
+}
+
+// VendorFunc returns the vendor of a BMC. It is only called when the
+// secret store has vendor selectors that need to be tried.
+type VendorFunc func() string
+
+// candidate is a secret ID that matches a BMC and how specific it is.
+type candidate struct {
+	secretID    string
+	specificity int
+}
+
+// bestCandidate returns the secret ID of the most specific candidate,
+// picking the first in lexical order among equally specific ones.
+func bestCandidate(candidates []candidate) string {
+	return slices.MinFunc(candidates, func(a, b candidate) int {
+		if a.specificity != b.specificity {
+			return b.specificity - a.specificity
+		}
+		return strings.Compare(a.secretID, b.secretID)
+	}).secretID
+}
+
+// isGlob reports whether a secret ID contains shell glob wildcards.
+func isGlob(secretID string) bool {
+	return strings.ContainsAny(secretID, "*?[")
+}
+
+// globSpecificity is the number of characters in a glob that aren't
+// wildcards.
+func globSpecificity(pattern string) int {
+	return len(pattern) - strings.Count(pattern, "*") - strings.Count(pattern, "?")
+}
+
+// HostFromURI returns the hostname or IP address in a BMC URI, which may
+// also be given without a scheme. It is the secret ID that RuleHost matches
+// for the BMC with any scheme or port.
+func HostFromURI(uri string) string {
+	if parsed, err := url.Parse(uri); err == nil && parsed.Host != "" {
+		return strings.ToLower(parsed.Hostname())
+	}
+	if host, _, err := net.SplitHostPort(uri); err == nil {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(uri)
+}
+
+// secretIndex holds the secret IDs of a store sorted into the rules that
+// MatchSecretID tries, with their globs and CIDRs parsed, so that they can
+// be matched against many BMCs without listing and parsing them again.
+type secretIndex struct {
+	ids        map[string]bool
+	hosts      map[string]string // lower case hostname or IP address -> secret ID
+	globs      []indexedGlob
+	cidrs      []indexedCIDR
+	selectors  []indexedGlob // vendor globs without VendorSelectorPrefix
+	hasDefault bool
+}
+
+// indexedGlob is a secret ID with its lower case glob.
+type indexedGlob struct {
+	secretID string
+	pattern  string
+}
+
+// indexedCIDR is a secret ID with the subnet it holds.
+type indexedCIDR struct {
+	secretID string
+	subnet   *net.IPNet
+}
+
+// newSecretIndex sorts secret IDs into the rules that match them.
+func newSecretIndex(secretIDs map[string]bool) *secretIndex {
+	index := &secretIndex{ids: secretIDs, hosts: map[string]string{}}
+	for secretID := range secretIDs {
+		switch {
+		case secretID == secrets.DEFAULT_KEY:
+			index.hasDefault = true
+		case strings.HasPrefix(secretID, VendorSelectorPrefix):
+			pattern := strings.ToLower(strings.TrimPrefix(secretID, VendorSelectorPrefix))
+			index.selectors = append(index.selectors, indexedGlob{secretID, pattern})
+		case isGlob(secretID):
+			index.globs = append(index.globs, indexedGlob{secretID, strings.ToLower(secretID)})
+		case strings.Contains(secretID, "/"):
+			if _, subnet, err := net.ParseCIDR(secretID); err == nil {
+				index.cidrs = append(index.cidrs, indexedCIDR{secretID, subnet})
+			}
+		default:
+			// of IDs that only differ in case, pick the same one
+			// every time
+			host := strings.ToLower(secretID)
+			if other, ok := index.hosts[host]; !ok || secretID < other {
+				index.hosts[host] = secretID
+			}
+		}
+	}
+	return index
+}
+
+// listSecretIndex lists the secret IDs of a store into a secretIndex.
+func listSecretIndex(store secrets.SecretStore) (*secretIndex, error) {
+	listed, err := store.ListSecrets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	secretIDs := make(map[string]bool, len(listed))
+	for secretID := range listed {
+		secretIDs[secretID] = true
+	}
+	return newSecretIndex(secretIDs), nil
+}
+
+// match finds the secret ID of the BMC at uri in the order documented by
+// MatchSecretID.
+func (index *secretIndex) match(uri string, vendor VendorFunc) (CredentialMatch, error) {
+	if index.ids[uri] {
+		return CredentialMatch{SecretID: uri, Rule: RuleExact}, nil
+	}
+	host := HostFromURI(uri)
+	if secretID, ok := index.hosts[host]; ok {
+		return CredentialMatch{SecretID: secretID, Rule: RuleHost}, nil
+	}
+
+	var globs []candidate
+	for _, glob := range index.globs {
+		if matched, _ := path.Match(glob.pattern, host); matched {
+			globs = append(globs, candidate{glob.secretID, globSpecificity(glob.secretID)})
+		}
+	}
+	if len(globs) > 0 {
+		return CredentialMatch{SecretID: bestCandidate(globs), Rule: RuleGlob}, nil
+	}
+
+	var cidrs []candidate
+	if ip := net.ParseIP(host); ip != nil {
+		for _, cidr := range index.cidrs {
+			if cidr.subnet.Contains(ip) {
+				ones, _ := cidr.subnet.Mask.Size()
+				cidrs = append(cidrs, candidate{cidr.secretID, ones})
+			}
+		}
+	}
+	if len(cidrs) > 0 {
+		return CredentialMatch{SecretID: bestCandidate(cidrs), Rule: RuleCIDR}, nil
+	}
+
+	if len(index.selectors) > 0 && vendor != nil {
+		if name := strings.ToLower(vendor()); name != "" {
+			var matches []candidate
+			for _, selector := range index.selectors {
+				if matched, _ := path.Match(selector.pattern, name); matched {
+					matches = append(matches, candidate{selector.secretID, globSpecificity(selector.pattern)})
+				}
+			}
+			if len(matches) > 0 {
+				return CredentialMatch{SecretID: bestCandidate(matches), Rule: RuleVendor}, nil
+			}
+		}
+	}
+	if index.hasDefault {
+		return CredentialMatch{SecretID: secrets.DEFAULT_KEY, Rule: RuleDefault}, nil
+	}
+	return CredentialMatch{}, errors.New("no secret ID matches BMC and no default credentials are set")
+}
+
+// SecretMatcher is a secret store that lists and sorts its secret IDs once,
+// so that the credentials of many BMCs are matched without listing them
+// again for each BMC, e.g. from Vault. It keeps up with the secrets stored
+// or removed through it, but not with changes made to the store otherwise,
+// so it is meant to be built when a command starts. MatchSecretID uses it
+// when it is given one as the store.
+type SecretMatcher struct {
+	secrets.SecretStore
+
+	mu    sync.RWMutex
+	index *secretIndex // replaced rather than changed, so it can be read without the lock
+}
+
+// NewSecretMatcher lists the secret IDs of a store into a SecretMatcher.
+//
+// Parameters:
+//   - store: The secret store to match the secret IDs of.
+//
+// Returns:
+//   - *SecretMatcher: The secret store that matches BMCs to its secret IDs.
+//   - error: An error if the secret IDs of the store could not be listed.
+func NewSecretMatcher(store secrets.SecretStore) (*SecretMatcher, error) {
+	if store == nil {
+		return nil, fmt.Errorf("invalid secrets store")
+	}
+	index, err := listSecretIndex(store)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretMatcher{SecretStore: store, index: index}, nil
+}
+
+// Match finds the secret ID that holds the credentials of the BMC at uri,
+// like MatchSecretID.
+func (matcher *SecretMatcher) Match(uri string, vendor VendorFunc) (CredentialMatch, error) {
+	matcher.mu.RLock()
+	index := matcher.index
+	matcher.mu.RUnlock()
+	return index.match(uri, vendor)
+}
+
+// update sorts the secret IDs again after one was stored or removed.
+func (matcher *SecretMatcher) update(secretID string, stored bool) {
+	matcher.mu.Lock()
+	defer matcher.mu.Unlock()
+	secretIDs := maps.Clone(matcher.index.ids)
+	if stored {
+		secretIDs[secretID] = true
+	} else {
+		delete(secretIDs, secretID)
+	}
+	matcher.index = newSecretIndex(secretIDs)
+}
+
+func (matcher *SecretMatcher) StoreSecretByID(secretID, secret string) error {
+	if err := matcher.SecretStore.StoreSecretByID(secretID, secret); err != nil {
+		return err
+	}
+	matcher.update(secretID, true)
+	return nil
+}
+
+func (matcher *SecretMatcher) RemoveSecretByID(secretID string) error {
+	if err := matcher.SecretStore.RemoveSecretByID(secretID); err != nil {
+		return err
+	}
+	matcher.update(secretID, false)
+	return nil
+}
+
+// MatchSecretID finds the secret in a store that holds the credentials of
+// the BMC at uri. The secret IDs are tried in this order:
+//
+//  1. the BMC URI itself
+//  2. the hostname or IP address of the BMC
+//  3. hostname globs, the most specific first
+//  4. CIDRs holding the IP address of the BMC, the longest prefix first
+//  5. vendor selectors matching the vendor returned by vendor, if not nil
+//  6. 'default'
+//
+// The secret IDs are listed for every call unless store is a SecretMatcher,
+// which should be used to match many BMCs.
+//
+// Parameters:
+//   - store: The secret store to search.
+//   - uri: The URI of the BMC, e.g. 'https://172.16.0.105:443'.
+//   - vendor: Returns the vendor of the BMC for vendor selectors, or nil to skip them.
+//
+// Returns:
+//   - CredentialMatch: The matching secret ID and the rule that selected it.
+//   - error: An error if no secret ID matches the BMC or the store could not be read.
+func MatchSecretID(store secrets.SecretStore, uri string, vendor VendorFunc) (CredentialMatch, error) {
+	if store == nil {
+		return CredentialMatch{}, fmt.Errorf("invalid secrets store")
+	}
+	if matcher, ok := store.(*SecretMatcher); ok {
+		return matcher.Match(uri, vendor)
+	}
+	if _, err := store.GetSecretByID(uri); err == nil {
+		return CredentialMatch{SecretID: uri, Rule: RuleExact}, nil
+	}
+	index, err := listSecretIndex(store)
+	if err != nil {
+		return CredentialMatch{}, err
+	}
+	return index.match(uri, vendor)
+}
+
+// GetMatchingBMCCredentials gets the credentials of the BMC at uri from the
+// secret ID selected by MatchSecretID, and logs which rule selected it. If
+// the secret holds a list of candidates, the first one is returned.
+//
+// Parameters:
+//   - store: The secret store to search.
+//   - uri: The URI of the BMC, e.g. 'https://172.16.0.105:443'.
+//   - vendor: Returns the vendor of the BMC for vendor selectors, or nil to skip them.
+//
+// Returns:
+//   - BMCCredentials: The credentials of the BMC.
+//   - CredentialMatch: The matching secret ID and the rule that selected it.
+//   - error: An error if no secret ID matches the BMC or its credentials could not be read.
+func GetMatchingBMCCredentials(store secrets.SecretStore, uri string, vendor VendorFunc) (BMCCredentials, CredentialMatch, error) {
+	candidates, match, err := GetMatchingBMCCredentialCandidates(store, uri, vendor)
+	if err != nil {
+		return BMCCredentials{}, match, err
+	}
+	return candidates[0], match, nil
+}
+
+// GetMatchingBMCCredentialCandidates gets the candidate credentials of the
+// BMC at uri from the secret ID selected by MatchSecretID, and logs which
+// rule selected it.
+//
+// Parameters:
+//   - store: The secret store to search.
+//   - uri: The URI of the BMC, e.g. 'https://172.16.0.105:443'.
+//   - vendor: Returns the vendor of the BMC for vendor selectors, or nil to skip them.
+//
+// Returns:
+//   - []BMCCredentials: The credentials to try, in order. There is at least one unless there is an error.
+//   - CredentialMatch: The matching secret ID and the rule that selected it.
+//   - error: An error if no secret ID matches the BMC or its credentials could not be read.
+func GetMatchingBMCCredentialCandidates(store secrets.SecretStore, uri string, vendor VendorFunc) ([]BMCCredentials, CredentialMatch, error) {
+	match, err := MatchSecretID(store, uri, vendor)
+	if err != nil {
+		return nil, match, fmt.Errorf("get BMC credentials from secret store: %w", err)
+	}
+	log.Debug().Str("id", uri).Str("secret ID", match.SecretID).Str("rule", string(match.Rule)).Msg("matched BMC credentials")
+
+	strCreds, err := store.GetSecretByID(match.SecretID)
+	if err != nil {
+		return nil, match, fmt.Errorf("get BMC credentials from secret store: %w", err)
+	}
+	candidates, err := ParseBMCCredentialCandidates(strCreds)
+	if err != nil {
+		return nil, match, fmt.Errorf("get BMC credentials from secret store: failed to unmarshal '%s': %w", match.SecretID, err)
+	}
+	return candidates, match, nil
+}
@@ -0,0 +1,178 @@
+package bmc
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+)
+
+func TestMatchSecretID(t *testing.T) {
+	masterKey, err := secrets.GenerateMasterKey()
+	if err != nil {
+		t.Fatalf("Failed to generate master key: %v", err)
+	}
+	store, err := secrets.NewLocalSecretStore(masterKey, filepath.Join(t.TempDir(), "secrets.json"), true)
+	if err != nil {
+		t.Fatalf("Failed to create LocalSecretStore: %v", err)
+	}
+	for _, secretID := range []string{
+		"https://172.16.0.105:443",
+		"172.16.0.106",
+		"x3000c0s*b0",
+		"x3000c0s1*b0",
+		"172.16.0.0/16",
+		"172.16.1.0/24",
+		"vendor:hpe",
+		"vendor:super*",
+		secrets.DEFAULT_KEY,
+	} {
+		creds := fmt.Sprintf(`{"username":"root","password":"%s"}`, secretID)
+		if err := store.StoreSecretByID(secretID, creds); err != nil {
+			t.Fatalf("Failed to store secret %s: %v", secretID, err)
+		}
+	}
+
+	tests := []struct {
+		uri    string
+		vendor string
+		want   CredentialMatch
+	}{
+		{"https://172.16.0.105:443", "HPE", CredentialMatch{"https://172.16.0.105:443", RuleExact}},
+		{"https://172.16.0.106:443", "", CredentialMatch{"172.16.0.106", RuleHost}},
+		{"https://x3000c0s1b0", "", CredentialMatch{"x3000c0s1*b0", RuleGlob}},
+		{"https://x3000c0s2b0:443", "HPE", CredentialMatch{"x3000c0s*b0", RuleGlob}},
+		{"https://172.16.1.7", "HPE", CredentialMatch{"172.16.1.0/24", RuleCIDR}},
+		{"https://172.16.2.7", "", CredentialMatch{"172.16.0.0/16", RuleCIDR}},
+		{"https://bmc1.example.com", "HPE", CredentialMatch{"vendor:hpe", RuleVendor}},
+		{"https://bmc2.example.com", "Supermicro", CredentialMatch{"vendor:super*", RuleVendor}},
+		{"https://bmc3.example.com", "Dell", CredentialMatch{secrets.DEFAULT_KEY, RuleDefault}},
+		{"https://bmc4.example.com", "", CredentialMatch{secrets.DEFAULT_KEY, RuleDefault}},
+	}
+	for _, test := range tests {
+		called := false
+		vendor := func() string {
+			called = true
+			return test.vendor
+		}
+		creds, match, err := GetMatchingBMCCredentials(store, test.uri, vendor)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.uri, err)
+			continue
+		}
+		if match != test.want {
+			t.Errorf("%s: expected %v, got %v", test.uri, test.want, match)
+		}
+		if creds.Password != test.want.SecretID {
+			t.Errorf("%s: expected credentials of %s, got those of %s", test.uri, test.want.SecretID, creds.Password)
+		}
+		if called && test.want.Rule != RuleVendor && test.want.Rule != RuleDefault {
+			t.Errorf("%s: vendor looked up although rule %s matched first", test.uri, test.want.Rule)
+		}
+	}
+
+	if err := store.RemoveSecretByID(secrets.DEFAULT_KEY); err != nil {
+		t.Fatalf("Failed to remove default secret: %v", err)
+	}
+	if _, err := MatchSecretID(store, "https://bmc3.example.com", nil); err == nil {
+		t.Errorf("expected an error without a matching secret ID")
+	}
+}
+
+func TestParseBMCCredentialCandidates(t *testing.T) {
+	tests := []struct {
+		secret string
+		want   []BMCCredentials
+	}{
+		{`{"username":"root","password":"calvin"}`, []BMCCredentials{{"root", "calvin"}}},
+		{`{"candidates":[{"username":"ADMIN","password":"ADMIN"},{"username":"root","password":"calvin"}]}`, []BMCCredentials{{"ADMIN", "ADMIN"}, {"root", "calvin"}}},
+		// --password overrides the password of every candidate
+		{`{"password":"secret","candidates":[{"username":"ADMIN","password":"ADMIN"},{"username":"root","password":"calvin"}]}`, []BMCCredentials{{"ADMIN", "secret"}, {"root", "secret"}}},
+	}
+	for _, test := range tests {
+		candidates, err := ParseBMCCredentialCandidates(test.secret)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.secret, err)
+			continue
+		}
+		if !slices.Equal(candidates, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.secret, test.want, candidates)
+		}
+	}
+	if _, err := ParseBMCCredentialCandidates(`[`); err == nil {
+		t.Errorf("expected an error for invalid JSON")
+	}
+}
+
+// countingStore counts the secret IDs listings of a secret store.
+type countingStore struct {
+	secrets.SecretStore
+	lists int
+}
+
+func (store *countingStore) ListSecrets() (map[string]string, error) {
+	store.lists++
+	return store.SecretStore.ListSecrets()
+}
+
+func TestSecretMatcher(t *testing.T) {
+	masterKey, err := secrets.GenerateMasterKey()
+	if err != nil {
+		t.Fatalf("Failed to generate master key: %v", err)
+	}
+	local, err := secrets.NewLocalSecretStore(masterKey, filepath.Join(t.TempDir(), "secrets.json"), true)
+	if err != nil {
+		t.Fatalf("Failed to create LocalSecretStore: %v", err)
+	}
+	for _, secretID := range []string{"X3000C0S1B0", "x3000c0s*b0", "172.16.0.0/16", "vendor:hpe", secrets.DEFAULT_KEY} {
+		if err := local.StoreSecretByID(secretID, `{"username":"root","password":"calvin"}`); err != nil {
+			t.Fatalf("Failed to store secret %s: %v", secretID, err)
+		}
+	}
+	store := &countingStore{SecretStore: local}
+	matcher, err := NewSecretMatcher(store)
+	if err != nil {
+		t.Fatalf("Failed to create SecretMatcher: %v", err)
+	}
+
+	// the secret IDs are listed once for any number of BMCs
+	hpe := func() string { return "HPE" }
+	tests := []struct {
+		uri  string
+		want CredentialMatch
+	}{
+		{"https://x3000c0s1b0:443", CredentialMatch{"X3000C0S1B0", RuleHost}},
+		{"https://x3000c0s2b0", CredentialMatch{"x3000c0s*b0", RuleGlob}},
+		{"https://172.16.4.2", CredentialMatch{"172.16.0.0/16", RuleCIDR}},
+		{"https://bmc1.example.com", CredentialMatch{"vendor:hpe", RuleVendor}},
+	}
+	for i := 0; i < 3; i++ {
+		for _, test := range tests {
+			if match, err := MatchSecretID(matcher, test.uri, hpe); err != nil || match != test.want {
+				t.Errorf("%s: expected %v, got %v (%v)", test.uri, test.want, match, err)
+			}
+		}
+	}
+	if store.lists != 1 {
+		t.Errorf("Expected the secret IDs to be listed once, listed %d times", store.lists)
+	}
+
+	// secrets stored or removed through the matcher are matched from then on
+	if err := matcher.StoreSecretByID("https://bmc1.example.com", `{"username":"root","password":"hunter2"}`); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+	if match, _ := matcher.Match("https://bmc1.example.com", hpe); match.Rule != RuleExact {
+		t.Errorf("Expected a stored secret to match exactly, got %v", match)
+	}
+	if err := matcher.RemoveSecretByID("vendor:hpe"); err != nil {
+		t.Fatalf("Failed to remove secret: %v", err)
+	}
+	if match, _ := matcher.Match("https://bmc2.example.com", hpe); match.Rule != RuleDefault {
+		t.Errorf("Expected a removed secret to no longer match, got %v", match)
+	}
+	if store.lists != 1 {
+		t.Errorf("Expected the secret IDs to be listed once, listed %d times", store.lists)
+	}
+}
@@ -20,7 +20,6 @@ import (
 
 	"github.com/rs/zerolog/log"
 
-	"github.com/stmcginnis/gofish"
 	"github.com/stmcginnis/gofish/schemas"
 	"golang.org/x/exp/slices"
 )
@@ -36,7 +35,16 @@ type CollectParams struct {
 	OutputFormat format.DataFormat   // set the output format
 	InputFormat  format.DataFormat   // set the input format
 	BMCIDMap     string              // Set the path to the BMC ID mapping YAML or JSON data or file name (if any)
+	IDTemplate   string              // set the Go template to build BMC IDs from with the 'id-template' flag
+	IDLocation   string              // set how to build BMC IDs from the chassis location ('xname' or a Go template) with the 'id-from-location' flag
+	IDMappers    []string            // set the order of the BMC ID mappers to try with the 'id-mappers' flag
+	IDReportPath string              // set the path to write the BMC ID report to with the 'id-report' flag ('-' for stderr)
 	SecretStore  secrets.SecretStore // set BMC credentials
+
+	WriteBackCredentials bool // set whether to store the candidate credentials that authenticated under the BMC URI with the 'write-back-creds' flag
+
+	CollectSensors    bool   // set whether to also collect a sensor snapshot with the '--sensors' flag
+	SensorsOutputPath string // set the path to save the sensor snapshot with the '--sensors-file' flag
 }
 
 // This is the main function used to collect information from the BMC nodes via Redfish.
@@ -57,14 +65,30 @@ func CollectInventory(assets *[]RemoteAsset, params *CollectParams) ([]map[strin
 	// collect bmc information asynchronously
 	var (
 		wg         sync.WaitGroup
+		mu         sync.Mutex
 		collection = make([]map[string]any, 0)
+		sensors    = make([]map[string]any, 0)
 		found      = make([]string, 0, len(*assets))
 		done       = make(chan struct{}, params.Concurrency+1)
 		chanAssets = make(chan RemoteAsset, params.Concurrency+1)
-		mapper     = idmap.PickIDMapper(params.BMCIDMap, params.OutputFormat)
 		err        error
 	)
 
+	// pick the BMC ID mappers once, so that duplicate IDs are found
+	// across all of the BMCs
+	mapper, err := idmap.PickIDMapper(idmap.MapperConfig{
+		BMCIDMap:    params.BMCIDMap,
+		IDMapFormat: params.OutputFormat,
+		IDTemplate:  params.IDTemplate,
+		IDLocation:  params.IDLocation,
+		Chain:       params.IDMappers,
+	})
+	if err != nil {
+		// every BMC would be skipped, so don't contact any of them
+		return nil, err
+	}
+	lookupHostname := mapper.UsesKey(idmap.MapKeyHostname)
+
 	// set the client's params from CLI
 	wg.Add(params.Concurrency)
 	for i := 0; i < params.Concurrency; i++ {
@@ -76,28 +100,25 @@ func CollectInventory(assets *[]RemoteAsset, params *CollectParams) ([]map[strin
 					return
 				}
 
-				trimmedHost := strings.TrimPrefix(sr.Host, "https://")
-				uri := fmt.Sprintf("%s:%d", sr.Host, sr.Port)
-				// resolve the hostname if it exists
+				var (
+					trimmedHost = strings.TrimPrefix(sr.Host, "https://")
+					hostname    string
+					uri         = fmt.Sprintf("%s:%d", sr.Host, sr.Port)
+				)
+				// resolve the hostname if it exists, or look up the
+				// name of the address otherwise, but only if a
+				// mapper needs it since a slow resolver would hold
+				// up every BMC
 				if net.ParseIP(trimmedHost) == nil {
+					hostname = trimmedHost
 					addrs, err := net.LookupIP(trimmedHost)
 					if err == nil && len(addrs) > 0 {
 						trimmedHost = addrs[0].String()
 					}
-				}
-				keys := &idmap.MapperKeys{
-					IPv4Addr: trimmedHost,
-				}
-				bmcID := mapper.GetMappedID(keys)
-
-				// If bmcID is empty, skip this
-				// BMC. Empty means that there is a
-				// valid mapping, but there was no
-				// match for this host in the mapping,
-				// meaning that the BMC is
-				// unrecognized. Skip this BMC.
-				if bmcID == "" {
-					continue
+				} else if lookupHostname {
+					if names, err := net.LookupAddr(trimmedHost); err == nil && len(names) > 0 {
+						hostname = names[0]
+					}
 				}
 
 				// crawl BMC node to fetch inventory data via Redfish
@@ -109,6 +130,8 @@ func CollectInventory(assets *[]RemoteAsset, params *CollectParams) ([]map[strin
 						CredentialStore: params.SecretStore,
 						Insecure:        params.Insecure,
 						UseDefault:      true,
+
+						WriteBackCredentials: params.WriteBackCredentials,
 					}
 				)
 
@@ -127,8 +150,49 @@ func CollectInventory(assets *[]RemoteAsset, params *CollectParams) ([]map[strin
 					continue
 				}
 
+				// optionally, add the MACAddr property if we find a matching IP
+				// from the correct ethernet interface
+				mac, err := FindMACAddressWithIP(config, net.ParseIP(trimmedHost))
+				if err != nil {
+					log.Warn().Err(err).Msgf("failed to find MAC address with IP '%s'", trimmedHost)
+				}
+
+				// the ID may be mapped from what the BMC reports, so it
+				// can only be computed after crawling
+				bmcID := mapper.GetMappedID(getMapperKeys(trimmedHost, hostname, mac, systems, managers))
+
+				// If bmcID is empty, skip this
+				// BMC. Empty means that there is a
+				// valid mapping, but there was no
+				// match for this host in the mapping,
+				// meaning that the BMC is
+				// unrecognized. Skip this BMC.
+				if bmcID == "" {
+					continue
+				}
+
+				// take a snapshot of the sensors separately from the inventory
+				if params.CollectSensors {
+					chassis, err := crawler.CrawlBMCForSensors(config)
+					if err != nil {
+						log.Error().Err(err).Str("uri", uri).Msg("failed to crawl BMC for sensors")
+					} else {
+						mu.Lock()
+						sensors = append(sensors, map[string]any{
+							"ID":        bmcID,
+							"FQDN":      strings.TrimPrefix(sr.Host, "https://"),
+							"Timestamp": time.Now().UTC(),
+							"Chassis":   chassis,
+						})
+						mu.Unlock()
+					}
+				}
+
 				// get BMC username to send
-				bmcCreds := bmc.GetBMCCredentialsOrDefault(params.SecretStore, config.URI)
+				bmcCreds, err := config.GetUserPass()
+				if err != nil {
+					log.Debug().Err(err).Str("id", config.URI).Msg("failed to get BMC credentials")
+				}
 				if bmcCreds == (bmc.BMCCredentials{}) {
 					log.Warn().Str("id", config.URI).Msg("username will be blank")
 				}
@@ -146,19 +210,6 @@ func CollectInventory(assets *[]RemoteAsset, params *CollectParams) ([]map[strin
 					"Managers":           managers,
 					"SchemaVersion":      1,
 				}
-
-				// optionally, add the MACAddr property if we find a matching IP
-				// from the correct ethernet interface
-
-				host := sr.Host
-				str_protocol := "https://"
-				if strings.Contains(host, str_protocol) {
-					host = strings.TrimPrefix(sr.Host, str_protocol)
-				}
-				mac, err := FindMACAddressWithIP(config, net.ParseIP(host))
-				if err != nil {
-					log.Warn().Err(err).Msgf("failed to find MAC address with IP '%s'", host)
-				}
 				if mac != "" {
 					data["MACAddr"] = mac
 				}
@@ -197,6 +248,9 @@ func CollectInventory(assets *[]RemoteAsset, params *CollectParams) ([]map[strin
 	wg.Wait()
 	close(done)
 
+	// report BMCs with ID problems before any output is written
+	writeIDReport(mapper.Report(), params)
+
 	var (
 		output     []byte
 		formatType format.DataFormat
@@ -248,9 +302,135 @@ func CollectInventory(assets *[]RemoteAsset, params *CollectParams) ([]map[strin
 		}
 	}
 
+	// write the sensor snapshot as its own dataset
+	if params.CollectSensors {
+		writeSensorSnapshot(sensors, params)
+	}
+
 	return collection, nil
 }
 
+// writeIDReport() logs a summary of the BMCs that were skipped, that
+// the ID mappers disagreed on or that got a duplicate ID, and writes the
+// full report to the '--id-report' path if set, or to stderr if it is '-'.
+func writeIDReport(report idmap.IDReport, params *CollectParams) {
+	log.Info().
+		Int("skipped", len(report.Skipped)).
+		Int("conflicting", len(report.Conflicting)).
+		Int("duplicate", len(report.Duplicate)).
+		Msg("mapped BMC IDs")
+	if params.IDReportPath == "" {
+		return
+	}
+
+	formatType := format.DataFormatFromFileExt(params.IDReportPath, params.OutputFormat)
+	output, err := format.MarshalData(report, formatType)
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to marshal ID report to %s", strings.ToUpper(formatType.String()))
+		return
+	}
+	if params.IDReportPath == "-" {
+		fmt.Fprintln(os.Stderr, string(output))
+		return
+	}
+	err = os.MkdirAll(filepath.Dir(params.IDReportPath), 0o777)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to make directory for ID report")
+		return
+	}
+	err = os.WriteFile(path.Clean(params.IDReportPath), output, os.ModePerm)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to write ID report to file")
+	}
+}
+
+// writeSensorSnapshot() writes the sensor readings collected during a run as a
+// timestamped dataset separate from the inventory. The snapshot is written to
+// the '--sensors-file' path if set. Otherwise, it is written next to the
+// inventory output with a '.sensors' suffix, or under a 'sensors' directory
+// for each BMC when using HIVE partitioning.
+func writeSensorSnapshot(sensors []map[string]any, params *CollectParams) {
+	var (
+		now        = time.Now().UTC()
+		formatType format.DataFormat
+		output     []byte
+		err        error
+	)
+
+	writeFile := func(path string, data any) {
+		formatType = format.DataFormatFromFileExt(path, params.OutputFormat)
+		output, err = format.MarshalData(data, formatType)
+		if err != nil {
+			log.Error().Err(err).Msgf("failed to marshal sensor snapshot to %s", strings.ToUpper(formatType.String()))
+			return
+		}
+		err = os.MkdirAll(filepath.Dir(path), 0o777)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to make directory for sensor snapshot")
+			return
+		}
+		err = os.WriteFile(path, output, os.ModePerm)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to write sensor snapshot to file")
+		}
+	}
+
+	switch {
+	case params.SensorsOutputPath != "":
+		writeFile(path.Clean(params.SensorsOutputPath), map[string]any{
+			"Timestamp": now,
+			"Sensors":   sensors,
+		})
+	case params.OutputDir != "":
+		for _, data := range sensors {
+			writeFile(fmt.Sprintf("./%s/%s/sensors/%d.%s",
+				path.Clean(params.OutputDir),
+				data["ID"],
+				now.Unix(),
+				params.OutputFormat,
+			), data)
+		}
+	case params.OutputPath != "":
+		ext := filepath.Ext(params.OutputPath)
+		writeFile(path.Clean(strings.TrimSuffix(params.OutputPath, ext)+".sensors"+ext), map[string]any{
+			"Timestamp": now,
+			"Sensors":   sensors,
+		})
+	default:
+		log.Warn().Int("count", len(sensors)).Msg("sensor snapshot collected but no output path set (see '--sensors-file')")
+	}
+}
+
+// getMapperKeys collects the keys a BMC ID mapper can look up from what is
+// known about a BMC after crawling it. The manager UUID, chassis serial and
+// location are taken from the first manager or system that reports one.
+func getMapperKeys(ip string, hostname string, mac string, systems []crawler.InventoryDetail, managers []crawler.Manager) *idmap.MapperKeys {
+	keys := &idmap.MapperKeys{
+		IPv4Addr:   ip,
+		Hostname:   hostname,
+		BMCMACAddr: mac,
+	}
+	for _, manager := range managers {
+		if manager.UUID != "" {
+			keys.ManagerUUID = manager.UUID
+			break
+		}
+	}
+	for _, system := range systems {
+		if system.Chassis_Serial != "" {
+			keys.ChassisSerial = system.Chassis_Serial
+			break
+		}
+	}
+	for _, system := range systems {
+		if system.Chassis_Location != nil {
+			keys.Location = system.Chassis_Location
+			break
+		}
+	}
+	return keys
+}
+
 // FindMACAddressWithIP() returns the MAC address of an ethernet interface with
 // a matching IPv4Address. Returns an empty string and error if there are no matches
 // found.
@@ -263,28 +443,8 @@ func FindMACAddressWithIP(config crawler.CrawlerConfig, targetIP net.IP) (string
 	// gofish (at least for now). If there's a need for grabbing more
 	// manager information in the future, we can move the logic into
 	// the crawler.
-	bmc_creds, err := config.GetUserPass()
+	client, err := crawler.GetBMCClient(config)
 	if err != nil {
-		return "", fmt.Errorf("failed to get credentials for URI: %s", config.URI)
-	}
-
-	client, err := gofish.Connect(gofish.ClientConfig{
-		Endpoint:  config.URI,
-		Username:  bmc_creds.Username,
-		Password:  bmc_creds.Password,
-		Insecure:  config.Insecure,
-		BasicAuth: true,
-	})
-	if err != nil {
-		if strings.HasPrefix(err.Error(), "404:") {
-			err = fmt.Errorf("no ServiceRoot found.  This is probably not a BMC: %s", config.URI)
-		}
-		if strings.HasPrefix(err.Error(), "401:") {
-			err = fmt.Errorf("authentication failed.  Check your username and password: %s", config.URI)
-		}
-		event := log.Error()
-		event.Err(err)
-		event.Msg("failed to connect to BMC")
 		return "", err
 	}
 	defer client.Logout()
@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/OpenCHAMI/magellan/internal/util"
+	"github.com/OpenCHAMI/magellan/pkg/bmc"
+	"github.com/rs/zerolog/log"
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/schemas"
+)
+
+// workingCredentials maps the URIs of BMCs with several candidate
+// credentials to the candidate that last authenticated with them, so that
+// it is tried first next time instead of the ones that were rejected.
+var workingCredentials sync.Map
+
+// WorkingCredentials returns the candidate credentials that last
+// authenticated with the BMC at uri, if the BMC has several candidates.
+func WorkingCredentials(uri string) (bmc.BMCCredentials, bool) {
+	creds, ok := workingCredentials.Load(uri)
+	if !ok {
+		return bmc.BMCCredentials{}, false
+	}
+	return creds.(bmc.BMCCredentials), true
+}
+
+// loadBMCCandidates returns the candidate credentials of a BMC in the order
+// they are to be tried, starting with the one that last authenticated.
+func loadBMCCandidates(config CrawlerConfig) ([]bmc.BMCCredentials, bmc.CredentialMatch, error) {
+	// NOTE: it is possible for the SecretStore to be nil, so we need a check
+	if config.CredentialStore == nil {
+		return nil, bmc.CredentialMatch{}, fmt.Errorf("credential store is invalid")
+	}
+	vendor := func() string { return GetBMCVendor(config) }
+	candidates, match := util.GetBMCCredentialCandidates(config.CredentialStore, config.URI, vendor)
+	candidates = slices.DeleteFunc(candidates, func(creds bmc.BMCCredentials) bool {
+		return creds == (bmc.BMCCredentials{})
+	})
+	if len(candidates) == 0 {
+		return nil, match, fmt.Errorf("%s: credentials blank for BMC", config.URI)
+	}
+	if working, ok := WorkingCredentials(config.URI); ok {
+		if i := slices.Index(candidates, working); i > 0 {
+			candidates = slices.Insert(slices.Delete(candidates, i, i+1), 0, working)
+		}
+	}
+	return candidates, match, nil
+}
+
+// recordWorkingCredentials remembers the candidate credentials that
+// authenticated with a BMC and, if asked to, stores them in the secret
+// store under the URI of the BMC so that they are used from then on.
+func recordWorkingCredentials(config CrawlerConfig, match bmc.CredentialMatch, creds bmc.BMCCredentials) {
+	if previous, loaded := workingCredentials.Swap(config.URI, creds); loaded && previous == creds {
+		return
+	}
+	log.Info().Str("uri", config.URI).Str("secret ID", match.SecretID).Str("username", creds.Username).Msg("found working candidate credentials for BMC")
+
+	if !config.WriteBackCredentials {
+		return
+	}
+	secret, err := json.Marshal(creds)
+	if err != nil {
+		log.Error().Err(err).Str("uri", config.URI).Msg("failed to marshal working credentials")
+		return
+	}
+	if err := config.CredentialStore.StoreSecretByID(config.URI, string(secret)); err != nil {
+		log.Error().Err(err).Str("uri", config.URI).Msg("failed to store working credentials")
+		return
+	}
+	log.Info().Str("uri", config.URI).Msg("stored working credentials under BMC URI")
+}
+
+// verifyBMCLogin makes a request that requires authentication, since
+// connecting with basic auth doesn't check the credentials.
+func verifyBMCLogin(client *gofish.APIClient) error {
+	resp, err := client.Get("/redfish/v1/Managers")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// isAuthError reports whether a BMC rejected the credentials of a request.
+func isAuthError(err error) bool {
+	var rfErr *schemas.Error
+	if errors.As(err, &rfErr) {
+		return rfErr.HTTPReturnedStatusCode == http.StatusUnauthorized || rfErr.HTTPReturnedStatusCode == http.StatusForbidden
+	}
+	return false
+}
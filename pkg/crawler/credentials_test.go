@@ -0,0 +1,124 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/OpenCHAMI/magellan/pkg/bmc"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+// newAuthBMC starts a BMC that only accepts the given basic auth
+// credentials, and counts the requests it rejected.
+func newAuthBMC(t *testing.T, username, password string, rejected *int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redfish/v1/", serveJSON(`{
+		"@odata.id": "/redfish/v1/",
+		"Id": "RootService",
+		"RedfishVersion": "1.15.0",
+		"Vendor": "Supermicro",
+		"Managers": {"@odata.id": "/redfish/v1/Managers"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Managers", func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != username || p != password {
+			*rejected++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		serveJSON(`{"Members": []}`)(w, r)
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetBMCClientCandidates(t *testing.T) {
+	t.Parallel()
+
+	masterKey, err := secrets.GenerateMasterKey()
+	require.NoError(t, err)
+	store, err := secrets.NewLocalSecretStore(masterKey, filepath.Join(t.TempDir(), "secrets.json"), true)
+	require.NoError(t, err)
+	require.NoError(t, store.StoreSecretByID("vendor:supermicro", `{"candidates": [
+		{"username": "ADMIN", "password": "ADMIN"},
+		{"username": "root", "password": "calvin"}
+	]}`))
+
+	// the second candidate authenticates and is remembered
+	var rejected int
+	server := newAuthBMC(t, "root", "calvin", &rejected)
+	config := CrawlerConfig{URI: server.URL, Insecure: true, CredentialStore: store}
+	_, err = GetBMCClient(config)
+	require.NoError(t, err)
+	require.Equal(t, 1, rejected)
+	working, ok := WorkingCredentials(server.URL)
+	require.True(t, ok)
+	require.Equal(t, bmc.BMCCredentials{Username: "root", Password: "calvin"}, working)
+
+	// and tried first from then on
+	_, err = GetBMCClient(config)
+	require.NoError(t, err)
+	require.Equal(t, 1, rejected)
+	_, err = store.GetSecretByID(server.URL)
+	require.Error(t, err, "credentials must only be written back when asked to")
+
+	// with write back, the working candidate is stored under the BMC URI
+	server = newAuthBMC(t, "ADMIN", "ADMIN", &rejected)
+	config = CrawlerConfig{URI: server.URL, Insecure: true, CredentialStore: store, WriteBackCredentials: true}
+	_, err = GetBMCClient(config)
+	require.NoError(t, err)
+	creds, err := bmc.GetBMCCredentials(store, server.URL)
+	require.NoError(t, err)
+	require.Equal(t, bmc.BMCCredentials{Username: "ADMIN", Password: "ADMIN"}, creds)
+
+	// no candidate authenticates
+	rejected = 0
+	server = newAuthBMC(t, "root", "hunter2", &rejected)
+	_, err = GetBMCClient(CrawlerConfig{URI: server.URL, Insecure: true, CredentialStore: store})
+	require.ErrorContains(t, err, "authentication failed")
+	require.Equal(t, 2, rejected)
+}
+
+func TestLoadBMCCandidatesVendorCached(t *testing.T) {
+	t.Parallel()
+
+	masterKey, err := secrets.GenerateMasterKey()
+	require.NoError(t, err)
+	store, err := secrets.NewLocalSecretStore(masterKey, filepath.Join(t.TempDir(), "secrets.json"), true)
+	require.NoError(t, err)
+	require.NoError(t, store.StoreSecretByID("vendor:supermicro", `{"username": "root", "password": "calvin"}`))
+
+	// the vendor is read from the service root, so count the requests
+	// to it made while selecting credentials
+	var rejected int
+	var lookups atomic.Int32
+	server := newAuthBMC(t, "root", "calvin", &rejected)
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redfish/v1/" {
+			lookups.Add(1)
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	config := CrawlerConfig{URI: server.URL, Insecure: true, CredentialStore: store}
+	for i := 0; i < 3; i++ {
+		candidates, match, err := loadBMCCandidates(config)
+		require.NoError(t, err)
+		require.Equal(t, "vendor:supermicro", match.SecretID)
+		require.Equal(t, []bmc.BMCCredentials{{Username: "root", Password: "calvin"}}, candidates)
+	}
+	require.Equal(t, int32(1), lookups.Load(), "the vendor must only be read once per BMC")
+	require.Equal(t, "Supermicro", GetBMCVendor(config))
+	require.Equal(t, int32(1), lookups.Load())
+
+	// unreachable BMCs are tried again
+	unreachable := CrawlerConfig{URI: "https://127.0.0.1:1", Insecure: true}
+	require.Empty(t, GetBMCVendor(unreachable))
+	_, ok := bmcVendors.Load(unreachable.URI)
+	require.False(t, ok)
+}
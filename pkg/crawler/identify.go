@@ -2,7 +2,9 @@ package crawler
 
 import (
 	"fmt"
+	"sync"
 
+	"github.com/rs/zerolog/log"
 	"github.com/stmcginnis/gofish"
 	"github.com/stmcginnis/gofish/schemas"
 )
@@ -67,3 +69,28 @@ func GetBMCInfo(client *gofish.APIClient) ([]BMCInfo, error) {
 
 	return bmcList, nil
 }
+
+// bmcVendors maps the URIs of BMCs to the vendor they reported in their
+// service root, so that it is only read once per BMC instead of on every
+// connection that selects credentials by vendor.
+var bmcVendors sync.Map
+
+// GetBMCVendor returns the vendor that a BMC reports in its Redfish service
+// root, which doesn't require logging in, or an empty string if it can't be
+// read. It is used to select credentials by vendor before logging in. The
+// vendor is cached per URI once it was read, while failures are retried.
+func GetBMCVendor(config CrawlerConfig) string {
+	if vendor, ok := bmcVendors.Load(config.URI); ok {
+		return vendor.(string)
+	}
+	client, err := gofish.Connect(gofish.ClientConfig{
+		Endpoint: config.URI,
+		Insecure: config.Insecure,
+	})
+	if err != nil {
+		log.Debug().Err(err).Str("id", config.URI).Msg("failed to read vendor from service root")
+		return ""
+	}
+	bmcVendors.Store(config.URI, client.Service.Vendor)
+	return client.Service.Vendor
+}
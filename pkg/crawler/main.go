@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/OpenCHAMI/magellan/internal/util"
 	"github.com/OpenCHAMI/magellan/pkg/bmc"
 	"github.com/OpenCHAMI/magellan/pkg/secrets"
 	"github.com/rs/zerolog/log"
@@ -13,14 +12,21 @@ import (
 )
 
 type CrawlerConfig struct {
-	URI             string // URI of the BMC
-	Insecure        bool   // Whether to ignore SSL errors
-	CredentialStore secrets.SecretStore
-	UseDefault      bool
+	URI                  string // URI of the BMC
+	Insecure             bool   // Whether to ignore SSL errors
+	CredentialStore      secrets.SecretStore
+	UseDefault           bool
+	WriteBackCredentials bool // Whether to store the candidate credentials that authenticated under the URI
 }
 
+// GetUserPass returns the credentials of the BMC, which are the candidate
+// that last authenticated with it if there are several.
 func (cc *CrawlerConfig) GetUserPass() (bmc.BMCCredentials, error) {
-	return loadBMCCreds(*cc)
+	candidates, _, err := loadBMCCandidates(*cc)
+	if err != nil {
+		return bmc.BMCCredentials{}, err
+	}
+	return candidates[0], nil
 }
 
 type EthernetInterface struct {
@@ -84,6 +90,17 @@ type SerialConsole struct {
 	SSH    SerialConsoleConfig `json:"ssh,omitempty"`
 }
 
+// Location is the physical location of a chassis, taken from the Placement
+// and PartLocation of its Redfish Location property.
+type Location struct {
+	Rack                 string `json:"rack,omitempty"`                   // Name of the rack
+	Row                  string `json:"row,omitempty"`                    // Name of the row of racks
+	RackOffset           *int   `json:"rack_offset,omitempty"`            // Vertical position in the rack, usually in rack units
+	ServiceLabel         string `json:"service_label,omitempty"`          // Label of the slot, as printed on the enclosure
+	LocationOrdinalValue *int   `json:"location_ordinal_value,omitempty"` // Number of the slot within the enclosure
+	LocationType         string `json:"location_type,omitempty"`          // Type of the slot, e.g. Slot or Bay
+}
+
 type InventoryDetail struct {
 	URI                  string              `json:"uri,omitempty"`                  // URI of the BMC
 	UUID                 string              `json:"uuid,omitempty"`                 // UUID of Node
@@ -108,6 +125,7 @@ type InventoryDetail struct {
 	Chassis_AssetTag     string              `json:"chassis_asset_tag,omitempty"`    // Asset tag of the Chassis
 	Chassis_Manufacturer string              `json:"chassis_manufacturer,omitempty"` // Manufacturer of the Chassis
 	Chassis_Model        string              `json:"chassis_model,omitempty"`        // Model of the Chassis
+	Chassis_Location     *Location           `json:"chassis_location,omitempty"`     // Physical location of the Chassis
 	Links                Links               `json:"links,omitempty"`                // Links to specific resources
 	NodeID               string              `json:"node_id,omitempty"`              // Node ID within the BMC, e.g. /redfish/v1/Systems/<ID>
 }
@@ -128,8 +146,8 @@ type InventoryDetail struct {
 //  3. Handles specific connection errors such as 404 (ServiceRoot not found) and 401 (authentication failed).
 //  4. Returns the active gofish client.
 func GetBMCClient(config CrawlerConfig) (*gofish.APIClient, error) {
-	// get username and password from secret store
-	bmc_creds, err := loadBMCCreds(config)
+	// get the candidate usernames and passwords from secret store
+	candidates, match, err := loadBMCCandidates(config)
 	if err != nil {
 		event := log.Error()
 		event.Err(err)
@@ -137,27 +155,43 @@ func GetBMCClient(config CrawlerConfig) (*gofish.APIClient, error) {
 		return nil, err
 	}
 
-	// initialize gofish client
-	client, err := gofish.Connect(gofish.ClientConfig{
-		Endpoint:  config.URI,
-		Username:  bmc_creds.Username,
-		Password:  bmc_creds.Password,
-		Insecure:  config.Insecure,
-		BasicAuth: true,
-	})
-	if err != nil {
-		if strings.HasPrefix(err.Error(), "404:") {
-			err = fmt.Errorf("no ServiceRoot found.  This is probably not a BMC: %s", config.URI)
+	// initialize gofish client, trying each candidate until one authenticates
+	var client *gofish.APIClient
+	for i, bmc_creds := range candidates {
+		client, err = gofish.Connect(gofish.ClientConfig{
+			Endpoint:  config.URI,
+			Username:  bmc_creds.Username,
+			Password:  bmc_creds.Password,
+			Insecure:  config.Insecure,
+			BasicAuth: true,
+		})
+		// basic auth isn't checked when connecting, so make sure the
+		// candidate works before settling on it
+		if err == nil && len(candidates) > 1 {
+			err = verifyBMCLogin(client)
+		}
+		if err == nil {
+			if len(candidates) > 1 {
+				recordWorkingCredentials(config, match, bmc_creds)
+			}
+			return client, nil
 		}
-		if strings.HasPrefix(err.Error(), "401:") {
-			err = fmt.Errorf("authentication failed.  Check your username and password: %s", config.URI)
+		if !isAuthError(err) {
+			break
 		}
-		event := log.Error()
-		event.Err(err)
-		event.Msg("failed to connect to BMC")
-		return nil, err
+		log.Debug().Str("uri", config.URI).Str("secret ID", match.SecretID).Int("candidate", i+1).Str("username", bmc_creds.Username).Msg("BMC rejected candidate credentials")
+	}
+
+	if strings.HasPrefix(err.Error(), "404:") {
+		err = fmt.Errorf("no ServiceRoot found.  This is probably not a BMC: %s", config.URI)
+	}
+	if isAuthError(err) {
+		err = fmt.Errorf("authentication failed.  Check your username and password: %s", config.URI)
 	}
-	return client, nil
+	event := log.Error()
+	event.Err(err)
+	event.Msg("failed to connect to BMC")
+	return nil, err
 }
 
 // CrawlBMCForSystems pulls all pertinent information from a BMC.
@@ -385,6 +419,7 @@ func walkSystems(rf_systems []*schemas.ComputerSystem, rf_chassis *schemas.Chass
 			system.Chassis_AssetTag = rf_chassis.AssetTag
 			system.Chassis_Manufacturer = rf_chassis.Manufacturer
 			system.Chassis_Model = rf_chassis.Model
+			system.Chassis_Location = toLocation(rf_chassis.Location)
 		}
 
 		// add ethernet interfaces
@@ -516,16 +551,21 @@ func walkManagers(rf_managers []*schemas.Manager, baseURI string) ([]Manager, er
 	return managers, nil
 }
 
-func loadBMCCreds(config CrawlerConfig) (bmc.BMCCredentials, error) {
-	// NOTE: it is possible for the SecretStore to be nil, so we need a check
-	if config.CredentialStore == nil {
-		return bmc.BMCCredentials{}, fmt.Errorf("credential store is invalid")
+// toLocation converts a Redfish Location into a Location, or nil if it holds
+// neither a placement nor a part location.
+func toLocation(rf_location schemas.Location) *Location {
+	location := Location{
+		Rack:                 rf_location.Placement.Rack,
+		Row:                  rf_location.Placement.Row,
+		RackOffset:           rf_location.Placement.RackOffset,
+		ServiceLabel:         rf_location.PartLocation.ServiceLabel,
+		LocationOrdinalValue: rf_location.PartLocation.LocationOrdinalValue,
+		LocationType:         string(rf_location.PartLocation.LocationType),
 	}
-	if creds := util.GetBMCCredentials(config.CredentialStore, config.URI); creds == (bmc.BMCCredentials{}) {
-		return creds, fmt.Errorf("%s: credentials blank for BMC", config.URI)
-	} else {
-		return creds, nil
+	if location == (Location{}) {
+		return nil
 	}
+	return &location
 }
 
 func extractPtrMapValues[T any](m map[string]*T) []T {
@@ -0,0 +1,169 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/pkg/pdu"
+	"github.com/rs/zerolog/log"
+	"github.com/stmcginnis/gofish/schemas"
+)
+
+// HasPowerEquipment reports whether a Redfish ServiceRoot links to a
+// PowerEquipment resource, which sets Redfish PDUs apart from BMCs.
+func HasPowerEquipment(serviceRoot []byte) bool {
+	var root struct {
+		PowerEquipment schemas.Link `json:"PowerEquipment"`
+	}
+	if err := json.Unmarshal(serviceRoot, &root); err != nil {
+		return false
+	}
+	return root.PowerEquipment.String() != ""
+}
+
+// IsRedfishPDU checks whether the Redfish service at the URI is a PDU. Only the
+// ServiceRoot is requested, which does not require authentication.
+//
+// Parameters:
+//   - uri: The URI of the service, e.g. https://x3000m0.
+//   - insecure: Whether to ignore SSL errors.
+//   - timeout: How long to wait for a response.
+//
+// Returns:
+//   - bool: True if the service has a PowerEquipment resource, false if not or if the service is not Redfish.
+func IsRedfishPDU(uri string, insecure bool, timeout time.Duration) bool {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+		},
+	}
+	res, err := client.Get(strings.TrimRight(uri, "/") + "/redfish/v1/")
+	if err != nil {
+		log.Debug().Err(err).Str("uri", uri).Msg("failed to get Redfish ServiceRoot")
+		return false
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return false
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return false
+	}
+	return HasPowerEquipment(body)
+}
+
+// CrawlRackPDUs connects to a Redfish service and collects the inventory of
+// each rack PDU under PowerEquipment, in the same form as the JAWS crawler.
+// The input (mains) circuits of a PDU are reported as its phases.
+//
+// Parameters:
+//   - config: A CrawlerConfig struct containing the URI and credentials of the PDU.
+//   - hostname: The name to identify the PDU by in the inventory.
+//
+// Returns:
+//   - []pdu.PDUInventory: The inventory of each rack PDU. When the service manages more than one, the hostname is suffixed with the ID of each PDU.
+//   - error: An error object if the service has no rack PDUs or they could not be retrieved.
+func CrawlRackPDUs(config CrawlerConfig, hostname string) ([]pdu.PDUInventory, error) {
+	client, err := GetBMCClient(config)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Logout()
+
+	rf_equipment, err := client.GetService().PowerEquipment()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PowerEquipment: %w", err)
+	}
+	if rf_equipment == nil {
+		return nil, fmt.Errorf("no PowerEquipment found. This is probably not a Redfish PDU: %s", config.URI)
+	}
+	rf_pdus, err := rf_equipment.RackPDUs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rack PDUs: %w", err)
+	}
+	if len(rf_pdus) == 0 {
+		return nil, fmt.Errorf("no rack PDUs found in PowerEquipment: %s", config.URI)
+	}
+
+	inventories := make([]pdu.PDUInventory, 0, len(rf_pdus))
+	for _, rf_pdu := range rf_pdus {
+		inventory := pdu.PDUInventory{
+			Hostname:        hostname,
+			Model:           rf_pdu.Model,
+			SerialNumber:    rf_pdu.SerialNumber,
+			FirmwareVersion: rf_pdu.FirmwareVersion,
+		}
+		if len(rf_pdus) > 1 {
+			inventory.Hostname = fmt.Sprintf("%s-%s", hostname, rf_pdu.ID)
+		}
+
+		rf_outlets, err := rf_pdu.Outlets()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get outlets of rack PDU %s: %w", rf_pdu.ID, err)
+		}
+		for _, rf_outlet := range rf_outlets {
+			inventory.Outlets = append(inventory.Outlets, pdu.PDUOutlet{
+				ID:          rf_outlet.ID,
+				Name:        outletName(rf_outlet),
+				PowerState:  string(rf_outlet.PowerState),
+				SocketType:  string(rf_outlet.OutletType),
+				Current:     reading(rf_outlet.CurrentAmps.Reading),
+				Voltage:     reading(rf_outlet.Voltage.Reading),
+				ActivePower: reading(rf_outlet.PowerWatts.Reading),
+			})
+		}
+
+		// the circuits are optional, so only log failures
+		rf_mains, err := rf_pdu.Mains()
+		if err != nil {
+			log.Warn().Err(err).Msgf("failed to get mains of rack PDU %s", rf_pdu.ID)
+		}
+		inventory.Phases = toPDUCircuits(rf_mains)
+		rf_branches, err := rf_pdu.Branches()
+		if err != nil {
+			log.Warn().Err(err).Msgf("failed to get branches of rack PDU %s", rf_pdu.ID)
+		}
+		inventory.Branches = toPDUCircuits(rf_branches)
+
+		log.Debug().Msgf("collected inventory for %d outlets of rack PDU %s from %s", len(inventory.Outlets), rf_pdu.ID, config.URI)
+		inventories = append(inventories, inventory)
+	}
+	return inventories, nil
+}
+
+func toPDUCircuits(rf_circuits []*schemas.Circuit) []pdu.PDUCircuit {
+	var circuits []pdu.PDUCircuit
+	for _, rf_circuit := range rf_circuits {
+		circuits = append(circuits, pdu.PDUCircuit{
+			ID:          rf_circuit.ID,
+			Name:        rf_circuit.Name,
+			Current:     reading(rf_circuit.CurrentAmps.Reading),
+			Voltage:     reading(rf_circuit.Voltage.Reading),
+			ActivePower: reading(rf_circuit.PowerWatts.Reading),
+		})
+	}
+	return circuits
+}
+
+// outletName prefers the label given to an outlet by the user.
+func outletName(rf_outlet *schemas.Outlet) string {
+	if rf_outlet.UserLabel != "" {
+		return rf_outlet.UserLabel
+	}
+	return rf_outlet.Name
+}
+
+// reading returns the value of a sensor excerpt, or 0 if there is none.
+func reading(value *float64) float64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
@@ -0,0 +1,96 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/pkg/pdu"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrawlRackPDUs(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redfish/v1/", serveJSON(`{
+		"@odata.id": "/redfish/v1/",
+		"Id": "RootService",
+		"RedfishVersion": "1.15.0",
+		"PowerEquipment": {"@odata.id": "/redfish/v1/PowerEquipment"}
+	}`))
+	mux.HandleFunc("/redfish/v1/PowerEquipment", serveJSON(`{
+		"@odata.id": "/redfish/v1/PowerEquipment",
+		"Id": "PowerEquipment",
+		"RackPDUs": {"@odata.id": "/redfish/v1/PowerEquipment/RackPDUs"}
+	}`))
+	mux.HandleFunc("/redfish/v1/PowerEquipment/RackPDUs", serveJSON(`{
+		"Members": [{"@odata.id": "/redfish/v1/PowerEquipment/RackPDUs/1"}]
+	}`))
+	mux.HandleFunc("/redfish/v1/PowerEquipment/RackPDUs/1", serveJSON(`{
+		"@odata.id": "/redfish/v1/PowerEquipment/RackPDUs/1",
+		"Id": "1",
+		"Model": "ZAP4000",
+		"SerialNumber": "29347ZT536",
+		"FirmwareVersion": "4.3.0",
+		"Outlets": {"@odata.id": "/redfish/v1/PowerEquipment/RackPDUs/1/Outlets"},
+		"Mains": {"@odata.id": "/redfish/v1/PowerEquipment/RackPDUs/1/Mains"}
+	}`))
+	mux.HandleFunc("/redfish/v1/PowerEquipment/RackPDUs/1/Outlets", serveJSON(`{
+		"Members": [{"@odata.id": "/redfish/v1/PowerEquipment/RackPDUs/1/Outlets/A1"}]
+	}`))
+	mux.HandleFunc("/redfish/v1/PowerEquipment/RackPDUs/1/Outlets/A1", serveJSON(`{
+		"@odata.id": "/redfish/v1/PowerEquipment/RackPDUs/1/Outlets/A1",
+		"Id": "A1",
+		"Name": "Outlet A1",
+		"UserLabel": "x3000c0s1b0",
+		"OutletType": "IEC_60320_C13",
+		"PowerState": "On",
+		"CurrentAmps": {"Reading": 1.68},
+		"Voltage": {"Reading": 117.5},
+		"PowerWatts": {"Reading": 197.4}
+	}`))
+	mux.HandleFunc("/redfish/v1/PowerEquipment/RackPDUs/1/Mains", serveJSON(`{
+		"Members": [{"@odata.id": "/redfish/v1/PowerEquipment/RackPDUs/1/Mains/AC1"}]
+	}`))
+	mux.HandleFunc("/redfish/v1/PowerEquipment/RackPDUs/1/Mains/AC1", serveJSON(`{
+		"@odata.id": "/redfish/v1/PowerEquipment/RackPDUs/1/Mains/AC1",
+		"Id": "AC1",
+		"Name": "Mains Input AC1",
+		"CurrentAmps": {"Reading": 2.1},
+		"Voltage": {"Reading": 117.5},
+		"PowerWatts": {"Reading": 240.2}
+	}`))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	require.True(t, IsRedfishPDU(server.URL, false, time.Second))
+	require.False(t, HasPowerEquipment([]byte(`{"Systems": {"@odata.id": "/redfish/v1/Systems"}}`)))
+
+	inventories, err := CrawlRackPDUs(CrawlerConfig{
+		URI:             server.URL,
+		CredentialStore: secrets.NewStaticStore("admin", "secret"),
+	}, "x3000m0")
+	require.NoError(t, err)
+	require.Len(t, inventories, 1)
+
+	inventory := inventories[0]
+	require.Equal(t, "x3000m0", inventory.Hostname)
+	require.Equal(t, "ZAP4000", inventory.Model)
+	require.Equal(t, "29347ZT536", inventory.SerialNumber)
+	require.Equal(t, "4.3.0", inventory.FirmwareVersion)
+	require.Equal(t, []pdu.PDUOutlet{{
+		ID:          "A1",
+		Name:        "x3000c0s1b0",
+		PowerState:  "On",
+		SocketType:  "IEC_60320_C13",
+		Current:     1.68,
+		Voltage:     117.5,
+		ActivePower: 197.4,
+	}}, inventory.Outlets)
+	require.Equal(t, []pdu.PDUCircuit{{ID: "AC1", Name: "Mains Input AC1", Current: 2.1, Voltage: 117.5, ActivePower: 240.2}}, inventory.Phases)
+	require.Empty(t, inventory.Branches)
+}
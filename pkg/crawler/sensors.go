@@ -0,0 +1,302 @@
+package crawler
+
+import (
+	"encoding/json"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stmcginnis/gofish/schemas"
+)
+
+type SensorThresholds struct {
+	LowerCaution  *float64 `json:"lower_caution,omitempty"`
+	LowerCritical *float64 `json:"lower_critical,omitempty"`
+	LowerFatal    *float64 `json:"lower_fatal,omitempty"`
+	UpperCaution  *float64 `json:"upper_caution,omitempty"`
+	UpperCritical *float64 `json:"upper_critical,omitempty"`
+	UpperFatal    *float64 `json:"upper_fatal,omitempty"`
+}
+
+type SensorReading struct {
+	URI             string           `json:"uri,omitempty"`              // URI of the sensor
+	Name            string           `json:"name,omitempty"`             // Name of the sensor
+	PhysicalContext string           `json:"physical_context,omitempty"` // Area or device the sensor measures, e.g. CPU or PowerSupply
+	Reading         *float64         `json:"reading,omitempty"`          // Current reading of the sensor
+	Units           string           `json:"units,omitempty"`            // Units of the reading, e.g. Cel, RPM, V or W
+	Thresholds      SensorThresholds `json:"thresholds,omitempty"`       // Thresholds used to derive the sensor health
+	Health          string           `json:"health,omitempty"`           // Health of the sensor, e.g. OK, Warning or Critical
+	State           string           `json:"state,omitempty"`            // State of the sensor, e.g. Enabled or Absent
+}
+
+type PowerSupplyReading struct {
+	URI              string   `json:"uri,omitempty"`                // URI of the power supply
+	Name             string   `json:"name,omitempty"`               // Name of the power supply
+	Model            string   `json:"model,omitempty"`              // Model of the power supply
+	Serial           string   `json:"serial,omitempty"`             // Serial number of the power supply
+	CapacityWatts    *float64 `json:"capacity_watts,omitempty"`     // Maximum rated output of the power supply
+	InputWatts       *float64 `json:"input_watts,omitempty"`        // Measured input power
+	OutputWatts      *float64 `json:"output_watts,omitempty"`       // Measured output power
+	LineInputVoltage *float64 `json:"line_input_voltage,omitempty"` // Measured line input voltage
+	Health           string   `json:"health,omitempty"`             // Health of the power supply
+	State            string   `json:"state,omitempty"`              // State of the power supply
+}
+
+type ChassisSensors struct {
+	URI           string               `json:"uri,omitempty"`            // URI of the chassis
+	ChassisID     string               `json:"chassis_id,omitempty"`     // Chassis ID within the BMC, e.g. /redfish/v1/Chassis/<ID>
+	Source        string               `json:"source,omitempty"`         // Redfish model the readings came from (Sensors or Thermal/Power)
+	Temperatures  []SensorReading      `json:"temperatures,omitempty"`   // Temperature sensors in degrees Celsius
+	Fans          []SensorReading      `json:"fans,omitempty"`           // Fan speed sensors
+	Voltages      []SensorReading      `json:"voltages,omitempty"`       // Voltage rail sensors
+	Power         []SensorReading      `json:"power,omitempty"`          // Power sensors in Watts
+	PowerSupplies []PowerSupplyReading `json:"power_supplies,omitempty"` // Power supply readings
+	Health        string               `json:"health,omitempty"`         // Health rollup of the chassis
+}
+
+const (
+	SensorSourceSensors = "Sensors"
+	SensorSourceLegacy  = "Thermal/Power"
+)
+
+// CrawlBMCForSensors connects to a BMC (Baseboard Management Controller) using the provided configuration
+// and reads the environmental sensors of every chassis found in the ServiceRoot.
+//
+// The newer Sensors collection with ThermalSubsystem/PowerSubsystem is preferred when the
+// chassis implements it. Otherwise, the deprecated Thermal and Power resources are used instead.
+//
+// Parameters:
+//   - config: A CrawlerConfig struct containing the URI, username, password, and other connection details.
+//
+// Returns:
+//   - []ChassisSensors: A slice of sensor readings grouped by chassis.
+//   - error: An error object if any error occurs during the connection or retrieval process.
+func CrawlBMCForSensors(config CrawlerConfig) ([]ChassisSensors, error) {
+	var chassisSensors []ChassisSensors
+	client, err := GetBMCClient(config)
+	if err != nil {
+		return chassisSensors, err
+	}
+	defer client.Logout()
+
+	// Obtain the ServiceRoot
+	rf_service := client.GetService()
+	log.Debug().Msgf("found ServiceRoot %s. Redfish Version %s", rf_service.ID, rf_service.RedfishVersion)
+
+	rf_chassis, err := rf_service.Chassis()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get chassis from ServiceRoot")
+		return chassisSensors, err
+	}
+	for _, chassis := range rf_chassis {
+		chassisSensors = append(chassisSensors, walkChassisSensors(chassis, config.URI))
+	}
+	return chassisSensors, nil
+}
+
+// walkChassisSensors reads all sensors of a single chassis. Failures to read any
+// one resource are logged and the remaining resources are still read.
+func walkChassisSensors(rf_chassis *schemas.Chassis, baseURI string) ChassisSensors {
+	sensors := ChassisSensors{
+		URI:       baseURI + rf_chassis.ODataID,
+		ChassisID: rf_chassis.ID,
+		Health:    string(rf_chassis.Status.Health),
+	}
+
+	// try the newer Sensors collection first since Thermal and Power are deprecated
+	rf_sensors, err := rf_chassis.Sensors()
+	if err != nil {
+		log.Warn().Err(err).Str("chassis_id", rf_chassis.ID).Msg("failed to get sensors from chassis")
+	}
+	if len(rf_sensors) > 0 {
+		sensors.Source = SensorSourceSensors
+		for _, rf_sensor := range rf_sensors {
+			reading := sensorReadingFromSensor(rf_sensor, baseURI)
+			switch rf_sensor.ReadingType {
+			case schemas.TemperatureReadingType:
+				sensors.Temperatures = append(sensors.Temperatures, reading)
+			case schemas.RotationalReadingType:
+				sensors.Fans = append(sensors.Fans, reading)
+			case schemas.VoltageReadingType:
+				sensors.Voltages = append(sensors.Voltages, reading)
+			case schemas.PowerReadingType:
+				sensors.Power = append(sensors.Power, reading)
+			}
+		}
+
+		rf_powersubsystem, err := rf_chassis.PowerSubsystem()
+		if err != nil {
+			log.Warn().Err(err).Str("chassis_id", rf_chassis.ID).Msg("failed to get power subsystem from chassis")
+		} else if rf_powersubsystem != nil {
+			rf_powersupplies, err := rf_powersubsystem.PowerSupplies()
+			if err != nil {
+				log.Warn().Err(err).Str("chassis_id", rf_chassis.ID).Msg("failed to get power supplies from power subsystem")
+			}
+			for _, rf_powersupply := range rf_powersupplies {
+				psu := powerSupplyReading(rf_powersupply, baseURI)
+				addPowerSupplyMetrics(&psu, rf_chassis, rf_powersupply)
+				sensors.PowerSupplies = append(sensors.PowerSupplies, psu)
+			}
+		}
+		return sensors
+	}
+
+	// fall back to the deprecated Thermal and Power resources
+	sensors.Source = SensorSourceLegacy
+	rf_thermal, err := rf_chassis.Thermal()
+	if err != nil {
+		log.Warn().Err(err).Str("chassis_id", rf_chassis.ID).Msg("failed to get thermal from chassis")
+	} else if rf_thermal != nil {
+		for _, t := range rf_thermal.Temperatures {
+			sensors.Temperatures = append(sensors.Temperatures, SensorReading{
+				URI:             baseURI + t.ODataID,
+				Name:            t.Name,
+				PhysicalContext: string(t.PhysicalContext),
+				Reading:         t.ReadingCelsius,
+				Units:           "Cel",
+				Thresholds: SensorThresholds{
+					LowerCaution:  t.LowerThresholdNonCritical,
+					LowerCritical: t.LowerThresholdCritical,
+					LowerFatal:    t.LowerThresholdFatal,
+					UpperCaution:  t.UpperThresholdNonCritical,
+					UpperCritical: t.UpperThresholdCritical,
+					UpperFatal:    t.UpperThresholdFatal,
+				},
+				Health: string(t.Status.Health),
+				State:  string(t.Status.State),
+			})
+		}
+		for _, f := range rf_thermal.Fans {
+			name := f.Name
+			if name == "" {
+				name = f.FanName
+			}
+			sensors.Fans = append(sensors.Fans, SensorReading{
+				URI:             baseURI + f.ODataID,
+				Name:            name,
+				PhysicalContext: string(f.PhysicalContext),
+				Reading:         toFloat64(f.Reading),
+				Units:           string(f.ReadingUnits),
+				Thresholds: SensorThresholds{
+					LowerCaution:  toFloat64(f.LowerThresholdNonCritical),
+					LowerCritical: toFloat64(f.LowerThresholdCritical),
+					LowerFatal:    toFloat64(f.LowerThresholdFatal),
+					UpperCaution:  toFloat64(f.UpperThresholdNonCritical),
+					UpperCritical: toFloat64(f.UpperThresholdCritical),
+					UpperFatal:    toFloat64(f.UpperThresholdFatal),
+				},
+				Health: string(f.Status.Health),
+				State:  string(f.Status.State),
+			})
+		}
+	}
+
+	rf_power, err := rf_chassis.Power()
+	if err != nil {
+		log.Warn().Err(err).Str("chassis_id", rf_chassis.ID).Msg("failed to get power from chassis")
+	} else if rf_power != nil {
+		for _, v := range rf_power.Voltages {
+			sensors.Voltages = append(sensors.Voltages, SensorReading{
+				URI:             baseURI + v.ODataID,
+				Name:            v.Name,
+				PhysicalContext: string(v.PhysicalContext),
+				Reading:         toFloat64(v.ReadingVolts),
+				Units:           "V",
+				Thresholds: SensorThresholds{
+					LowerCaution:  toFloat64(v.LowerThresholdNonCritical),
+					LowerCritical: toFloat64(v.LowerThresholdCritical),
+					LowerFatal:    toFloat64(v.LowerThresholdFatal),
+					UpperCaution:  toFloat64(v.UpperThresholdNonCritical),
+					UpperCritical: toFloat64(v.UpperThresholdCritical),
+					UpperFatal:    toFloat64(v.UpperThresholdFatal),
+				},
+				Health: string(v.Status.Health),
+				State:  string(v.Status.State),
+			})
+		}
+		for _, pc := range rf_power.PowerControl {
+			sensors.Power = append(sensors.Power, SensorReading{
+				URI:             baseURI + pc.ODataID,
+				Name:            pc.Name,
+				PhysicalContext: string(pc.PhysicalContext),
+				Reading:         toFloat64(pc.PowerConsumedWatts),
+				Units:           "W",
+				Health:          string(pc.Status.Health),
+				State:           string(pc.Status.State),
+			})
+		}
+		for i := range rf_power.PowerSupplies {
+			sensors.PowerSupplies = append(sensors.PowerSupplies, powerSupplyReading(&rf_power.PowerSupplies[i], baseURI))
+		}
+	}
+	return sensors
+}
+
+func sensorReadingFromSensor(rf_sensor *schemas.Sensor, baseURI string) SensorReading {
+	return SensorReading{
+		URI:             baseURI + rf_sensor.ODataID,
+		Name:            rf_sensor.Name,
+		PhysicalContext: string(rf_sensor.PhysicalContext),
+		Reading:         rf_sensor.Reading,
+		Units:           rf_sensor.ReadingUnits,
+		Thresholds: SensorThresholds{
+			LowerCaution:  rf_sensor.Thresholds.LowerCaution.Reading,
+			LowerCritical: rf_sensor.Thresholds.LowerCritical.Reading,
+			LowerFatal:    rf_sensor.Thresholds.LowerFatal.Reading,
+			UpperCaution:  rf_sensor.Thresholds.UpperCaution.Reading,
+			UpperCritical: rf_sensor.Thresholds.UpperCritical.Reading,
+			UpperFatal:    rf_sensor.Thresholds.UpperFatal.Reading,
+		},
+		Health: string(rf_sensor.Status.Health),
+		State:  string(rf_sensor.Status.State),
+	}
+}
+
+func powerSupplyReading(rf_powersupply *schemas.PowerSupply, baseURI string) PowerSupplyReading {
+	return PowerSupplyReading{
+		URI:              baseURI + rf_powersupply.ODataID,
+		Name:             rf_powersupply.Name,
+		Model:            rf_powersupply.Model,
+		Serial:           rf_powersupply.SerialNumber,
+		CapacityWatts:    toFloat64(rf_powersupply.PowerCapacityWatts),
+		InputWatts:       toFloat64(rf_powersupply.PowerInputWatts),
+		OutputWatts:      toFloat64(rf_powersupply.PowerOutputWatts),
+		LineInputVoltage: toFloat64(rf_powersupply.LineInputVoltage),
+		Health:           string(rf_powersupply.Status.Health),
+		State:            string(rf_powersupply.Status.State),
+	}
+}
+
+// addPowerSupplyMetrics fills in the readings of a PowerSubsystem power supply
+// from its linked PowerSupplyMetrics resource. gofish does not expose the
+// 'Metrics' link, so it is read from the raw JSON of the power supply.
+func addPowerSupplyMetrics(psu *PowerSupplyReading, rf_chassis *schemas.Chassis, rf_powersupply *schemas.PowerSupply) {
+	var links struct {
+		Metrics schemas.Link `json:"Metrics"`
+	}
+	if err := json.Unmarshal(rf_powersupply.RawData, &links); err != nil || links.Metrics.String() == "" {
+		return
+	}
+	rf_metrics, err := schemas.GetPowerSupplyMetrics(rf_chassis.GetClient(), links.Metrics.String())
+	if err != nil {
+		log.Warn().Err(err).Str("uri", psu.URI).Msg("failed to get power supply metrics")
+		return
+	}
+	if rf_metrics.InputPowerWatts.Reading != nil {
+		psu.InputWatts = rf_metrics.InputPowerWatts.Reading
+	}
+	if rf_metrics.OutputPowerWatts.Reading != nil {
+		psu.OutputWatts = rf_metrics.OutputPowerWatts.Reading
+	}
+	if rf_metrics.InputVoltage.Reading != nil {
+		psu.LineInputVoltage = rf_metrics.InputVoltage.Reading
+	}
+}
+
+// toFloat64 converts the numeric pointer types used by the different Redfish
+// schemas into a single *float64 while preserving nil.
+func toFloat64[T ~int | ~float32 | ~float64](v *T) *float64 {
+	if v == nil {
+		return nil
+	}
+	f := float64(*v)
+	return &f
+}
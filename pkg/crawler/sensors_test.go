@@ -0,0 +1,180 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+func serveJSON(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func TestCrawlBMCForSensors(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redfish/v1/", serveJSON(`{
+		"@odata.id": "/redfish/v1/",
+		"Id": "RootService",
+		"RedfishVersion": "1.15.0",
+		"Chassis": {"@odata.id": "/redfish/v1/Chassis"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Chassis", serveJSON(`{
+		"Members": [
+			{"@odata.id": "/redfish/v1/Chassis/Legacy"},
+			{"@odata.id": "/redfish/v1/Chassis/Modern"}
+		]
+	}`))
+
+	// chassis implementing only the deprecated Thermal and Power resources
+	mux.HandleFunc("/redfish/v1/Chassis/Legacy", serveJSON(`{
+		"@odata.id": "/redfish/v1/Chassis/Legacy",
+		"Id": "Legacy",
+		"Status": {"Health": "OK"},
+		"Thermal": {"@odata.id": "/redfish/v1/Chassis/Legacy/Thermal"},
+		"Power": {"@odata.id": "/redfish/v1/Chassis/Legacy/Power"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Chassis/Legacy/Thermal", serveJSON(`{
+		"@odata.id": "/redfish/v1/Chassis/Legacy/Thermal",
+		"Temperatures": [{
+			"@odata.id": "/redfish/v1/Chassis/Legacy/Thermal#/Temperatures/0",
+			"Name": "CPU1 Temp",
+			"PhysicalContext": "CPU",
+			"ReadingCelsius": 45,
+			"UpperThresholdCritical": 90,
+			"Status": {"Health": "OK", "State": "Enabled"}
+		}],
+		"Fans": [{
+			"@odata.id": "/redfish/v1/Chassis/Legacy/Thermal#/Fans/0",
+			"Name": "Fan1",
+			"Reading": 6000,
+			"ReadingUnits": "RPM",
+			"LowerThresholdCritical": 500,
+			"Status": {"Health": "Warning", "State": "Enabled"}
+		}]
+	}`))
+	mux.HandleFunc("/redfish/v1/Chassis/Legacy/Power", serveJSON(`{
+		"@odata.id": "/redfish/v1/Chassis/Legacy/Power",
+		"PowerControl": [{
+			"@odata.id": "/redfish/v1/Chassis/Legacy/Power#/PowerControl/0",
+			"Name": "System Power Control",
+			"PowerConsumedWatts": 344
+		}],
+		"Voltages": [{
+			"@odata.id": "/redfish/v1/Chassis/Legacy/Power#/Voltages/0",
+			"Name": "VRM1",
+			"ReadingVolts": 12.1,
+			"UpperThresholdCritical": 13.2,
+			"Status": {"Health": "OK", "State": "Enabled"}
+		}],
+		"PowerSupplies": [{
+			"@odata.id": "/redfish/v1/Chassis/Legacy/Power#/PowerSupplies/0",
+			"Name": "PSU1",
+			"PowerInputWatts": 410,
+			"PowerOutputWatts": 380,
+			"LineInputVoltage": 230,
+			"Status": {"Health": "OK", "State": "Enabled"}
+		}]
+	}`))
+
+	// chassis implementing the newer Sensors and PowerSubsystem resources
+	mux.HandleFunc("/redfish/v1/Chassis/Modern", serveJSON(`{
+		"@odata.id": "/redfish/v1/Chassis/Modern",
+		"Id": "Modern",
+		"Sensors": {"@odata.id": "/redfish/v1/Chassis/Modern/Sensors"},
+		"PowerSubsystem": {"@odata.id": "/redfish/v1/Chassis/Modern/PowerSubsystem"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Chassis/Modern/Sensors", serveJSON(`{
+		"Members": [
+			{"@odata.id": "/redfish/v1/Chassis/Modern/Sensors/Temp1"},
+			{"@odata.id": "/redfish/v1/Chassis/Modern/Sensors/Fan1"}
+		]
+	}`))
+	mux.HandleFunc("/redfish/v1/Chassis/Modern/Sensors/Temp1", serveJSON(`{
+		"@odata.id": "/redfish/v1/Chassis/Modern/Sensors/Temp1",
+		"Id": "Temp1",
+		"Name": "Inlet Temp",
+		"ReadingType": "Temperature",
+		"Reading": 22.5,
+		"ReadingUnits": "Cel",
+		"Thresholds": {"UpperCritical": {"Reading": 40}},
+		"Status": {"Health": "OK", "State": "Enabled"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Chassis/Modern/Sensors/Fan1", serveJSON(`{
+		"@odata.id": "/redfish/v1/Chassis/Modern/Sensors/Fan1",
+		"Id": "Fan1",
+		"Name": "Fan 1",
+		"ReadingType": "Rotational",
+		"Reading": 7200,
+		"ReadingUnits": "RPM",
+		"Status": {"Health": "OK", "State": "Enabled"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Chassis/Modern/PowerSubsystem", serveJSON(`{
+		"@odata.id": "/redfish/v1/Chassis/Modern/PowerSubsystem",
+		"PowerSupplies": {"@odata.id": "/redfish/v1/Chassis/Modern/PowerSubsystem/PowerSupplies"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Chassis/Modern/PowerSubsystem/PowerSupplies", serveJSON(`{
+		"Members": [{"@odata.id": "/redfish/v1/Chassis/Modern/PowerSubsystem/PowerSupplies/0"}]
+	}`))
+	mux.HandleFunc("/redfish/v1/Chassis/Modern/PowerSubsystem/PowerSupplies/0", serveJSON(`{
+		"@odata.id": "/redfish/v1/Chassis/Modern/PowerSubsystem/PowerSupplies/0",
+		"Id": "0",
+		"Name": "PSU0",
+		"Metrics": {"@odata.id": "/redfish/v1/Chassis/Modern/PowerSubsystem/PowerSupplies/0/Metrics"},
+		"Status": {"Health": "OK", "State": "Enabled"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Chassis/Modern/PowerSubsystem/PowerSupplies/0/Metrics", serveJSON(`{
+		"@odata.id": "/redfish/v1/Chassis/Modern/PowerSubsystem/PowerSupplies/0/Metrics",
+		"Id": "Metrics",
+		"InputPowerWatts": {"Reading": 520},
+		"OutputPowerWatts": {"Reading": 490},
+		"InputVoltage": {"Reading": 208}
+	}`))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	chassis, err := CrawlBMCForSensors(CrawlerConfig{
+		URI:             server.URL,
+		CredentialStore: secrets.NewStaticStore("user", "pass"),
+	})
+	require.NoError(t, err)
+	require.Len(t, chassis, 2)
+
+	byID := make(map[string]ChassisSensors, len(chassis))
+	for _, c := range chassis {
+		byID[c.ChassisID] = c
+	}
+
+	legacy := byID["Legacy"]
+	require.Equal(t, SensorSourceLegacy, legacy.Source)
+	require.Len(t, legacy.Temperatures, 1)
+	require.Equal(t, 45.0, *legacy.Temperatures[0].Reading)
+	require.Equal(t, 90.0, *legacy.Temperatures[0].Thresholds.UpperCritical)
+	require.Len(t, legacy.Fans, 1)
+	require.Equal(t, "Warning", legacy.Fans[0].Health)
+	require.Equal(t, 500.0, *legacy.Fans[0].Thresholds.LowerCritical)
+	require.Len(t, legacy.Voltages, 1)
+	require.Len(t, legacy.Power, 1)
+	require.Equal(t, 344.0, *legacy.Power[0].Reading)
+	require.Len(t, legacy.PowerSupplies, 1)
+	require.Equal(t, 410.0, *legacy.PowerSupplies[0].InputWatts)
+
+	modern := byID["Modern"]
+	require.Equal(t, SensorSourceSensors, modern.Source)
+	require.Len(t, modern.Temperatures, 1)
+	require.Equal(t, 40.0, *modern.Temperatures[0].Thresholds.UpperCritical)
+	require.Len(t, modern.Fans, 1)
+	require.Equal(t, "RPM", modern.Fans[0].Units)
+	require.Len(t, modern.PowerSupplies, 1)
+	require.Equal(t, 520.0, *modern.PowerSupplies[0].InputWatts)
+	require.Equal(t, 490.0, *modern.PowerSupplies[0].OutputWatts)
+	require.Equal(t, 208.0, *modern.PowerSupplies[0].LineInputVoltage)
+}
@@ -0,0 +1,184 @@
+// Package magellan implements the core routines for the tools.
+package idmap
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The names of the mappers that can be put in a mapper chain.
+const (
+	MapperUserMap  = "user-map"
+	MapperLocation = "location"
+	MapperTemplate = "template"
+	MapperXname    = "xname"
+)
+
+// MapperNames lists the valid mapper names of a mapper chain.
+var MapperNames = []string{MapperUserMap, MapperLocation, MapperTemplate, MapperXname}
+
+// idGenerator is implemented by mappers that can compute the ID of a
+// BMC without side effects, i.e. without logging or remembering the
+// IDs they hand out, so that a ChainMapper can try all of them.
+type idGenerator interface {
+	Mapper
+	generateID(keys *MapperKeys) (string, error)
+	// usesKey reports whether the mapper reads the key with the given
+	// MapKeys name, so that keys that are costly to find, such as the
+	// hostname, are only looked up when needed.
+	usesKey(key string) bool
+}
+
+// namedMapper is a mapper in a chain with the name it is reported by.
+// Only mappers that describe what a BMC is, rather than compute an ID
+// for any BMC, are compared to find conflicts.
+type namedMapper struct {
+	name          string
+	mapper        idGenerator
+	authoritative bool
+}
+
+// IDResult describes how the ID of a single BMC was mapped.
+type IDResult struct {
+	IPv4Addr string `json:"ipv4_addr" yaml:"ipv4_addr"`
+	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	// ID is the ID the BMC got, or would have got if it is a duplicate.
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+	// Mapper is the name of the mapper that produced the ID.
+	Mapper string `json:"mapper,omitempty" yaml:"mapper,omitempty"`
+	// Candidates are the IDs produced by each mapper of the chain.
+	Candidates map[string]string `json:"candidates,omitempty" yaml:"candidates,omitempty"`
+	// Errors are why each mapper that produced no ID failed.
+	Errors map[string]string `json:"errors,omitempty" yaml:"errors,omitempty"`
+	// DuplicateOf is the IPv4 address of the BMC that already has the ID.
+	DuplicateOf string `json:"duplicate_of,omitempty" yaml:"duplicate_of,omitempty"`
+}
+
+// IDReport lists the BMCs whose ID mapping needs attention.
+type IDReport struct {
+	// Skipped BMCs got no ID from any mapper.
+	Skipped []IDResult `json:"skipped" yaml:"skipped"`
+	// Conflicting BMCs got different IDs from the user provided map and
+	// the chassis location, and were given the one from the first of
+	// them in the chain.
+	Conflicting []IDResult `json:"conflicting" yaml:"conflicting"`
+	// Duplicate BMCs got an ID that was already given to another BMC,
+	// and were skipped.
+	Duplicate []IDResult `json:"duplicate" yaml:"duplicate"`
+}
+
+// ChainMapper tries an ordered list of mappers, e.g. a user provided
+// map, then the chassis location, then the generated XNAME, and gives
+// each BMC the ID from the first one that produces an ID. It also
+// makes sure that no two BMCs get the same ID, and records the BMCs
+// that are skipped, that the mappers disagree on or that would get a
+// duplicate ID in a report.
+type ChainMapper struct {
+	mappers  []namedMapper
+	assigned assignedIDs
+	mu       sync.Mutex
+	report   IDReport
+}
+
+func (chain *ChainMapper) Initialize() (Mapper, error) {
+	for i, named := range chain.mappers {
+		mapper, err := named.mapper.Initialize()
+		if err != nil {
+			// Don't fall back to the next mapper for every BMC
+			// because of a broken mapping, skip them all.
+			chain.mappers = nil
+			return chain, fmt.Errorf("failed to initialize '%s' BMC ID mapper: %w", named.name, err)
+		}
+		chain.mappers[i].mapper = mapper.(idGenerator)
+	}
+	return chain, nil
+}
+
+// UsesKey reports whether any mapper in the chain reads the key with
+// the given MapKeys name, e.g. MapKeyHostname, so that the caller can
+// skip looking up keys that no mapper needs.
+func (chain *ChainMapper) UsesKey(key string) bool {
+	return slices.ContainsFunc(chain.mappers, func(named namedMapper) bool {
+		return named.mapper.usesKey(key)
+	})
+}
+
+// Names returns the names of the mappers in the chain, in order.
+func (chain *ChainMapper) Names() []string {
+	names := make([]string, 0, len(chain.mappers))
+	for _, named := range chain.mappers {
+		names = append(names, named.name)
+	}
+	return names
+}
+
+func (chain *ChainMapper) GetMappedID(keys *MapperKeys) string {
+	result := IDResult{
+		IPv4Addr:   keys.IPv4Addr,
+		Hostname:   keys.Hostname,
+		Candidates: map[string]string{},
+		Errors:     map[string]string{},
+	}
+	var (
+		authoritative string // the ID from the first authoritative mapper
+		conflicting   bool
+	)
+	for _, named := range chain.mappers {
+		bmcID, err := named.mapper.generateID(keys)
+		if err != nil {
+			log.Debug().Err(err).Str("IPv4 address", keys.IPv4Addr).Str("mapper", named.name).Msg("mapper produced no BMC ID")
+			result.Errors[named.name] = err.Error()
+			continue
+		}
+		result.Candidates[named.name] = bmcID
+		if result.ID == "" {
+			result.ID = bmcID
+			result.Mapper = named.name
+		} else if named.authoritative && authoritative != "" && bmcID != authoritative {
+			conflicting = true
+		}
+		if named.authoritative && authoritative == "" {
+			authoritative = bmcID
+		}
+	}
+
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+
+	if result.ID == "" {
+		log.Warn().Str("IPv4 address", keys.IPv4Addr).Strs("mappers", chain.Names()).Msg("no mapper produced a BMC ID, skipping BMC")
+		chain.report.Skipped = append(chain.report.Skipped, result)
+		return ""
+	}
+	if conflicting {
+		log.Warn().Str("IPv4 address", keys.IPv4Addr).Any("candidates", result.Candidates).Msgf("mappers disagree on BMC ID, using '%s' from mapper '%s'", result.ID, result.Mapper)
+		chain.report.Conflicting = append(chain.report.Conflicting, result)
+	}
+	if other, ok := chain.assigned.claim(result.ID, keys.IPv4Addr); !ok {
+		log.Error().Str("IPv4 address", keys.IPv4Addr).Str("other IPv4 address", other).Str("mapper", result.Mapper).Msgf("BMC ID '%s' is already assigned to another BMC, skipping BMC", result.ID)
+		result.DuplicateOf = other
+		chain.report.Duplicate = append(chain.report.Duplicate, result)
+		return ""
+	}
+	log.Info().Str("IPv4 address", keys.IPv4Addr).Str("mapper", result.Mapper).Msgf("mapped BMC ID '%s'", result.ID)
+	return result.ID
+}
+
+// Report returns the BMCs that were skipped, that the mappers
+// disagreed on or that got a duplicate ID so far, sorted by address.
+func (chain *ChainMapper) Report() IDReport {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	byAddr := func(a, b IDResult) int {
+		return strings.Compare(a.IPv4Addr, b.IPv4Addr)
+	}
+	return IDReport{
+		Skipped:     slices.SortedFunc(slices.Values(chain.report.Skipped), byAddr),
+		Conflicting: slices.SortedFunc(slices.Values(chain.report.Conflicting), byAddr),
+		Duplicate:   slices.SortedFunc(slices.Values(chain.report.Duplicate), byAddr),
+	}
+}
@@ -0,0 +1,76 @@
+package idmap
+
+import (
+	"testing"
+
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainMapper(t *testing.T) {
+	t.Parallel()
+
+	// without a chain, the mappers are picked as before
+	require.Equal(t, []string{MapperXname}, MapperConfig{}.DefaultChain())
+	require.Equal(t, []string{MapperLocation, MapperUserMap}, MapperConfig{BMCIDMap: "{}", IDTemplate: "x", IDLocation: "xname"}.DefaultChain())
+	require.Equal(t, []string{MapperTemplate}, MapperConfig{IDTemplate: "x"}.DefaultChain())
+
+	offset := 17
+	mapper, err := PickIDMapper(MapperConfig{
+		BMCIDMap: `{"map_key": "bmc-ip-addr", "id_map": {"172.20.4.17": "x3000c0s17b0", "172.20.4.18": "x3000c0s20b0"}}`,
+		Chain:    []string{MapperUserMap, MapperLocation, MapperXname},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{MapperUserMap, MapperLocation, MapperXname}, mapper.Names())
+
+	// only the keys the mappers read are needed
+	require.True(t, mapper.UsesKey(MapKeyBMCIPAddr))
+	require.False(t, mapper.UsesKey(MapKeyHostname))
+	for _, test := range []struct {
+		config MapperConfig
+		uses   bool
+	}{
+		{MapperConfig{BMCIDMap: `{"map_key": "hostname", "id_map": {"bmc1": "x3000c0s17b0"}}`}, true},
+		{MapperConfig{IDTemplate: "{{.ShortName}}"}, true},
+		{MapperConfig{IDTemplate: "rack{{.Octet3}}-node{{.Octet4}}"}, false},
+		{MapperConfig{IDLocation: "{{.Rack}}-{{$.Hostname}}"}, true},
+		{MapperConfig{IDLocation: LocationFormatXname}, false},
+	} {
+		chain, err := PickIDMapper(test.config)
+		require.NoError(t, err)
+		require.Equal(t, test.uses, chain.UsesKey(MapKeyHostname), test.config)
+	}
+
+	// the first mapper that produces an ID wins
+	require.Equal(t, "x3000c0s17b0", mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.20.4.17", Location: &crawler.Location{Rack: "x3000", RackOffset: &offset}}))
+	require.Equal(t, "x5506c4s172b20", mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.20.4.20"}))
+	// the user map and location disagree
+	require.Equal(t, "x3000c0s20b0", mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.20.4.18", Location: &crawler.Location{Rack: "x3000", RackOffset: &offset}}))
+	// the ID from the location is already taken
+	require.Empty(t, mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.20.4.19", Location: &crawler.Location{Rack: "x3000", RackOffset: &offset}}))
+	// nothing maps an invalid address
+	require.Empty(t, mapper.GetMappedID(&MapperKeys{IPv4Addr: "bmc"}))
+
+	report := mapper.Report()
+	require.Len(t, report.Skipped, 1)
+	require.Equal(t, "bmc", report.Skipped[0].IPv4Addr)
+	require.Len(t, report.Conflicting, 1)
+	require.Equal(t, "172.20.4.18", report.Conflicting[0].IPv4Addr)
+	require.Equal(t, map[string]string{MapperUserMap: "x3000c0s20b0", MapperLocation: "x3000c0s17b0", MapperXname: "x5506c4s172b18"}, report.Conflicting[0].Candidates)
+	require.Len(t, report.Duplicate, 1)
+	require.Equal(t, "172.20.4.19", report.Duplicate[0].IPv4Addr)
+	require.Equal(t, "172.20.4.17", report.Duplicate[0].DuplicateOf)
+
+	// invalid or unconfigured mappers, and maps with invalid entries, are
+	// rejected, and skip every BMC rather than falling back to the next one
+	for _, config := range []MapperConfig{
+		{Chain: []string{"ip"}},
+		{Chain: []string{MapperUserMap, MapperXname}},
+		{Chain: []string{MapperTemplate, MapperXname}, IDTemplate: "{{.Cabinet}}"},
+		{Chain: []string{MapperUserMap}, BMCIDMap: `{"map_key": "bmc-ip-addr", "id_map": {"172.20.4.17": "x3000c0s17b0", "172.20.4.18": "x3000c0s17b0"}}`},
+	} {
+		mapper, err := PickIDMapper(config)
+		require.Error(t, err, config.Chain)
+		require.Empty(t, mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.20.4.17"}))
+	}
+}
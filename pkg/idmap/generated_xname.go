@@ -2,6 +2,8 @@
 package idmap
 
 import (
+	"fmt"
+
 	"github.com/Cray-HPE/hms-xname/xnames"
 	"github.com/OpenCHAMI/magellan/internal/util"
 	"github.com/rs/zerolog/log"
@@ -49,8 +51,22 @@ func (mapper generatedXNAMEMapper) Initialize() (Mapper, error) {
 	return mapper, nil
 }
 
-func (mapper generatedXNAMEMapper) GetMappedID(keys *MapperKeys) string {
+func (mapper generatedXNAMEMapper) generateID(keys *MapperKeys) (string, error) {
 	ipAddrInt, err := util.IPAddrStrToInt(keys.IPv4Addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate XNAME from IP address: %w", err)
+	}
+	return ipAddrIntToXname(ipAddrInt), nil
+}
+
+// usesKey reports whether the key is the IPv4 address, which is all
+// the XNAME is generated from.
+func (mapper generatedXNAMEMapper) usesKey(key string) bool {
+	return key == MapKeyBMCIPAddr
+}
+
+func (mapper generatedXNAMEMapper) GetMappedID(keys *MapperKeys) string {
+	bmcID, err := mapper.generateID(keys)
 	if err != nil {
 		log.Error().Err(err).Str("IPv4 address", keys.IPv4Addr).Msg("failed to generate XNAME from IP address")
 		// Failed to translate the IP into an XNAME for some
@@ -59,5 +75,5 @@ func (mapper generatedXNAMEMapper) GetMappedID(keys *MapperKeys) string {
 		// this BMC.
 		return ""
 	}
-	return ipAddrIntToXname(ipAddrInt)
+	return bmcID
 }
@@ -2,7 +2,12 @@
 package idmap
 
 import (
+	"fmt"
+	"net"
+	"strings"
+
 	"github.com/OpenCHAMI/magellan/internal/format"
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
 	"github.com/rs/zerolog/log"
 )
 
@@ -14,13 +19,73 @@ import (
 // Implementations of specific ID mappers are found in files with the
 // prefix 'idmap_' in this directory. To extend the BMC ID Mapping
 // capability, create a new mapper in its own file, and then add the
-// mapper selection logic for your new mapper to pickIDMapper() here.
+// mapper selection logic for your new mapper to PickIDMapper() here.
+// Mappers implement idGenerator so they can be part of a ChainMapper.
 
 // The structure passed into the GetMappedID() function as the key
-// options for a mapper. Currently only contains the IPv4 address of
-// the BMC in question.
+// options for a mapper. The IPv4 address and hostname are known before
+// the BMC is crawled, the remaining keys only after crawling and may
+// be empty if the BMC does not report them.
 type MapperKeys struct {
-	IPv4Addr string
+	IPv4Addr      string
+	Hostname      string
+	BMCMACAddr    string
+	ManagerUUID   string
+	ChassisSerial string
+	Location      *crawler.Location
+}
+
+// The names of the keys that can be used as the 'map_key' of a user
+// provided mapping.
+const (
+	MapKeyBMCIPAddr     = "bmc-ip-addr"
+	MapKeyBMCMACAddr    = "bmc-mac-addr"
+	MapKeyManagerUUID   = "manager-uuid"
+	MapKeyChassisSerial = "chassis-serial"
+	MapKeyHostname      = "hostname"
+)
+
+// MapKeys lists the valid 'map_key' names.
+var MapKeys = []string{MapKeyBMCIPAddr, MapKeyBMCMACAddr, MapKeyManagerUUID, MapKeyChassisSerial, MapKeyHostname}
+
+// Get returns the value of the key named by 'mapKey', normalized with
+// NormalizeKey(), and whether the name is valid.
+func (keys *MapperKeys) Get(mapKey string) (string, bool) {
+	var value string
+	switch mapKey {
+	case MapKeyBMCIPAddr:
+		value = keys.IPv4Addr
+	case MapKeyBMCMACAddr:
+		value = keys.BMCMACAddr
+	case MapKeyManagerUUID:
+		value = keys.ManagerUUID
+	case MapKeyChassisSerial:
+		value = keys.ChassisSerial
+	case MapKeyHostname:
+		value = keys.Hostname
+	default:
+		return "", false
+	}
+	return NormalizeKey(mapKey, value), true
+}
+
+// NormalizeKey puts the value of a key into a canonical form so that
+// values reported by a BMC match those written in a mapping
+// regardless of case or MAC address notation.
+func NormalizeKey(mapKey string, value string) string {
+	value = strings.TrimSpace(value)
+	switch mapKey {
+	case MapKeyBMCMACAddr:
+		if mac, err := net.ParseMAC(value); err == nil {
+			return mac.String()
+		}
+		return strings.ToLower(value)
+	case MapKeyManagerUUID:
+		return strings.ToLower(value)
+	case MapKeyHostname:
+		return strings.ToLower(strings.TrimSuffix(value, "."))
+	}
+	return value
 }
 
 type Mapper interface {
@@ -28,39 +93,103 @@ type Mapper interface {
 	GetMappedID(keys *MapperKeys) string
 }
 
-// Select the correct BMC ID Mapper based on the parameters to
-// 'collect'.
-// func PickIDMapper(params *magellan.CollectParams) idMapper {
-func PickIDMapper(bmcIDMap string, idMapFormat format.DataFormat) Mapper {
+// MapperConfig holds the parameters to 'collect' that select and
+// configure the BMC ID Mappers.
+type MapperConfig struct {
+	BMCIDMap    string            // the user provided BMC ID Map, or @<path> to a file with it
+	IDMapFormat format.DataFormat // the format of the BMC ID Map if not given by the file extension
+	IDTemplate  string            // the Go template of the templateMapper
+	IDLocation  string            // the format of IDs built from the chassis location ('xname' or a Go template)
+	Chain       []string          // the names of the mappers to try in order, one of MapperNames each
+}
+
+// DefaultChain returns the mappers tried when no chain is configured:
+// the chassis location if set, followed by exactly one of the user
+// provided map, the ID template or the generated XNAME.
+func (config MapperConfig) DefaultChain() []string {
 	// If the parameters contain a BMC ID Map (user defined
 	// mapping of a key to a BMC ID) then we use the userProvidedMapper
-	// implementaiton of an ID Mapper. Until other BMC ID schemes
-	// are implemented, the other case is simply to use the
-	// generated XNAME mapper, generatedXNAMEMapper.
+	// implementaiton of an ID Mapper. Otherwise, an ID template
+	// selects the templateMapper, and the other case is simply to
+	// use the generated XNAME mapper, generatedXNAMEMapper. Building
+	// IDs from the location of the chassis takes precedence over all
+	// of these, which are then only used for BMCs without a location.
+	var chain []string
+	if config.IDLocation != "" {
+		chain = append(chain, MapperLocation)
+	}
+	switch {
+	case config.BMCIDMap != "":
+		chain = append(chain, MapperUserMap)
+	case config.IDTemplate != "":
+		chain = append(chain, MapperTemplate)
+	default:
+		chain = append(chain, MapperXname)
+	}
+	return chain
+}
+
+// Select the correct BMC ID Mappers based on the parameters to
+// 'collect', and chain them in the configured order. An error is
+// returned if a mapper is unknown or misconfigured, or fails to
+// initialize, e.g. because the BMC ID Map has invalid or duplicate
+// entries, so that no BMC is contacted with a chain that would skip
+// all of them.
+// func PickIDMapper(params *magellan.CollectParams) idMapper {
+func PickIDMapper(config MapperConfig) (*ChainMapper, error) {
 	var (
-		mapper     Mapper
-		mapperName string
-		err        error
+		chain = &ChainMapper{}
+		names = config.Chain
+		err   error
 	)
+	if len(names) == 0 {
+		names = config.DefaultChain()
+	}
 
-	if bmcIDMap != "" {
-		// Always use the user provided mapper if a user
-		// provided map is present.
-		mapperName = "userProvidedMapper"
-		mapper = userProvidedMapper{
-			IDMapStr:    bmcIDMap,
-			IDMapFormat: idMapFormat,
+	for _, name := range names {
+		var mapper idGenerator
+		switch name {
+		case MapperUserMap:
+			if config.BMCIDMap == "" {
+				err = fmt.Errorf("mapper '%s' requires a BMC ID Map", name)
+			}
+			mapper = userProvidedMapper{
+				IDMapStr:    config.BMCIDMap,
+				IDMapFormat: config.IDMapFormat,
+			}
+		case MapperLocation:
+			idLocation := config.IDLocation
+			if idLocation == "" {
+				idLocation = LocationFormatXname
+			}
+			mapper = &locationMapper{Format: idLocation}
+		case MapperTemplate:
+			if config.IDTemplate == "" {
+				err = fmt.Errorf("mapper '%s' requires an ID template", name)
+			}
+			mapper = &templateMapper{TemplateStr: config.IDTemplate}
+		case MapperXname:
+			mapper = generatedXNAMEMapper{}
+		default:
+			err = fmt.Errorf("invalid BMC ID mapper '%s', valid values are: %s", name, strings.Join(MapperNames, ", "))
 		}
+		if err != nil {
+			break
+		}
+		chain.mappers = append(chain.mappers, namedMapper{
+			name:          name,
+			mapper:        mapper,
+			authoritative: name == MapperUserMap || name == MapperLocation,
+		})
+	}
+	if err == nil {
+		_, err = chain.Initialize()
 	} else {
-		// Currently the only other mapper is the generated
-		// XNAMEs mapper, since no user provided mapper was
-		// offered, use that instead.
-		mapperName = "generatedXNAMEMapper"
-		mapper = generatedXNAMEMapper{}
+		chain.mappers = nil
 	}
-	mapper, err = mapper.Initialize()
 	if err != nil {
-		log.Error().Err(err).Str("Mapper Name", mapperName).Msg("failed to initialized BMC ID Mapper")
+		return chain, fmt.Errorf("failed to initialize BMC ID mapper chain %s: %w", strings.Join(names, ","), err)
 	}
-	return mapper
+	log.Debug().Strs("Mapper Chain", names).Msg("initialized BMC ID Mapper")
+	return chain, nil
 }
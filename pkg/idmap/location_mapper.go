@@ -0,0 +1,121 @@
+// Package magellan implements the core routines for the tools.
+package idmap
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/Cray-HPE/hms-xname/xnames"
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
+	"github.com/rs/zerolog/log"
+)
+
+// LocationFormatXname selects XNAMEs as the IDs built by the
+// locationMapper instead of a template.
+const LocationFormatXname = "xname"
+
+// locationMapper builds BMC IDs from the Redfish Location that the
+// chassis reports, so that the physical location of a BMC comes from
+// the hardware rather than from its IP address. BMCs whose chassis
+// don't report enough of their location get their ID from the next
+// mapper in the chain instead (see ChainMapper).
+//
+// In the 'xname' format, a PartLocation.ServiceLabel that is already a
+// node or BMC XNAME is used as is. Otherwise, the XNAME is built as
+// x<Rack>c0s<RackOffset>b<LocationOrdinalValue>, where the cabinet is
+// the number in Placement.Rack (e.g. 3000 in 'x3000' or 'R3000') and
+// the BMC defaults to 0. Any other format is an ID template, and
+// fields used with 'required' that the chassis doesn't report select
+// the next mapper.
+type locationMapper struct {
+	Format   string
+	template *templateMapper
+	assigned assignedIDs
+}
+
+// rackNumberRegex matches the cabinet number at the end of a rack name.
+var rackNumberRegex = regexp.MustCompile(`^\D*(\d+)$`)
+
+// locationToXname builds the XNAME of a BMC from the location of its
+// chassis.
+func locationToXname(location *crawler.Location) (string, error) {
+	if location.ServiceLabel != "" {
+		switch xname := xnames.FromString(location.ServiceLabel).(type) {
+		case xnames.NodeBMC:
+			return xname.String(), nil
+		case xnames.Node:
+			return xname.Parent().String(), nil
+		}
+	}
+
+	if location.Rack == "" {
+		return "", fmt.Errorf("chassis does not report Location.Placement.Rack")
+	}
+	matches := rackNumberRegex.FindStringSubmatch(location.Rack)
+	if matches == nil {
+		return "", fmt.Errorf("rack '%s' does not end in a cabinet number", location.Rack)
+	}
+	cabinet, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid cabinet number in rack '%s': %w", location.Rack, err)
+	}
+	if location.RackOffset == nil {
+		return "", fmt.Errorf("chassis does not report Location.Placement.RackOffset")
+	}
+	xname := xnames.NodeBMC{
+		Cabinet:       cabinet,
+		ComputeModule: *location.RackOffset,
+	}
+	if location.LocationOrdinalValue != nil {
+		xname.NodeBMC = *location.LocationOrdinalValue
+	}
+	if err := xname.Validate(); err != nil {
+		return "", fmt.Errorf("invalid XNAME from location: %w", err)
+	}
+	return xname.String(), nil
+}
+
+func (mapper *locationMapper) Initialize() (Mapper, error) {
+	if mapper.Format != LocationFormatXname {
+		template, err := (&templateMapper{TemplateStr: mapper.Format}).Initialize()
+		if err != nil {
+			return mapper, err
+		}
+		mapper.template = template.(*templateMapper)
+	}
+	return mapper, nil
+}
+
+func (mapper *locationMapper) generateID(keys *MapperKeys) (string, error) {
+	switch {
+	case keys.Location == nil:
+		return "", fmt.Errorf("chassis does not report a location")
+	case mapper.template != nil:
+		return mapper.template.generateID(keys)
+	default:
+		return locationToXname(keys.Location)
+	}
+}
+
+// usesKey reports whether the ID template, if any, uses the key, since
+// XNAMEs are only built from the location.
+func (mapper *locationMapper) usesKey(key string) bool {
+	return mapper.template != nil && mapper.template.usesKey(key)
+}
+
+func (mapper *locationMapper) GetMappedID(keys *MapperKeys) string {
+	bmcID, err := mapper.generateID(keys)
+	if err != nil {
+		log.Warn().Err(err).Str("IPv4 address", keys.IPv4Addr).Msg("failed to build BMC ID from location, skipping BMC")
+		return ""
+	}
+
+	// Two chassis reporting the same location is a hardware
+	// configuration error, so don't guess which one is right.
+	if other, ok := mapper.assigned.claim(bmcID, keys.IPv4Addr); !ok {
+		log.Error().Str("IPv4 address", keys.IPv4Addr).Str("other IPv4 address", other).Msgf("BMC ID '%s' from location is already assigned to another BMC, skipping BMC", bmcID)
+		return ""
+	}
+	return bmcID
+}
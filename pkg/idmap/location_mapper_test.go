@@ -0,0 +1,45 @@
+package idmap
+
+import (
+	"testing"
+
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocationMapper(t *testing.T) {
+	t.Parallel()
+
+	offset, ordinal := 17, 2
+	tests := []struct {
+		format   string
+		location *crawler.Location
+		id       string
+	}{
+		{LocationFormatXname, &crawler.Location{Rack: "x3000", RackOffset: &offset}, "x3000c0s17b0"},
+		{LocationFormatXname, &crawler.Location{Rack: "R3000", RackOffset: &offset, LocationOrdinalValue: &ordinal}, "x3000c0s17b2"},
+		{LocationFormatXname, &crawler.Location{ServiceLabel: "x1000c1s7b1n0", Rack: "R3000", RackOffset: &offset}, "x1000c1s7b1"},
+		{`{{.Row}}-{{required "rack" .Rack}}-u{{.RackOffset}}`, &crawler.Location{Row: "A", Rack: "12", RackOffset: &offset}, "A-12-u17"},
+		// BMCs with missing location properties are left to the
+		// next mapper in the chain
+		{LocationFormatXname, nil, ""},
+		{LocationFormatXname, &crawler.Location{Rack: "x3000"}, ""},
+		{LocationFormatXname, &crawler.Location{Rack: "blue", RackOffset: &offset}, ""},
+		{`{{required "service label" .ServiceLabel}}`, &crawler.Location{Rack: "12"}, ""},
+	}
+	for _, test := range tests {
+		mapper, err := (&locationMapper{Format: test.format}).Initialize()
+		require.NoError(t, err, test.format)
+		require.Equal(t, test.id, mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.20.4.17", Location: test.location}), test.format)
+	}
+
+	mapper, err := (&locationMapper{Format: LocationFormatXname}).Initialize()
+	require.NoError(t, err)
+
+	// two BMCs in the same location don't both get the ID
+	require.Equal(t, "x3000c0s17b0", mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.20.4.18", Location: &crawler.Location{Rack: "x3000", RackOffset: &offset}}))
+	require.Empty(t, mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.20.4.19", Location: &crawler.Location{Rack: "x3000", RackOffset: &offset}}))
+
+	_, err = (&locationMapper{Format: `{{.Cabinet}}`}).Initialize()
+	require.Error(t, err)
+}
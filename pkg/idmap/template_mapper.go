@@ -0,0 +1,200 @@
+// Package magellan implements the core routines for the tools.
+package idmap
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
+	"github.com/rs/zerolog/log"
+)
+
+// templateMapper builds BMC IDs from a Go text/template, e.g.
+// 'rack{{.Octet3}}-node{{.Octet4}}', executed over templateData. It
+// remembers the IDs it handed out so that two BMCs never get the same
+// ID within a run.
+type templateMapper struct {
+	TemplateStr string
+	template    *template.Template
+	assigned    assignedIDs
+}
+
+// assignedIDs records the BMC IDs handed out by a mapper, so that two
+// BMCs with the same ID don't overwrite each other in SMD.
+type assignedIDs struct {
+	mu  sync.Mutex
+	ids map[string]string // ID -> IPv4 address of the BMC it was assigned to
+}
+
+// claim assigns an ID to the BMC with the given IPv4 address unless it
+// is already assigned to another BMC, whose address is then returned.
+func (assigned *assignedIDs) claim(id string, ipv4Addr string) (string, bool) {
+	assigned.mu.Lock()
+	defer assigned.mu.Unlock()
+	if other, ok := assigned.ids[id]; ok && other != ipv4Addr {
+		return other, false
+	}
+	if assigned.ids == nil {
+		assigned.ids = map[string]string{}
+	}
+	assigned.ids[id] = ipv4Addr
+	return ipv4Addr, true
+}
+
+// templateData holds the fields available to an ID template. Numbers
+// the BMC does not report are -1, and strings are empty; use the
+// 'required' function to skip BMCs that lack a field.
+type templateData struct {
+	IP           string // IPv4 address of the BMC
+	Octet1       int    // first octet of the IPv4 address
+	Octet2       int
+	Octet3       int
+	Octet4       int
+	Hostname     string // full hostname of the BMC
+	ShortName    string // hostname up to the first dot
+	MAC          string // MAC address of the BMC
+	UUID         string // UUID of the BMC's Redfish Manager
+	Serial       string // serial number of the chassis
+	Rack         string // Location.Placement.Rack of the chassis
+	Row          string // Location.Placement.Row of the chassis
+	RackOffset   int    // Location.Placement.RackOffset of the chassis
+	Slot         int    // Location.PartLocation.LocationOrdinalValue of the chassis
+	ServiceLabel string // Location.PartLocation.ServiceLabel of the chassis
+}
+
+// templateFuncs are the functions available to an ID template besides
+// the text/template builtins.
+var templateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	// required fails the template, and so skips the BMC, if the value
+	// is an empty string or a negative (unknown) number.
+	"required": func(name string, value any) (any, error) {
+		switch v := value.(type) {
+		case nil:
+			return nil, fmt.Errorf("%s is not set", name)
+		case string:
+			if v == "" {
+				return nil, fmt.Errorf("%s is not set", name)
+			}
+		case int:
+			if v < 0 {
+				return nil, fmt.Errorf("%s is not set", name)
+			}
+		}
+		return value, nil
+	},
+}
+
+func newTemplateData(keys *MapperKeys) templateData {
+	data := templateData{
+		IP:         keys.IPv4Addr,
+		Octet1:     -1,
+		Octet2:     -1,
+		Octet3:     -1,
+		Octet4:     -1,
+		Hostname:   strings.TrimSuffix(keys.Hostname, "."),
+		MAC:        NormalizeKey(MapKeyBMCMACAddr, keys.BMCMACAddr),
+		UUID:       keys.ManagerUUID,
+		Serial:     keys.ChassisSerial,
+		RackOffset: -1,
+		Slot:       -1,
+	}
+	if ip := net.ParseIP(keys.IPv4Addr).To4(); ip != nil {
+		data.Octet1, data.Octet2, data.Octet3, data.Octet4 = int(ip[0]), int(ip[1]), int(ip[2]), int(ip[3])
+	}
+	data.ShortName, _, _ = strings.Cut(data.Hostname, ".")
+	if location := keys.Location; location != nil {
+		data.Rack = location.Rack
+		data.Row = location.Row
+		data.ServiceLabel = location.ServiceLabel
+		if location.RackOffset != nil {
+			data.RackOffset = *location.RackOffset
+		}
+		if location.LocationOrdinalValue != nil {
+			data.Slot = *location.LocationOrdinalValue
+		}
+	}
+	return data
+}
+
+func (mapper *templateMapper) Initialize() (Mapper, error) {
+	tmpl, err := template.New("id").Funcs(templateFuncs).Parse(mapper.TemplateStr)
+	if err != nil {
+		return mapper, fmt.Errorf("failed to parse ID template '%s': %w", mapper.TemplateStr, err)
+	}
+
+	// Catch references to fields that don't exist now rather than
+	// for every BMC, by executing the template over a fully
+	// populated set of keys.
+	var sample strings.Builder
+	offset, ordinal := 1, 1
+	err = tmpl.Execute(&sample, newTemplateData(&MapperKeys{
+		IPv4Addr:      "172.21.0.1",
+		Hostname:      "bmc.example.com",
+		BMCMACAddr:    "00:00:00:00:00:01",
+		ManagerUUID:   "00000000-0000-0000-0000-000000000001",
+		ChassisSerial: "serial",
+		Location:      &crawler.Location{Rack: "rack", Row: "row", RackOffset: &offset, ServiceLabel: "label", LocationOrdinalValue: &ordinal},
+	}))
+	if err != nil {
+		return mapper, fmt.Errorf("invalid ID template '%s': %w", mapper.TemplateStr, err)
+	}
+
+	mapper.template = tmpl
+	return mapper, nil
+}
+
+// templateKeyFields maps the names of the keys to the templateData
+// fields that are built from them.
+var templateKeyFields = map[string]*regexp.Regexp{
+	MapKeyBMCIPAddr:     regexp.MustCompile(`\.(IP|Octet[1-4])\b`),
+	MapKeyBMCMACAddr:    regexp.MustCompile(`\.MAC\b`),
+	MapKeyManagerUUID:   regexp.MustCompile(`\.UUID\b`),
+	MapKeyChassisSerial: regexp.MustCompile(`\.Serial\b`),
+	MapKeyHostname:      regexp.MustCompile(`\.(Hostname|ShortName)\b`),
+}
+
+// usesKey reports whether the template refers to any of the fields
+// built from the key.
+func (mapper *templateMapper) usesKey(key string) bool {
+	fields, ok := templateKeyFields[key]
+	return ok && fields.MatchString(mapper.TemplateStr)
+}
+
+// generateID executes the template for a BMC, failing if a required
+// field is missing or the resulting ID is empty.
+func (mapper *templateMapper) generateID(keys *MapperKeys) (string, error) {
+	if mapper.template == nil {
+		return "", fmt.Errorf("ID template is missing")
+	}
+	var id strings.Builder
+	if err := mapper.template.Execute(&id, newTemplateData(keys)); err != nil {
+		return "", err
+	}
+	bmcID := strings.TrimSpace(id.String())
+	if bmcID == "" {
+		return "", fmt.Errorf("ID template produced an empty BMC ID")
+	}
+	return bmcID, nil
+}
+
+func (mapper *templateMapper) GetMappedID(keys *MapperKeys) string {
+	bmcID, err := mapper.generateID(keys)
+	if err != nil {
+		log.Warn().Err(err).Str("IPv4 address", keys.IPv4Addr).Msg("failed to generate BMC ID from template, skipping BMC")
+		return ""
+	}
+
+	// Two BMCs with the same ID would overwrite each other in SMD,
+	// so only the first one gets it.
+	if other, ok := mapper.assigned.claim(bmcID, keys.IPv4Addr); !ok {
+		log.Error().Str("IPv4 address", keys.IPv4Addr).Str("other IPv4 address", other).Msgf("BMC ID '%s' from template is already assigned to another BMC, skipping BMC", bmcID)
+		return ""
+	}
+	return bmcID
+}
@@ -0,0 +1,73 @@
+package idmap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateMapper(t *testing.T) {
+	t.Parallel()
+
+	slot := 3
+	keys := &MapperKeys{
+		IPv4Addr:      "172.21.4.17",
+		Hostname:      "bmc17.example.com.",
+		BMCMACAddr:    "A4-BF-01-5C-29-10",
+		ChassisSerial: "CN7475166M0123",
+		Location:      &crawler.Location{Rack: "R12", LocationOrdinalValue: &slot},
+	}
+
+	tests := []struct {
+		template string
+		id       string
+	}{
+		{`rack{{.Octet3}}-node{{.Octet4}}`, "rack4-node17"},
+		{`{{.ShortName}}`, "bmc17"},
+		{`{{lower .Rack}}-s{{printf "%02d" .Slot}}`, "r12-s03"},
+		{`{{.Serial}}-{{.MAC}}`, "CN7475166M0123-a4:bf:01:5c:29:10"},
+	}
+	for _, test := range tests {
+		mapper, err := (&templateMapper{TemplateStr: test.template}).Initialize()
+		require.NoError(t, err, test.template)
+		require.Equal(t, test.id, mapper.GetMappedID(keys), test.template)
+	}
+
+	// missing required fields skip the BMC
+	mapper, err := (&templateMapper{TemplateStr: `{{required "rack offset" .RackOffset}}`}).Initialize()
+	require.NoError(t, err)
+	require.Empty(t, mapper.GetMappedID(keys))
+
+	// unknown fields and syntax errors are caught up front
+	_, err = (&templateMapper{TemplateStr: `{{.Cabinet}}`}).Initialize()
+	require.Error(t, err)
+	_, err = (&templateMapper{TemplateStr: `{{.Octet4`}).Initialize()
+	require.Error(t, err)
+
+	// no two BMCs get the same ID, even when mapped concurrently, but
+	// the same BMC can be mapped again
+	mapper, err = (&templateMapper{TemplateStr: `rack{{.Octet3}}`}).Initialize()
+	require.NoError(t, err)
+	var (
+		ids = make([]string, 8)
+		wg  sync.WaitGroup
+	)
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids[i] = mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.21.4." + string(rune('1'+i))})
+		}()
+	}
+	wg.Wait()
+	var assigned []string
+	for _, id := range ids {
+		if id != "" {
+			assigned = append(assigned, id)
+		}
+	}
+	require.Equal(t, []string{"rack4"}, assigned)
+	require.Equal(t, "rack4", mapper.GetMappedID(&MapperKeys{IPv4Addr: mapper.(*templateMapper).assigned.ids["rack4"]}))
+}
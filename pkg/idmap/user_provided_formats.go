@@ -0,0 +1,139 @@
+// Package magellan implements the core routines for the tools.
+package idmap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// This file reads BMC ID Maps that are not in the JSON or YAML form of
+// bmcIDMap, such as exports of the spreadsheets sites keep their
+// mappings in and /etc/hosts-style files.
+
+// The names of the ID column of a CSV BMC ID Map. An 'xname' column
+// puts the map in XNAME mode.
+const (
+	csvColumnID    = "id"
+	csvColumnXname = "xname"
+)
+
+// isCSVIDMap reports whether a BMC ID Map file is a CSV file, by its
+// extension.
+func isCSVIDMap(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".csv")
+}
+
+// isHostsIDMap reports whether a BMC ID Map file is in /etc/hosts
+// format, i.e. is named 'hosts' or has the extension '.hosts'.
+func isHostsIDMap(path string) bool {
+	return filepath.Base(path) == "hosts" || strings.EqualFold(filepath.Ext(path), ".hosts")
+}
+
+// parseCSVIDMap reads a BMC ID Map from CSV with a header row. The
+// first column named after one of the MapKeys, e.g. 'bmc-mac-addr', is
+// the key of the map, and the column named 'id' or 'xname' holds the
+// IDs. Other columns are ignored, so that a spreadsheet can be
+// exported as is. Lines starting with '#' are comments.
+func parseCSVIDMap(input []byte, path string) (*bmcIDMap, error) {
+	reader := csv.NewReader(bytes.NewReader(input))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header of CSV BMC ID Map '%s': %w", path, err)
+	}
+	var (
+		idMap  = bmcIDMap{source: path, IDMap: map[string]string{}}
+		keyCol = -1
+		idCol  = -1
+	)
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		switch {
+		case keyCol < 0 && slices.Contains(MapKeys, column):
+			keyCol = i
+			idMap.MapKey = column
+		case idCol < 0 && column == csvColumnID:
+			idCol = i
+		case idCol < 0 && column == csvColumnXname:
+			idCol = i
+			idMap.IDFormat = IDFormatXname
+		}
+	}
+	if keyCol < 0 {
+		return nil, fmt.Errorf("%s:1: CSV BMC ID Map has no key column, expected one of: %s", path, strings.Join(MapKeys, ", "))
+	}
+	if idCol < 0 {
+		return nil, fmt.Errorf("%s:1: CSV BMC ID Map has no '%s' or '%s' column", path, csvColumnID, csvColumnXname)
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// csv.ParseError already includes the line
+			return nil, fmt.Errorf("failed to read CSV BMC ID Map '%s': %w", path, err)
+		}
+		key, id := strings.TrimSpace(record[keyCol]), strings.TrimSpace(record[idCol])
+		if key == "" && id == "" {
+			// spreadsheets often export rows with no mapping
+			continue
+		}
+		line, _ := reader.FieldPos(keyCol)
+		idMap.entries = append(idMap.entries, idMapEntry{key: key, id: id, line: line})
+		idMap.IDMap[key] = id
+	}
+	return &idMap, nil
+}
+
+// parseHostsIDMap reads a BMC ID Map in /etc/hosts format, mapping the
+// IPv4 address at the start of each line to the first name after it.
+// Comments, aliases, and IPv6 and loopback addresses are ignored.
+func parseHostsIDMap(input []byte, path string) (*bmcIDMap, error) {
+	var (
+		idMap   = bmcIDMap{source: path, MapKey: MapKeyBMCIPAddr, IDMap: map[string]string{}}
+		scanner = bufio.NewScanner(bytes.NewReader(input))
+		line    int
+	)
+	for scanner.Scan() {
+		line++
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if ip := net.ParseIP(fields[0]); ip == nil {
+			return nil, fmt.Errorf("%s:%d: invalid IP address '%s'", path, line, fields[0])
+		} else if ip.To4() == nil {
+			log.Debug().Str("path", path).Int("line", line).Msg("skipping IPv6 address in hosts BMC ID Map")
+			continue
+		} else if ip.IsLoopback() {
+			// no BMC is at a loopback address, and names such as
+			// 'localhost' would fail validation as XNAMEs
+			log.Debug().Str("path", path).Int("line", line).Msg("skipping loopback address in hosts BMC ID Map")
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: no name for address '%s'", path, line, fields[0])
+		}
+		idMap.entries = append(idMap.entries, idMapEntry{key: fields[0], id: fields[1], line: line})
+		idMap.IDMap[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read hosts BMC ID Map '%s': %w", path, err)
+	}
+	return &idMap, nil
+}
@@ -0,0 +1,62 @@
+package idmap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVAndHostsIDMaps(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name string, contents string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+		return "@" + path
+	}
+
+	// the header picks the key and ID columns, other columns are ignored
+	data := write("map.csv", `# exported from the rack spreadsheet
+Rack,BMC-MAC-Addr,Bmc-Ip-Addr,XName
+R1,A4-BF-01-5C-29-10,172.21.0.1,x1000c0s0b03
+R1,a4:bf:01:5c:29:11,172.21.0.2,x1000c0s1b0
+,,,
+`)
+	output, err := ConvertBMCIDMap(data, "")
+	require.NoError(t, err)
+	require.Equal(t, `map_key: bmc-mac-addr
+id_format: xname
+id_map:
+    a4:bf:01:5c:29:10: x1000c0s0b3
+    a4:bf:01:5c:29:11: x1000c0s1b0
+`, string(output))
+	mapper, err := userProvidedMapper{IDMapStr: data}.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, "x1000c0s1b0", mapper.GetMappedID(&MapperKeys{BMCMACAddr: "A4:BF:01:5C:29:11"}))
+
+	_, err = ConvertBMCIDMap(write("nokey.csv", "rack,id\nR1,x1000c0s0b0\n"), "")
+	require.ErrorContains(t, err, "nokey.csv:1: CSV BMC ID Map has no key column")
+	_, err = ConvertBMCIDMap(write("dup.csv", "hostname,id\nbmc1,node1\nBMC1.,node2\n"), "")
+	require.ErrorContains(t, err, "dup.csv:3: duplicate key 'BMC1.', already mapped on line 2")
+
+	// hosts files map IPv4 addresses other than loopback to the first name
+	data = write("bmcs.hosts", `127.0.0.1 localhost
+::1       localhost ip6-localhost
+172.21.0.1  x1000c0s0b0   bmc1   # first BMC
+172.21.0.2	x1000c0s1b0
+`)
+	output, err = ConvertBMCIDMap(data, "")
+	require.NoError(t, err)
+	require.Equal(t, `map_key: bmc-ip-addr
+id_format: xname
+id_map:
+    172.21.0.1: x1000c0s0b0
+    172.21.0.2: x1000c0s1b0
+`, string(output))
+
+	_, err = ConvertBMCIDMap(write("hosts", "172.21.0.1 x1000c0s0b0\n172.21.0.2\n"), "")
+	require.ErrorContains(t, err, "hosts:2: no name for address '172.21.0.2'")
+}
@@ -3,9 +3,15 @@ package idmap
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
 
+	"github.com/Cray-HPE/hms-xname/xnametypes"
 	"github.com/OpenCHAMI/magellan/internal/format"
 	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v3"
@@ -13,11 +19,67 @@ import (
 
 // bmcIDMap contains the mapping of host address strings to BMC Identifiers
 // supplied by the --bmc-id-map option to collect. IDMap is the mapping itself,
-// MapKey specifies what string to use as the key to the map. For now, that is
-// always 'bmc-ip-addr'. In the future other options may be available.
+// MapKey specifies what string to use as the key to the map, one of the
+// names in MapKeys. IDFormat is IDFormatXname if the IDs must be valid
+// XNAMEs of BMCs, IDFormatAny for any IDs, or empty to validate them as
+// XNAMEs if any of them looks like one.
 type bmcIDMap struct {
-	IDMap  map[string]string `json:"id_map" yaml:"id_map"`
-	MapKey string            `json:"map_key" yaml:"map_key"`
+	MapKey   string            `json:"map_key" yaml:"map_key"`
+	IDFormat string            `json:"id_format,omitempty" yaml:"id_format,omitempty"`
+	IDMap    map[string]string `json:"id_map" yaml:"id_map"`
+
+	source  string       // the file the map was read from, for errors
+	entries []idMapEntry // the entries of 'id_map' in the order they were written
+}
+
+// IDFormatXname is the 'id_format' of a BMC ID Map whose IDs are
+// validated and normalized as XNAMEs, and IDFormatAny that of a map
+// whose IDs are used as they are.
+const (
+	IDFormatXname = "xname"
+	IDFormatAny   = "any"
+)
+
+// xnamePrefix matches IDs that start like an XNAME, i.e. with the
+// cabinet, so that mistyped XNAMEs are recognized as XNAMEs too.
+var xnamePrefix = regexp.MustCompile(`^[xX][0-9]`)
+
+// idMapEntry is a single entry of a BMC ID Map with the line it is on,
+// or 0 if the line is not known.
+type idMapEntry struct {
+	key  string
+	id   string
+	line int
+}
+
+// readIDMapEntries finds the entries of 'id_map' and their lines in
+// the raw map data. YAML nodes are used since they keep the position
+// of each entry, as well as entries with duplicate keys, and JSON
+// parses as YAML.
+func readIDMapEntries(input []byte) ([]idMapEntry, bool) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(input, &document); err != nil || len(document.Content) == 0 {
+		return nil, false
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, false
+	}
+	var entries []idMapEntry
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "id_map" || root.Content[i+1].Kind != yaml.MappingNode {
+			continue
+		}
+		idMap := root.Content[i+1]
+		for j := 0; j+1 < len(idMap.Content); j += 2 {
+			entries = append(entries, idMapEntry{
+				key:  idMap.Content[j].Value,
+				id:   idMap.Content[j+1].Value,
+				line: idMap.Content[j].Line,
+			})
+		}
+	}
+	return entries, true
 }
 
 type userProvidedMapper struct {
@@ -43,6 +105,8 @@ func getBMCIDMap(data string, formatType format.DataFormat) (*bmcIDMap, error) {
 		if err != nil {
 			return nil, err
 		}
+		bmcIDMap.source = "--bmc-id-map"
+		bmcIDMap.entries, _ = readIDMapEntries([]byte(data))
 		return &bmcIDMap, nil
 	}
 
@@ -57,6 +121,15 @@ func getBMCIDMap(data string, formatType format.DataFormat) (*bmcIDMap, error) {
 		return nil, fmt.Errorf("error reading BMC ID mapping file '%s': %v", path, err)
 	}
 
+	// Spreadsheet exports and hosts files carry the positions of
+	// their entries themselves.
+	switch {
+	case isCSVIDMap(path):
+		return parseCSVIDMap(input, path)
+	case isHostsIDMap(path):
+		return parseHostsIDMap(input, path)
+	}
+
 	// Decode the file based on the appropriate format.
 	switch format.DataFormatFromFileExt(path, formatType) {
 	case format.FORMAT_JSON:
@@ -72,71 +145,194 @@ func getBMCIDMap(data string, formatType format.DataFormat) (*bmcIDMap, error) {
 			return nil, err
 		}
 	}
+	bmcIDMap.source = path
+	bmcIDMap.entries, _ = readIDMapEntries(input)
 	return &bmcIDMap, nil
 }
 
-// Generate a BMC ID string associated with 'selector' in the provided
-// 'bmcIDMap'. If there is no map, then return the selector string
-// itself.  If the map is present but the host is not present in the
-// map, then log a warning and return an empty string indicating that
-// the BMC ID was not composed.
-func getBMCID(bmcIDMap *bmcIDMap, selector string) string {
-	if bmcIDMap == nil {
-		return selector
+// normalize validates the entries of the map and puts their keys, and
+// in XNAME mode their IDs, into canonical form. XNAME mode is turned on
+// when 'id_format' is not set but any ID looks like an XNAME, so that
+// maps of XNAMEs are checked without having to ask for it, and the IDs
+// among them that aren't valid XNAMEs of BMCs are reported. All of the
+// invalid or duplicate entries are reported together, with their lines.
+func (idMap *bmcIDMap) normalize() error {
+	entries := idMap.entries
+	if entries == nil {
+		// The positions of the entries are unknown, so at least
+		// report them in a stable order.
+		for _, key := range slices.Sorted(maps.Keys(idMap.IDMap)) {
+			entries = append(entries, idMapEntry{key: key, id: idMap.IDMap[key]})
+		}
+	}
+	if idMap.IDFormat == "" && anyXname(entries) {
+		log.Debug().Str("source", idMap.source).Msg("BMC IDs look like XNAMEs, validating them as such")
+		idMap.IDFormat = IDFormatXname
+	}
+	position := func(line int) string {
+		if line == 0 {
+			return idMap.source
+		}
+		return fmt.Sprintf("%s:%d", idMap.source, line)
+	}
+
+	var (
+		errs       []error
+		normalized = make(map[string]string, len(entries))
+		keyLines   = make(map[string]int, len(entries))
+		idKeys     = make(map[string]idMapEntry, len(entries))
+	)
+	for _, entry := range entries {
+		key := NormalizeKey(idMap.MapKey, entry.key)
+		id := strings.TrimSpace(entry.id)
+		if key == "" {
+			errs = append(errs, fmt.Errorf("%s: empty key for BMC ID '%s'", position(entry.line), id))
+			continue
+		}
+		if id == "" {
+			errs = append(errs, fmt.Errorf("%s: empty BMC ID for key '%s'", position(entry.line), entry.key))
+			continue
+		}
+		if idMap.IDFormat == IDFormatXname {
+			xname := xnametypes.VerifyNormalizeCompID(id)
+			if xname == "" {
+				errs = append(errs, fmt.Errorf("%s: invalid XNAME '%s' for key '%s'", position(entry.line), id, entry.key))
+				continue
+			}
+			if hmsType := xnametypes.GetHMSType(xname); !xnametypes.IsHMSTypeController(hmsType) {
+				errs = append(errs, fmt.Errorf("%s: XNAME '%s' for key '%s' is a %s, not a BMC", position(entry.line), id, entry.key, hmsType))
+				continue
+			}
+			if xname != id {
+				log.Debug().Str("key", entry.key).Msgf("normalized BMC ID '%s' to '%s'", id, xname)
+			}
+			id = xname
+		}
+		if line, ok := keyLines[key]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate key '%s', already mapped on line %d", position(entry.line), entry.key, line))
+			continue
+		}
+		if other, ok := idKeys[id]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate BMC ID '%s' for key '%s', already mapped to key '%s' on line %d", position(entry.line), id, entry.key, other.key, other.line))
+			continue
+		}
+		normalized[key] = id
+		keyLines[key] = entry.line
+		idKeys[id] = entry
 	}
-	// Go does not error out on string map references that do not
-	// match the selector, it simply produces an empty
-	// string. Recognize that case and log it, then return an
-	// empty string.
-	bmcID := bmcIDMap.IDMap[selector]
-	if bmcID == "" {
-		log.Warn().Msgf("no mapping found from host selector '%v' to a BMC ID", selector)
-		return ""
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid BMC ID Map: %w", errors.Join(errs...))
 	}
-	return bmcID
+	idMap.IDMap = normalized
+	return nil
+}
+
+// anyXname reports whether any ID of the map looks like an XNAME, i.e.
+// parses as one, of a BMC or not, or starts like one.
+func anyXname(entries []idMapEntry) bool {
+	return slices.ContainsFunc(entries, func(entry idMapEntry) bool {
+		id := strings.TrimSpace(entry.id)
+		return xnamePrefix.MatchString(id) || (id != "" && xnametypes.VerifyNormalizeCompID(id) != "")
+	})
+}
+
+// loadBMCIDMap reads a BMC ID Map with getBMCIDMap(), validates it and
+// normalizes its entries.
+func loadBMCIDMap(data string, formatType format.DataFormat) (*bmcIDMap, error) {
+	idMap, err := getBMCIDMap(data, formatType)
+	if err != nil {
+		return nil, err
+	}
+	if idMap == nil {
+		return nil, fmt.Errorf("no BMC ID Map provided")
+	}
+
+	// Verify that the map key is one we know how to look up, then
+	// normalize the keys of the mapping the same way the values
+	// from the BMC will be, so that e.g. MAC addresses match
+	// regardless of notation.
+	if !slices.Contains(MapKeys, idMap.MapKey) {
+		return nil, fmt.Errorf("invalid 'map_key' field '%s' in BMC ID Map, valid values are: %s", idMap.MapKey, strings.Join(MapKeys, ", "))
+	}
+	if idMap.IDFormat != "" && idMap.IDFormat != IDFormatXname && idMap.IDFormat != IDFormatAny {
+		return nil, fmt.Errorf("invalid 'id_format' field '%s' in BMC ID Map, valid values are: %s, %s", idMap.IDFormat, IDFormatXname, IDFormatAny)
+	}
+	if err := idMap.normalize(); err != nil {
+		return nil, err
+	}
+	return idMap, nil
+}
+
+// ConvertBMCIDMap reads a BMC ID Map in any of the supported forms, i.e.
+// JSON or YAML data, or a JSON, YAML, CSV or hosts file with @<path>, and
+// writes it as canonical YAML with normalized and sorted entries.
+//
+// Parameters:
+//   - data: The BMC ID Map as raw JSON data, or @<path> to a file with it.
+//   - formatType: The format of the file if it is not given by the file extension.
+//
+// Returns:
+//   - []byte: The BMC ID Map as YAML.
+//   - error: An error object if the map could not be read or has invalid entries.
+func ConvertBMCIDMap(data string, formatType format.DataFormat) ([]byte, error) {
+	idMap, err := loadBMCIDMap(data, formatType)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(idMap)
 }
 
 func (mapper userProvidedMapper) Initialize() (Mapper, error) {
 	// Get the host to BMC ID mapping
-	idMap, err := getBMCIDMap(mapper.IDMapStr, mapper.IDMapFormat)
+	idMap, err := loadBMCIDMap(mapper.IDMapStr, mapper.IDMapFormat)
 	if err != nil {
 		log.Error().Err(err).Str("User Specified BMC ID Map", mapper.IDMapStr).Msg("failed to decode user supplied BMC ID Mapping")
 		return mapper, err
 	}
 	mapper.IDMap = idMap
-
-	// For now, the only valid key is the IPv4 address, verify
-	// that is what is in the mapping structure. If it is not,
-	// return an error. When more map key options become available
-	// add them here...
-	switch mapper.IDMap.MapKey {
-	case "bmc-ip-addr":
-		break
-	default:
-		return mapper, fmt.Errorf("invalid 'map_key' field '%s' in BMC ID Map a valid value is 'bmc-ip-addr", mapper.IDMap.MapKey)
-	}
 	return mapper, nil
 }
 
-func (mapper userProvidedMapper) GetMappedID(keys *MapperKeys) string {
+func (mapper userProvidedMapper) generateID(keys *MapperKeys) (string, error) {
 	// Get the map key. We already validated the key name in
-	// initialize() so the default here should never happen. Log a
-	// debug message if it does and return no ID.
+	// initialize() so the default here should never happen.
 	if mapper.IDMap == nil {
 		// Somehow the IDMap isn't there. Must have failed
-		// initialization. Log an error and return nil. There
-		// was an error logged in initialize() that should
-		// explain this.
-		log.Error().Str("ID Mapper Keys", fmt.Sprintf("%#v", *keys)).Msg("BMC ID Mapping is missing, skipping BMC")
-		return ""
+		// initialization. There was an error logged in
+		// initialize() that should explain this.
+		return "", fmt.Errorf("BMC ID Mapping is missing")
+	}
+	selector, ok := keys.Get(mapper.IDMap.MapKey)
+	if !ok {
+		return "", fmt.Errorf("failed to interpret map key name '%s'", mapper.IDMap.MapKey)
+	}
+	if selector == "" {
+		// The BMC did not report the key, so there is nothing
+		// to look up.
+		return "", fmt.Errorf("BMC did not report a value for map key '%s'", mapper.IDMap.MapKey)
+	}
+	// Go does not error out on string map references that do not
+	// match the selector, it simply produces an empty
+	// string. Recognize that case and report it.
+	bmcID := mapper.IDMap.IDMap[selector]
+	if bmcID == "" {
+		return "", fmt.Errorf("no mapping found from host selector '%v' to a BMC ID", selector)
 	}
-	var selector string
-	switch mapper.IDMap.MapKey {
-	case "bmc-ip-addr":
-		selector = keys.IPv4Addr
-	default:
-		log.Debug().Msgf("failed to interpret map key name '%s' - THIS SHOULD NEVER HAPPEN!", mapper.IDMap.MapKey)
+	return bmcID, nil
+}
+
+// usesKey reports whether the key is the 'map_key' of the map.
+func (mapper userProvidedMapper) usesKey(key string) bool {
+	return mapper.IDMap != nil && mapper.IDMap.MapKey == key
+}
+
+func (mapper userProvidedMapper) GetMappedID(keys *MapperKeys) string {
+	bmcID, err := mapper.generateID(keys)
+	if err != nil {
+		// An empty ID means that the BMC is unrecognized, so
+		// the caller skips it.
+		log.Warn().Err(err).Str("IPv4 address", keys.IPv4Addr).Msg("failed to map BMC ID, skipping BMC")
 		return ""
 	}
-	return getBMCID(mapper.IDMap, selector)
+	return bmcID
 }
@@ -0,0 +1,145 @@
+package idmap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenCHAMI/magellan/internal/format"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserProvidedMapper(t *testing.T) {
+	t.Parallel()
+
+	keys := &MapperKeys{
+		IPv4Addr:      "172.21.0.1",
+		Hostname:      "BMC1.example.com.",
+		BMCMACAddr:    "A4:BF:01:5C:29:10",
+		ManagerUUID:   "3F2504E0-4F89-11D3-9A0C-0305E82C3301",
+		ChassisSerial: "CN7475166M0123",
+	}
+
+	tests := []struct {
+		mapKey string
+		key    string
+	}{
+		{MapKeyBMCIPAddr, "172.21.0.1"},
+		{MapKeyBMCMACAddr, "a4-bf-01-5c-29-10"},
+		{MapKeyManagerUUID, "3f2504e0-4f89-11d3-9a0c-0305e82c3301"},
+		{MapKeyChassisSerial, "CN7475166M0123"},
+		{MapKeyHostname, "bmc1.example.com"},
+	}
+	for _, test := range tests {
+		mapper, err := userProvidedMapper{
+			IDMapStr: `{"map_key": "` + test.mapKey + `", "id_map": {"` + test.key + `": "x0c0s1b0"}}`,
+		}.Initialize()
+		require.NoError(t, err, test.mapKey)
+		require.Equal(t, "x0c0s1b0", mapper.GetMappedID(keys), test.mapKey)
+
+		// BMCs that are not mapped or do not report the key are skipped
+		require.Empty(t, mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.21.0.2", Hostname: "bmc2", BMCMACAddr: "a4:bf:01:5c:29:11", ManagerUUID: "x", ChassisSerial: "y"}), test.mapKey)
+		require.Empty(t, mapper.GetMappedID(&MapperKeys{}), test.mapKey)
+	}
+
+	// the map can be read from a file
+	path := filepath.Join(t.TempDir(), "map.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("map_key: chassis-serial\nid_map:\n  CN7475166M0123: x0c0s1b0\n"), 0o644))
+	mapper, err := userProvidedMapper{IDMapStr: "@" + path, IDMapFormat: format.FORMAT_JSON}.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, "x0c0s1b0", mapper.GetMappedID(keys))
+
+	_, err = userProvidedMapper{IDMapStr: `{"map_key": "bmc-serial", "id_map": {}}`}.Initialize()
+	require.Error(t, err)
+}
+
+func TestUserProvidedMapperValidation(t *testing.T) {
+	t.Parallel()
+
+	// in XNAME mode, IDs are normalized to their canonical form
+	mapper, err := userProvidedMapper{
+		IDMapStr: `{"map_key": "bmc-ip-addr", "id_format": "xname", "id_map": {"172.21.0.1": "X1000C0S0B03", "172.21.0.2": "x1000c0s1b0"}}`,
+	}.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, "x1000c0s0b3", mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.21.0.1"}))
+
+	// invalid and duplicate entries are all reported with their lines
+	path := filepath.Join(t.TempDir(), "map.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`map_key: bmc-mac-addr
+id_format: xname
+id_map:
+  a4:bf:01:5c:29:10: x1000c0s0b3
+  a4:bf:01:5c:29:11: x1000c0s0b03
+  A4-BF-01-5C-29-10: x1000c0s2b0
+  a4:bf:01:5c:29:12: x1000c0s3
+  a4:bf:01:5c:29:13: x1000c0s4b0x
+  a4:bf:01:5c:29:14: ""
+`), 0o644))
+	_, err = userProvidedMapper{IDMapStr: "@" + path}.Initialize()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), path+":5: duplicate BMC ID 'x1000c0s0b3' for key 'a4:bf:01:5c:29:11', already mapped to key 'a4:bf:01:5c:29:10' on line 4")
+	require.Contains(t, err.Error(), path+":6: duplicate key 'A4-BF-01-5C-29-10', already mapped on line 4")
+	require.Contains(t, err.Error(), path+":7: XNAME 'x1000c0s3' for key 'a4:bf:01:5c:29:12' is a ComputeModule, not a BMC")
+	require.Contains(t, err.Error(), path+":8: invalid XNAME 'x1000c0s4b0x'")
+	require.Contains(t, err.Error(), path+":9: empty BMC ID")
+
+	// duplicate keys are found in JSON too, and duplicate IDs are
+	// rejected without XNAME mode
+	path = filepath.Join(t.TempDir(), "map.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "map_key": "hostname",
+  "id_map": {
+    "bmc1": "node-1",
+    "bmc1": "node-2",
+    "bmc3": "node-1"
+  }
+}`), 0o644))
+	_, err = userProvidedMapper{IDMapStr: "@" + path}.Initialize()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), path+":5: duplicate key 'bmc1', already mapped on line 4")
+	require.Contains(t, err.Error(), path+":6: duplicate BMC ID 'node-1'")
+
+	// XNAME mode is on without 'id_format' when any ID looks like an
+	// XNAME, and the IDs that aren't XNAMEs of BMCs are reported
+	mapper, err = userProvidedMapper{
+		IDMapStr: `{"map_key": "bmc-ip-addr", "id_map": {"172.21.0.1": "X1000C0S0B03", "172.21.0.2": "x1000c0s1b0"}}`,
+	}.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, "x1000c0s0b3", mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.21.0.1"}))
+	_, err = userProvidedMapper{
+		IDMapStr: `{"map_key": "bmc-ip-addr", "id_map": {"172.21.0.1": "x1000c0s0b3", "172.21.0.2": "x1000c0s0b03"}}`,
+	}.Initialize()
+	require.ErrorContains(t, err, "duplicate BMC ID 'x1000c0s0b3'")
+	_, err = userProvidedMapper{
+		IDMapStr: `{"map_key": "bmc-ip-addr", "id_map": {"172.21.0.1": "x1000c0s0b3", "172.21.0.2": "x1000c0s3"}}`,
+	}.Initialize()
+	require.ErrorContains(t, err, "is a ComputeModule, not a BMC")
+	path = filepath.Join(t.TempDir(), "map.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`map_key: bmc-ip-addr
+id_map:
+  172.21.0.1: X1000C0S0B03
+  172.21.0.2: node-2
+  172.21.0.3: x1000c0s0bb3
+`), 0o644))
+	_, err = userProvidedMapper{IDMapStr: "@" + path}.Initialize()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), path+":4: invalid XNAME 'node-2' for key '172.21.0.2'")
+	require.Contains(t, err.Error(), path+":5: invalid XNAME 'x1000c0s0bb3' for key '172.21.0.3'")
+	require.NotContains(t, err.Error(), ":3:")
+
+	// unless the map asks for any IDs, while maps without XNAMEs are
+	// never validated as such
+	mapper, err = userProvidedMapper{
+		IDMapStr: `{"map_key": "bmc-ip-addr", "id_format": "any", "id_map": {"172.21.0.1": "X1000C0S0B03", "172.21.0.2": "node-2"}}`,
+	}.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, "X1000C0S0B03", mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.21.0.1"}))
+	mapper, err = userProvidedMapper{
+		IDMapStr: `{"map_key": "bmc-ip-addr", "id_map": {"172.21.0.1": "node-1", "172.21.0.2": "node-2"}}`,
+	}.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, "node-1", mapper.GetMappedID(&MapperKeys{IPv4Addr: "172.21.0.1"}))
+
+	_, err = userProvidedMapper{IDMapStr: `{"map_key": "hostname", "id_format": "uuid", "id_map": {}}`}.Initialize()
+	require.Error(t, err)
+}
@@ -0,0 +1,165 @@
+package jaws
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// OutletAction is a control action accepted by the JAWS outlet control API.
+type OutletAction string
+
+const (
+	OutletActionOn     OutletAction = "on"
+	OutletActionOff    OutletAction = "off"
+	OutletActionReboot OutletAction = "reboot"
+)
+
+// ParseOutletAction converts a case-insensitive action name into an OutletAction.
+func ParseOutletAction(action string) (OutletAction, error) {
+	for _, a := range []OutletAction{OutletActionOn, OutletActionOff, OutletActionReboot} {
+		if strings.EqualFold(string(a), action) {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("invalid outlet action '%s' (must be one of on, off or reboot)", action)
+}
+
+// ExpectedState returns the outlet state reported by JAWS once the action
+// has completed. A rebooted outlet is expected to be back on.
+func (a OutletAction) ExpectedState() string {
+	if a == OutletActionOff {
+		return "Off"
+	}
+	return "On"
+}
+
+// GetOutlet gets the current state and readings of a single outlet from the
+// JAWS monitor API.
+//
+// Parameters:
+//   - config: The address and credentials of the PDU.
+//   - outletID: The ID of the outlet, e.g. "AA1".
+//
+// Returns:
+//   - *JawsOutlet: The outlet as reported by the PDU.
+//   - error: An error object if the request failed or the outlet does not exist.
+func GetOutlet(config CrawlerConfig, outletID string) (*JawsOutlet, error) {
+	var outlet JawsOutlet
+	if err := getJSON(config, "/jaws/monitor/outlets/"+url.PathEscape(outletID), &outlet); err != nil {
+		return nil, fmt.Errorf("failed to get outlet %s: %w", outletID, err)
+	}
+	return &outlet, nil
+}
+
+// SetOutletState switches, or power-cycles, a single outlet using the JAWS
+// control API. The PDU applies the action asynchronously, see WaitForOutletState.
+//
+// Parameters:
+//   - config: The address and credentials of the PDU.
+//   - outletID: The ID of the outlet, e.g. "AA1".
+//   - action: The control action to perform.
+//
+// Returns:
+//   - error: An error object if the PDU rejected the action.
+func SetOutletState(config CrawlerConfig, outletID string, action OutletAction) error {
+	log.Debug().Msgf("sending control action '%s' to outlet %s of %s", action, outletID, config.URI)
+	payload := map[string]string{"control_action": string(action)}
+	if _, err := doRequest(config, http.MethodPatch, "/jaws/control/outlets/"+url.PathEscape(outletID), payload); err != nil {
+		return fmt.Errorf("failed to %s outlet %s: %w", action, outletID, err)
+	}
+	return nil
+}
+
+// WaitForOutletState polls the state of an outlet until it matches the
+// expected state or the timeout expires. Errors while polling are only logged.
+//
+// Returns:
+//   - string: The last state reported by the PDU.
+//   - error: An error object if the outlet did not reach the expected state before the timeout.
+func WaitForOutletState(config CrawlerConfig, outletID string, expected string, timeout time.Duration, interval time.Duration) (string, error) {
+	var (
+		deadline = time.Now().Add(timeout)
+		state    string
+	)
+	for {
+		outlet, err := GetOutlet(config, outletID)
+		if err != nil {
+			log.Debug().Err(err).Msgf("failed to get state of outlet %s, retrying", outletID)
+		} else {
+			state = outlet.State
+			if strings.EqualFold(state, expected) {
+				return state, nil
+			}
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return state, fmt.Errorf("outlet %s did not reach state %s within %s", outletID, expected, timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// newHTTPClient creates the HTTP client used to talk to a JAWS PDU.
+func newHTTPClient(config CrawlerConfig) *http.Client {
+	return &http.Client{
+		Timeout: config.Timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: config.Insecure},
+		},
+	}
+}
+
+// doRequest sends an authenticated request with an optional JSON payload to
+// a JAWS endpoint and returns the response body.
+func doRequest(config CrawlerConfig, method string, path string, payload any) ([]byte, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	targetURL := baseURL(config.URI) + path
+	req, err := http.NewRequest(method, targetURL, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new HTTP request: %w", err)
+	}
+	req.SetBasicAuth(config.Username, config.Password)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	log.Debug().Msgf("querying JAWS endpoint: %s %s", method, targetURL)
+	resp, err := newHTTPClient(config).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("received status code %d %s from %s", resp.StatusCode, http.StatusText(resp.StatusCode), targetURL)
+	}
+	return body, nil
+}
+
+// baseURL returns the URL of a PDU, assuming HTTPS when no scheme is given.
+func baseURL(host string) string {
+	if strings.Contains(host, "://") {
+		return strings.TrimRight(host, "/")
+	}
+	return "https://" + host
+}
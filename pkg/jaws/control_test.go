@@ -0,0 +1,72 @@
+package jaws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutletControl(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu          sync.Mutex
+		outletState = "On"
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jaws/monitor/outlets/AA1", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = json.NewEncoder(w).Encode(JawsOutlet{ID: "AA1", Name: "Link1_Outlet_1", State: outletState})
+	})
+	mux.HandleFunc("/jaws/control/outlets/AA1", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body map[string]string
+		if r.Method != http.MethodPatch || json.NewDecoder(r.Body).Decode(&body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		outletState = strings.ToUpper(body["control_action"][:1]) + body["control_action"][1:]
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	config := CrawlerConfig{URI: server.URL, Username: "admin", Password: "secret", Insecure: true, Timeout: time.Second}
+
+	outlet, err := GetOutlet(config, "AA1")
+	require.NoError(t, err)
+	require.Equal(t, "On", outlet.State)
+
+	action, err := ParseOutletAction("OFF")
+	require.NoError(t, err)
+	require.NoError(t, SetOutletState(config, "AA1", action))
+	state, err := WaitForOutletState(config, "AA1", action.ExpectedState(), time.Second, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, "Off", state)
+
+	// the outlet never reaches a state it was not switched to
+	_, err = WaitForOutletState(config, "AA1", "On", 0, time.Millisecond)
+	require.Error(t, err)
+
+	// rejected credentials are reported
+	config.Password = "wrong"
+	require.Error(t, SetOutletState(config, "AA1", OutletActionOn))
+
+	_, err = GetOutlet(config, "ZZ9")
+	require.Error(t, err)
+	_, err = ParseOutletAction("toggle")
+	require.Error(t, err)
+}
@@ -1,10 +1,8 @@
 package jaws
 
 import (
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 
@@ -26,74 +24,117 @@ type JawsOutlet struct {
 	Name        string  `json:"name"`
 	State       string  `json:"state"`
 	SocketType  string  `json:"socket_type"`
-	Current     float32 `json:"current"`
-	Voltage     float32 `json:"voltage"`
+	Current     float64 `json:"current"`
+	Voltage     float64 `json:"voltage"`
 	ActivePower int     `json:"active_power"`
 }
 
+// JawsCircuit represents an input phase or an outlet branch, as returned by
+// the phases and branches monitor endpoints.
+type JawsCircuit struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Current     float64 `json:"current"`
+	Voltage     float64 `json:"voltage"`
+	ActivePower int     `json:"active_power"`
+}
+
+// JawsSystem represents the system information of a PDU.
+type JawsSystem struct {
+	Firmware string `json:"firmware"`
+}
+
+// JawsUnit represents one of the (possibly linked) units making up a PDU.
+// The master unit comes first.
+type JawsUnit struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	ModelNumber  string `json:"model_number"`
+	SerialNumber string `json:"product_serial_number"`
+}
+
 // CrawlPDU connects to a single JAWS PDU and collects its full inventory.
+// Only the outlets are required; the identity of the PDU and the phase and
+// branch readings are added when the PDU provides them.
 func CrawlPDU(config CrawlerConfig) (*pdu.PDUInventory, error) {
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: config.Insecure},
-	}
-	client := &http.Client{
-		Timeout:   config.Timeout,
-		Transport: transport,
-	}
-
 	inventory := &pdu.PDUInventory{
 		Hostname: config.URI,
 	}
 
-	targetURL := fmt.Sprintf("https://%s/jaws/monitor/outlets", config.URI)
-
-	req, err := http.NewRequest("GET", targetURL, nil)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to create new HTTP request for outlets")
-		return nil, err
-	}
-	req.SetBasicAuth(config.Username, config.Password)
-
-	log.Debug().Msgf("querying JAWS endpoint: %s", targetURL)
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Error().Err(err).Msgf("failed to execute request to JAWS outlets endpoint %s", targetURL)
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		err := fmt.Errorf("received non-200 status code from outlets endpoint: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
-		log.Error().Err(err).Str("url", targetURL).Msg("bad response from PDU")
-		return nil, err
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to read outlets response body")
-		return nil, err
-	}
-	log.Debug().RawJSON("response_body", body).Msg("received response from JAWS outlets")
-
 	var rawOutlets []JawsOutlet
-	if err := json.Unmarshal(body, &rawOutlets); err != nil {
-		log.Error().Err(err).Msg("failed to unmarshal JAWS outlet data")
+	if err := getJSON(config, "/jaws/monitor/outlets", &rawOutlets); err != nil {
+		log.Error().Err(err).Str("host", config.URI).Msg("failed to get JAWS outlets")
 		return nil, err
 	}
-
 	for _, rawOutlet := range rawOutlets {
 		outlet := pdu.PDUOutlet{
-			ID:         rawOutlet.ID,
-			Name:       rawOutlet.Name,
-			PowerState: rawOutlet.State,
-			SocketType: rawOutlet.SocketType,
+			ID:          rawOutlet.ID,
+			Name:        rawOutlet.Name,
+			PowerState:  rawOutlet.State,
+			SocketType:  rawOutlet.SocketType,
+			Current:     rawOutlet.Current,
+			Voltage:     rawOutlet.Voltage,
+			ActivePower: float64(rawOutlet.ActivePower),
 		}
 		inventory.Outlets = append(inventory.Outlets, outlet)
 	}
 
-	if err := resp.Body.Close(); err != nil {
-		log.Warn().Err(err).Msg("could not close response resource")
+	// identity of the PDU
+	var system JawsSystem
+	if err := getJSON(config, "/jaws/config/info/system", &system); err != nil {
+		log.Warn().Err(err).Str("host", config.URI).Msg("failed to get JAWS system information")
+	} else {
+		inventory.FirmwareVersion = system.Firmware
+	}
+	var units []JawsUnit
+	if err := getJSON(config, "/jaws/config/info/units", &units); err != nil {
+		log.Warn().Err(err).Str("host", config.URI).Msg("failed to get JAWS unit information")
+	} else if len(units) > 0 {
+		inventory.Model = units[0].ModelNumber
+		inventory.SerialNumber = units[0].SerialNumber
+	}
+
+	// readings of the input phases and outlet branches
+	var err error
+	if inventory.Phases, err = getCircuits(config, "/jaws/monitor/phases"); err != nil {
+		log.Warn().Err(err).Str("host", config.URI).Msg("failed to get JAWS phases")
+	}
+	if inventory.Branches, err = getCircuits(config, "/jaws/monitor/branches"); err != nil {
+		log.Warn().Err(err).Str("host", config.URI).Msg("failed to get JAWS branches")
 	}
 
 	log.Debug().Msgf("successfully collected inventory for %d outlets from %s", len(inventory.Outlets), config.URI)
 	return inventory, nil
 }
+
+// getCircuits gets the phases or branches of a PDU from a JAWS monitor endpoint.
+func getCircuits(config CrawlerConfig, path string) ([]pdu.PDUCircuit, error) {
+	var rawCircuits []JawsCircuit
+	if err := getJSON(config, path, &rawCircuits); err != nil {
+		return nil, err
+	}
+	circuits := make([]pdu.PDUCircuit, 0, len(rawCircuits))
+	for _, c := range rawCircuits {
+		circuits = append(circuits, pdu.PDUCircuit{
+			ID:          c.ID,
+			Name:        c.Name,
+			Current:     c.Current,
+			Voltage:     c.Voltage,
+			ActivePower: float64(c.ActivePower),
+		})
+	}
+	return circuits, nil
+}
+
+// getJSON GETs a JAWS endpoint and unmarshals the response into v.
+func getJSON(config CrawlerConfig, path string, v any) error {
+	body, err := doRequest(config, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	log.Debug().RawJSON("response_body", body).Msgf("received response from JAWS %s", path)
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to unmarshal response from %s: %w", path, err)
+	}
+	return nil
+}
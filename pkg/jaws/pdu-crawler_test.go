@@ -0,0 +1,59 @@
+package jaws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/pkg/pdu"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrawlPDU(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jaws/monitor/outlets", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[
+			{"id": "AA1", "name": "Link1_Outlet_1", "state": "On", "socket_type": "C13", "current": 1.25, "voltage": 208.1, "active_power": 251},
+			{"id": "AA2", "name": "Link1_Outlet_2", "state": "Off", "socket_type": "C13", "current": 0, "voltage": 208.1, "active_power": 0}
+		]`))
+	})
+	mux.HandleFunc("/jaws/monitor/phases", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"id": "AA", "name": "AA:L1-L2", "current": 1.4, "voltage": 208.1, "active_power": 280}]`))
+	})
+	mux.HandleFunc("/jaws/config/info/system", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"firmware": "Version 8.0m"}`))
+	})
+	mux.HandleFunc("/jaws/config/info/units", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"id": "A", "name": "Master", "model_number": "C2WG36TE-DQME2M66/C", "product_serial_number": "ABEF0001234"}]`))
+	})
+	// the branches endpoint is missing, as on PDUs without branch metering
+
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	inventory, err := CrawlPDU(CrawlerConfig{URI: server.URL, Username: "admin", Password: "secret", Insecure: true, Timeout: time.Second})
+	require.NoError(t, err)
+	require.Equal(t, "C2WG36TE-DQME2M66/C", inventory.Model)
+	require.Equal(t, "ABEF0001234", inventory.SerialNumber)
+	require.Equal(t, "Version 8.0m", inventory.FirmwareVersion)
+	require.Empty(t, inventory.Branches)
+
+	require.Len(t, inventory.Outlets, 2)
+	require.Equal(t, "On", inventory.Outlets[0].PowerState)
+	require.Equal(t, 1.25, inventory.Outlets[0].Current)
+	require.Equal(t, 208.1, inventory.Outlets[0].Voltage)
+	require.Equal(t, 251.0, inventory.Outlets[0].ActivePower)
+
+	// the phase readings take precedence over the sum of the outlets
+	require.Equal(t, []pdu.PDUCircuit{{ID: "AA", Name: "AA:L1-L2", Current: 1.4, Voltage: 208.1, ActivePower: 280}}, inventory.Phases)
+	require.Equal(t, 280.0, inventory.TotalActivePower())
+	inventory.Phases = nil
+	require.Equal(t, 251.0, inventory.TotalActivePower())
+
+	// without outlets there is no inventory
+	_, err = CrawlPDU(CrawlerConfig{URI: server.URL + "/missing", Insecure: true, Timeout: time.Second})
+	require.Error(t, err)
+}
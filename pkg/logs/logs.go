@@ -0,0 +1,194 @@
+package logs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/schemas"
+)
+
+// LogEntry is a single entry read from a Redfish LogService such as the
+// system event log (SEL) of a BMC.
+type LogEntry struct {
+	Host       string    `json:"host" yaml:"host"`                                   // Host of the BMC the entry was read from
+	LogService string    `json:"log_service" yaml:"log_service"`                     // URI of the LogService containing the entry
+	ID         string    `json:"id" yaml:"id"`                                       // ID of the entry within the LogService
+	Created    time.Time `json:"created" yaml:"created"`                             // Time the entry was created
+	Severity   string    `json:"severity,omitempty" yaml:"severity,omitempty"`       // Severity of the entry (OK, Warning or Critical)
+	MessageID  string    `json:"message_id,omitempty" yaml:"message_id,omitempty"`   // Redfish message registry ID, e.g. Base.1.0.Success
+	Message    string    `json:"message,omitempty" yaml:"message,omitempty"`         // Human readable message of the entry
+	EntryType  string    `json:"entry_type,omitempty" yaml:"entry_type,omitempty"`   // Type of entry, e.g. Event, SEL or Oem
+	SensorType string    `json:"sensor_type,omitempty" yaml:"sensor_type,omitempty"` // Sensor type of SEL entries
+}
+
+// LogFilter selects which entries are returned when reading a LogService.
+// The zero value matches every entry.
+type LogFilter struct {
+	Since    time.Time             // only include entries created at or after this time
+	Severity schemas.EventSeverity // only include entries at least this severe
+}
+
+// severityLevels orders the Redfish event severities from least to most severe.
+var severityLevels = map[schemas.EventSeverity]int{
+	schemas.OKEventSeverity:       0,
+	schemas.WarningEventSeverity:  1,
+	schemas.CriticalEventSeverity: 2,
+}
+
+// ParseSeverity converts a case-insensitive severity name into a Redfish
+// event severity. An empty string is returned as-is to mean no filtering.
+func ParseSeverity(severity string) (schemas.EventSeverity, error) {
+	if severity == "" {
+		return "", nil
+	}
+	for s := range severityLevels {
+		if strings.EqualFold(string(s), severity) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("invalid severity '%s' (must be one of OK, Warning or Critical)", severity)
+}
+
+// ParseSince converts the value of the '--since' flag into an absolute time.
+// The value can either be a duration relative to now (e.g. '24h') or an
+// RFC3339 timestamp or date (e.g. '2025-01-31').
+func ParseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, since); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s' (must be a duration like '24h' or a timestamp like '2006-01-02T15:04:05Z')", since)
+}
+
+// Match returns whether a log entry passes the filter. Entries with a
+// timestamp or severity that cannot be interpreted are kept so that nothing
+// is silently dropped from an archive.
+func (f LogFilter) Match(entry *LogEntry) bool {
+	if !f.Since.IsZero() && !entry.Created.IsZero() && entry.Created.Before(f.Since) {
+		return false
+	}
+	if f.Severity != "" {
+		level, known := severityLevels[schemas.EventSeverity(entry.Severity)]
+		if known && level < severityLevels[f.Severity] {
+			return false
+		}
+	}
+	return true
+}
+
+// CollectLogs walks the LogServices of every Manager and ComputerSystem found
+// in the ServiceRoot and returns the entries matching the filter.
+//
+// Parameters:
+//   - client: An active gofish client for the BMC.
+//   - host: The host of the BMC that is recorded with each entry.
+//   - filter: A LogFilter to select which entries to return.
+//
+// Returns:
+//   - []LogEntry: The entries of all LogServices that matched the filter.
+//   - []*schemas.LogService: The LogServices that were read completely, which can later be cleared with ClearLogs.
+//   - error: An error object if the Managers and Systems could not be retrieved.
+func CollectLogs(client *gofish.APIClient, host string, filter LogFilter) ([]LogEntry, []*schemas.LogService, error) {
+	var (
+		rf_service  = client.GetService()
+		logServices []*schemas.LogService
+		entries     []LogEntry
+		errs        []error
+	)
+
+	// find all of the log services from managers and systems
+	rf_managers, err := rf_service.Managers()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to get managers: %w", err))
+	}
+	for _, rf_manager := range rf_managers {
+		rf_logservices, err := rf_manager.LogServices()
+		if err != nil {
+			log.Warn().Err(err).Str("host", host).Str("manager", rf_manager.ID).Msg("failed to get log services from manager")
+			continue
+		}
+		logServices = append(logServices, rf_logservices...)
+	}
+	rf_systems, err := rf_service.Systems()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to get systems: %w", err))
+	}
+	for _, rf_system := range rf_systems {
+		rf_logservices, err := rf_system.LogServices()
+		if err != nil {
+			log.Warn().Err(err).Str("host", host).Str("system", rf_system.ID).Msg("failed to get log services from system")
+			continue
+		}
+		logServices = append(logServices, rf_logservices...)
+	}
+	if len(errs) == 2 {
+		return nil, nil, fmt.Errorf("failed to find log services: %w", errs[0])
+	}
+
+	// read the entries from each log service
+	var read []*schemas.LogService
+	for _, rf_logservice := range logServices {
+		rf_entries, err := rf_logservice.Entries()
+		if err != nil {
+			log.Warn().Err(err).Str("host", host).Str("log_service", rf_logservice.ODataID).Msg("failed to get log entries")
+			continue
+		}
+		for _, rf_entry := range rf_entries {
+			entry := LogEntry{
+				Host:       host,
+				LogService: rf_logservice.ODataID,
+				ID:         rf_entry.ID,
+				Severity:   string(rf_entry.Severity),
+				MessageID:  rf_entry.MessageID,
+				Message:    rf_entry.Message,
+				EntryType:  string(rf_entry.EntryType),
+				SensorType: string(rf_entry.SensorType),
+			}
+			if rf_entry.Created != "" {
+				entry.Created, err = time.Parse(time.RFC3339, rf_entry.Created)
+				if err != nil {
+					log.Debug().Err(err).Str("host", host).Str("entry", rf_entry.ODataID).Msg("failed to parse log entry timestamp")
+				}
+			}
+			if filter.Match(&entry) {
+				entries = append(entries, entry)
+			}
+		}
+		read = append(read, rf_logservice)
+	}
+	return entries, read, nil
+}
+
+// ClearLogs clears all entries of the given LogServices. LogServices that do
+// not support the ClearLog action are skipped with a warning.
+//
+// Returns:
+//   - error: A condensed error if any of the LogServices failed to clear.
+func ClearLogs(logServices []*schemas.LogService) error {
+	var errs []string
+	for _, rf_logservice := range logServices {
+		if !rf_logservice.SupportsClearLog() {
+			log.Warn().Str("log_service", rf_logservice.ODataID).Msg("log service does not support clearing, skipping")
+			continue
+		}
+		if _, err := rf_logservice.ClearLog(""); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", rf_logservice.ODataID, err))
+			continue
+		}
+		log.Debug().Str("log_service", rf_logservice.ODataID).Msg("cleared log service")
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to clear log services: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
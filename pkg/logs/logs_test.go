@@ -0,0 +1,133 @@
+package logs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stmcginnis/gofish"
+	"github.com/stretchr/testify/require"
+)
+
+func serveJSON(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	since, err := ParseSince("24h", now)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(-24*time.Hour), since)
+
+	since, err = ParseSince("2025-02-01", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), since)
+
+	since, err = ParseSince("", now)
+	require.NoError(t, err)
+	require.True(t, since.IsZero())
+
+	_, err = ParseSince("yesterday", now)
+	require.Error(t, err)
+}
+
+func TestCollectLogs(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redfish/v1/", serveJSON(`{
+		"@odata.id": "/redfish/v1/",
+		"Id": "RootService",
+		"Managers": {"@odata.id": "/redfish/v1/Managers"},
+		"Systems": {"@odata.id": "/redfish/v1/Systems"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Managers", serveJSON(`{"Members": [{"@odata.id": "/redfish/v1/Managers/BMC"}]}`))
+	mux.HandleFunc("/redfish/v1/Managers/BMC", serveJSON(`{
+		"@odata.id": "/redfish/v1/Managers/BMC",
+		"Id": "BMC",
+		"LogServices": {"@odata.id": "/redfish/v1/Managers/BMC/LogServices"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Managers/BMC/LogServices", serveJSON(`{"Members": [{"@odata.id": "/redfish/v1/Managers/BMC/LogServices/SEL"}]}`))
+	mux.HandleFunc("/redfish/v1/Managers/BMC/LogServices/SEL", serveJSON(`{
+		"@odata.id": "/redfish/v1/Managers/BMC/LogServices/SEL",
+		"Id": "SEL",
+		"Entries": {"@odata.id": "/redfish/v1/Managers/BMC/LogServices/SEL/Entries"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Managers/BMC/LogServices/SEL/Entries", serveJSON(`{
+		"Members": [{
+			"@odata.id": "/redfish/v1/Managers/BMC/LogServices/SEL/Entries/1",
+			"Id": "1",
+			"Created": "2025-01-01T00:00:00Z",
+			"Severity": "Critical",
+			"MessageId": "Event.1.0.PowerSupplyFailed",
+			"Message": "PSU1 failed"
+		}, {
+			"@odata.id": "/redfish/v1/Managers/BMC/LogServices/SEL/Entries/2",
+			"Id": "2",
+			"Created": "2025-03-01T00:00:00Z",
+			"Severity": "OK",
+			"Message": "PSU1 restored"
+		}, {
+			"@odata.id": "/redfish/v1/Managers/BMC/LogServices/SEL/Entries/3",
+			"Id": "3",
+			"Created": "2025-03-02T00:00:00Z",
+			"Severity": "Warning",
+			"Message": "Fan1 slow"
+		}]
+	}`))
+	mux.HandleFunc("/redfish/v1/Systems", serveJSON(`{"Members": [{"@odata.id": "/redfish/v1/Systems/Node0"}]}`))
+	mux.HandleFunc("/redfish/v1/Systems/Node0", serveJSON(`{
+		"@odata.id": "/redfish/v1/Systems/Node0",
+		"Id": "Node0",
+		"LogServices": {"@odata.id": "/redfish/v1/Systems/Node0/LogServices"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Systems/Node0/LogServices", serveJSON(`{"Members": [{"@odata.id": "/redfish/v1/Systems/Node0/LogServices/Event"}]}`))
+	mux.HandleFunc("/redfish/v1/Systems/Node0/LogServices/Event", serveJSON(`{
+		"@odata.id": "/redfish/v1/Systems/Node0/LogServices/Event",
+		"Id": "Event",
+		"Entries": {"@odata.id": "/redfish/v1/Systems/Node0/LogServices/Event/Entries"}
+	}`))
+	mux.HandleFunc("/redfish/v1/Systems/Node0/LogServices/Event/Entries", serveJSON(`{
+		"Members": [{
+			"@odata.id": "/redfish/v1/Systems/Node0/LogServices/Event/Entries/1",
+			"Id": "1",
+			"Created": "not a timestamp",
+			"Severity": "Critical",
+			"Message": "Uncorrectable memory error"
+		}]
+	}`))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := gofish.Connect(gofish.ClientConfig{Endpoint: server.URL, BasicAuth: true})
+	require.NoError(t, err)
+	defer client.Logout()
+
+	// all entries are returned without a filter
+	entries, services, err := CollectLogs(client, server.URL, LogFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	require.Len(t, services, 2)
+	require.Equal(t, "/redfish/v1/Managers/BMC/LogServices/SEL", entries[0].LogService)
+	require.Equal(t, "Event.1.0.PowerSupplyFailed", entries[0].MessageID)
+
+	// entries older than '--since' and below '--severity' are dropped, but
+	// entries with an unparsable timestamp are kept
+	entries, _, err = CollectLogs(client, server.URL, LogFilter{
+		Since:    time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		Severity: "Warning",
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "Fan1 slow", entries[0].Message)
+	require.Equal(t, "Uncorrectable memory error", entries[1].Message)
+	require.True(t, entries[1].Created.IsZero())
+}
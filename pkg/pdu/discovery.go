@@ -0,0 +1,141 @@
+package pdu
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DiscoveryConfig describes how to infer which nodes the outlets of a PDU
+// power. Switching and probing are passed in so that discovery works the
+// same for any PDU interface and way of reaching a BMC.
+type DiscoveryConfig struct {
+	// PDU is the hostname of the PDU, used as the key in the outlet map.
+	PDU string
+	// Outlets are the IDs of the outlets to cycle, one at a time.
+	Outlets []string
+	// BMCs are the addresses of the candidate BMCs by node ID.
+	BMCs map[string]string
+	// SwitchOutlet switches an outlet on or off.
+	SwitchOutlet func(outlet string, on bool) error
+	// IsReachable reports whether a BMC answers at its address.
+	IsReachable func(address string) bool
+	// SettleTime is how long to wait after switching an outlet off before
+	// probing the BMCs, and after switching it back on.
+	SettleTime time.Duration
+	// DryRun only logs which outlets would be cycled, without switching any.
+	DryRun bool
+}
+
+// DiscoverOutletMap infers the node powered by each outlet by switching the
+// outlet off, probing the BMCs that were reachable beforehand and mapping the
+// outlet to the one that no longer answers. The outlet is always switched
+// back on before moving to the next one, even if switching it off failed.
+// Outlets whose BMC stays reachable, e.g. because it has a redundant power
+// supply, or that take down more than one BMC are left unmapped and logged.
+// BMCs that went down are not probed again, since they are still booting
+// while the next outlets are cycled.
+//
+// Parameters:
+//   - config: A DiscoveryConfig struct with the PDU, its outlets, the candidate BMCs and how to switch and probe them.
+//
+// Returns:
+//   - OutletMap: The outlets of the PDU that could be mapped. In dry-run mode, the map is empty.
+//   - error: An error object if an outlet could not be switched; the outlets mapped so far are returned with it.
+func DiscoverOutletMap(config DiscoveryConfig) (OutletMap, error) {
+	outletMap := OutletMap{}
+
+	// only BMCs that answer with all outlets on can be told apart
+	candidates := probe(config.BMCs, config.IsReachable)
+	for node, reachable := range candidates {
+		if !reachable {
+			log.Warn().Str("node", node).Msg("BMC is not reachable before discovery, skipping")
+			delete(candidates, node)
+		}
+	}
+	if len(candidates) == 0 {
+		return outletMap, fmt.Errorf("none of the %d BMCs are reachable", len(config.BMCs))
+	}
+
+	for _, outlet := range config.Outlets {
+		if config.DryRun {
+			log.Info().Str("pdu", config.PDU).Msgf("dry run: would switch outlet %s off and probe %d BMCs", outlet, len(candidates))
+			continue
+		}
+
+		log.Info().Str("pdu", config.PDU).Msgf("switching outlet %s off", outlet)
+		if err := config.SwitchOutlet(outlet, false); err != nil {
+			// the outlet may be off anyway, e.g. if waiting for it timed out,
+			// so never leave its node without power
+			if onErr := config.SwitchOutlet(outlet, true); onErr != nil {
+				log.Error().Err(onErr).Str("pdu", config.PDU).Msgf("failed to switch outlet %s back on", outlet)
+			}
+			return outletMap, fmt.Errorf("failed to switch outlet %s off: %w", outlet, err)
+		}
+		time.Sleep(config.SettleTime)
+
+		addresses := make(map[string]string, len(candidates))
+		for node := range candidates {
+			addresses[node] = config.BMCs[node]
+		}
+		var down []string
+		for node, reachable := range probe(addresses, config.IsReachable) {
+			if !reachable {
+				down = append(down, node)
+			}
+		}
+
+		log.Info().Str("pdu", config.PDU).Msgf("switching outlet %s back on", outlet)
+		if err := config.SwitchOutlet(outlet, true); err != nil {
+			return outletMap, fmt.Errorf("failed to switch outlet %s back on: %w", outlet, err)
+		}
+
+		switch len(down) {
+		case 0:
+			log.Warn().Str("pdu", config.PDU).Msgf("no BMC became unreachable with outlet %s off", outlet)
+		case 1:
+			log.Info().Str("pdu", config.PDU).Str("node", down[0]).Msgf("outlet %s powers node", outlet)
+			outletMap.Set(config.PDU, outlet, down[0])
+		default:
+			slices.Sort(down)
+			log.Warn().Str("pdu", config.PDU).Strs("nodes", down).Msgf("more than one BMC became unreachable with outlet %s off, not mapping it", outlet)
+		}
+		// a BMC takes a while to boot, so don't probe the ones that went
+		// down for the next outlets, where they would look powered by them
+		for _, node := range down {
+			delete(candidates, node)
+		}
+		time.Sleep(config.SettleTime)
+
+		if len(candidates) == 0 {
+			log.Info().Str("pdu", config.PDU).Msg("all BMCs are mapped, stopping discovery")
+			break
+		}
+	}
+	return outletMap, nil
+}
+
+// probe checks the reachability of each BMC concurrently.
+func probe(bmcs map[string]string, isReachable func(address string) bool) map[string]bool {
+	var (
+		results = make(map[string]bool, len(bmcs))
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
+	for _, node := range slices.Sorted(maps.Keys(bmcs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reachable := isReachable(bmcs[node])
+			mu.Lock()
+			results[node] = reachable
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,160 @@
+package pdu
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverOutletMap(t *testing.T) {
+	t.Parallel()
+
+	// AA1 powers one BMC, AA2 two BMCs in the same chassis, AA3 nothing, and
+	// x3000c0s9b0 is down from the start
+	var (
+		wiring = map[string][]string{
+			"AA1": {"10.0.0.1"},
+			"AA2": {"10.0.0.2", "10.0.0.3"},
+		}
+		off      = map[string]bool{}
+		switched []string
+		mu       sync.Mutex
+	)
+	config := DiscoveryConfig{
+		PDU:     "x3000m0",
+		Outlets: []string{"AA1", "AA2", "AA3"},
+		BMCs: map[string]string{
+			"x3000c0s1b0": "10.0.0.1",
+			"x3000c0s3b0": "10.0.0.2",
+			"x3000c0s3b1": "10.0.0.3",
+			"x3000c0s5b0": "10.0.0.5",
+			"x3000c0s9b0": "10.0.0.9",
+		},
+		SwitchOutlet: func(outlet string, on bool) error {
+			mu.Lock()
+			defer mu.Unlock()
+			off[outlet] = !on
+			switched = append(switched, outlet)
+			return nil
+		},
+		IsReachable: func(address string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			if address == "10.0.0.9" {
+				return false
+			}
+			for outlet, addresses := range wiring {
+				for _, a := range addresses {
+					if a == address && off[outlet] {
+						return false
+					}
+				}
+			}
+			return true
+		},
+	}
+
+	// a dry run does not touch any outlet
+	dryRun := config
+	dryRun.DryRun = true
+	outletMap, err := DiscoverOutletMap(dryRun)
+	require.NoError(t, err)
+	require.Empty(t, outletMap)
+	require.Empty(t, switched)
+
+	outletMap, err = DiscoverOutletMap(config)
+	require.NoError(t, err)
+	require.Equal(t, OutletMap{"x3000m0": {"AA1": "x3000c0s1b0"}}, outletMap)
+
+	// each outlet is switched off and back on
+	require.Equal(t, []string{"AA1", "AA1", "AA2", "AA2", "AA3", "AA3"}, switched)
+	require.False(t, off["AA1"] || off["AA2"] || off["AA3"])
+
+	// without reachable BMCs there is nothing to discover
+	config.BMCs = map[string]string{"x3000c0s9b0": "10.0.0.9"}
+	_, err = DiscoverOutletMap(config)
+	require.Error(t, err)
+}
+
+func TestDiscoverOutletMapRebootingBMCs(t *testing.T) {
+	t.Parallel()
+
+	// AA1 powers both BMCs of a chassis, which are still booting while AA2,
+	// which powers x3000c0s1b0, is cycled
+	var (
+		wiring = map[string][]string{
+			"AA1": {"10.0.0.2", "10.0.0.3"},
+			"AA2": {"10.0.0.1"},
+		}
+		off     = map[string]bool{}
+		booting = map[string]bool{}
+		mu      sync.Mutex
+	)
+	config := DiscoveryConfig{
+		PDU:     "x3000m0",
+		Outlets: []string{"AA1", "AA2"},
+		BMCs: map[string]string{
+			"x3000c0s1b0": "10.0.0.1",
+			"x3000c0s3b0": "10.0.0.2",
+			"x3000c0s3b1": "10.0.0.3",
+		},
+		SwitchOutlet: func(outlet string, on bool) error {
+			mu.Lock()
+			defer mu.Unlock()
+			off[outlet] = !on
+			if on {
+				for _, address := range wiring[outlet] {
+					booting[address] = true
+				}
+			}
+			return nil
+		},
+		IsReachable: func(address string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			for outlet, addresses := range wiring {
+				for _, a := range addresses {
+					if a == address && (off[outlet] || booting[address]) {
+						return false
+					}
+				}
+			}
+			return true
+		},
+	}
+
+	outletMap, err := DiscoverOutletMap(config)
+	require.NoError(t, err)
+	require.Equal(t, OutletMap{"x3000m0": {"AA2": "x3000c0s1b0"}}, outletMap)
+}
+
+func TestDiscoverOutletMapSwitchOffFailure(t *testing.T) {
+	t.Parallel()
+
+	// the outlet is switched off, but waiting for it to be off times out
+	var (
+		off = map[string]bool{}
+		mu  sync.Mutex
+	)
+	config := DiscoveryConfig{
+		PDU:     "x3000m0",
+		Outlets: []string{"AA1", "AA2"},
+		BMCs:    map[string]string{"x3000c0s1b0": "10.0.0.1"},
+		SwitchOutlet: func(outlet string, on bool) error {
+			mu.Lock()
+			defer mu.Unlock()
+			off[outlet] = !on
+			if !on {
+				return errors.New("timed out waiting for outlet to be off")
+			}
+			return nil
+		},
+		IsReachable: func(address string) bool { return true },
+	}
+
+	_, err := DiscoverOutletMap(config)
+	require.Error(t, err)
+	require.Equal(t, map[string]bool{"AA1": false}, off, "the outlet must be switched back on, and no other outlet cycled")
+}
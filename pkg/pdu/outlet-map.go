@@ -0,0 +1,98 @@
+package pdu
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/OpenCHAMI/magellan/internal/format"
+)
+
+// OutletMap maps the outlets of PDUs to the nodes they power, by PDU hostname
+// and then outlet ID, for example:
+//
+//	x3000m0:
+//	  AA1: x3000c0s1b0
+//	  AA2: x3000c0s3b0
+type OutletMap map[string]map[string]string
+
+// OutletRef identifies a single outlet of a PDU.
+type OutletRef struct {
+	PDU    string `json:"pdu" yaml:"pdu"`
+	Outlet string `json:"outlet" yaml:"outlet"`
+}
+
+// LoadOutletMap reads an outlet map from a JSON or YAML file, chosen by the
+// file extension and defaulting to YAML.
+func LoadOutletMap(path string) (OutletMap, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outlet map '%s': %w", path, err)
+	}
+	outletMap := OutletMap{}
+	if err := format.UnmarshalData(contents, &outletMap, format.DataFormatFromFileExt(path, format.FORMAT_YAML)); err != nil {
+		return nil, fmt.Errorf("failed to parse outlet map '%s': %w", path, err)
+	}
+	return outletMap, nil
+}
+
+// Save writes the outlet map to a JSON or YAML file, chosen by the file
+// extension and defaulting to YAML.
+func (m OutletMap) Save(path string) error {
+	contents, err := format.MarshalData(m, format.DataFormatFromFileExt(path, format.FORMAT_YAML))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, contents, 0o644); err != nil {
+		return fmt.Errorf("failed to write outlet map '%s': %w", path, err)
+	}
+	return nil
+}
+
+// Set maps an outlet of a PDU to the node it powers.
+func (m OutletMap) Set(pdu string, outlet string, node string) {
+	if m[pdu] == nil {
+		m[pdu] = map[string]string{}
+	}
+	m[pdu][outlet] = node
+}
+
+// Node returns the node powered by an outlet of a PDU, or an empty string if
+// the outlet is not mapped.
+func (m OutletMap) Node(pdu string, outlet string) string {
+	return m[pdu][outlet]
+}
+
+// OutletsOf returns the outlets that power a node, sorted by PDU and outlet.
+// Nodes with redundant power supplies are fed by more than one outlet.
+func (m OutletMap) OutletsOf(node string) []OutletRef {
+	var refs []OutletRef
+	for _, pdu := range slices.Sorted(maps.Keys(m)) {
+		for _, outlet := range slices.Sorted(maps.Keys(m[pdu])) {
+			if m[pdu][outlet] == node {
+				refs = append(refs, OutletRef{PDU: pdu, Outlet: outlet})
+			}
+		}
+	}
+	return refs
+}
+
+// Merge copies the mappings of another outlet map into this one, replacing
+// the node of outlets mapped in both.
+func (m OutletMap) Merge(other OutletMap) {
+	for pdu, outlets := range other {
+		for outlet, node := range outlets {
+			m.Set(pdu, outlet, node)
+		}
+	}
+}
+
+// Apply sets the node of each outlet of the inventory that is mapped.
+func (m OutletMap) Apply(inventory *PDUInventory) {
+	for i := range inventory.Outlets {
+		if node := m.Node(inventory.Hostname, inventory.Outlets[i].ID); node != "" {
+			inventory.Outlets[i].Node = node
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package pdu
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutletMap(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "outlets.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("x3000m0:\n  AA1: x3000c0s1b0\n  AA2: x3000c0s3b0\nx3000m1:\n  AA1: x3000c0s3b0\n"), 0o644))
+
+	outletMap, err := LoadOutletMap(path)
+	require.NoError(t, err)
+	require.Equal(t, "x3000c0s1b0", outletMap.Node("x3000m0", "AA1"))
+	require.Empty(t, outletMap.Node("x3000m0", "AA3"))
+	require.Empty(t, outletMap.Node("x3000m2", "AA1"))
+
+	// a node with redundant power supplies is fed by two PDUs
+	require.Equal(t, []OutletRef{{PDU: "x3000m0", Outlet: "AA2"}, {PDU: "x3000m1", Outlet: "AA1"}}, outletMap.OutletsOf("x3000c0s3b0"))
+
+	inventory := &PDUInventory{Hostname: "x3000m0", Outlets: []PDUOutlet{{ID: "AA1"}, {ID: "AA3"}}}
+	outletMap.Apply(inventory)
+	require.Equal(t, "x3000c0s1b0", inventory.Outlets[0].Node)
+	require.Empty(t, inventory.Outlets[1].Node)
+
+	// merged mappings replace existing ones and survive a round trip through JSON
+	outletMap.Merge(OutletMap{"x3000m0": {"AA1": "x3000c0s5b0"}, "x3000m2": {"BA1": "x3000c0s7b0"}})
+	path = filepath.Join(t.TempDir(), "outlets.json")
+	require.NoError(t, outletMap.Save(path))
+	saved, err := LoadOutletMap(path)
+	require.NoError(t, err)
+	require.Equal(t, outletMap, saved)
+	require.Equal(t, "x3000c0s5b0", saved.Node("x3000m0", "AA1"))
+
+	_, err = LoadOutletMap(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
@@ -1,13 +1,49 @@
 package pdu
 
 type PDUOutlet struct {
-	ID         string `json:"id"`          // e.g., "35" or "BA35"
-	Name       string `json:"name"`        // e.g., "Link1_Outlet_35"
-	PowerState string `json:"power_state"` // e.g., "ON" or "OFF"
-	SocketType string `json:"socket_type"`
+	ID          string  `json:"id"`          // e.g., "35" or "BA35"
+	Name        string  `json:"name"`        // e.g., "Link1_Outlet_35"
+	PowerState  string  `json:"power_state"` // e.g., "ON" or "OFF"
+	SocketType  string  `json:"socket_type"`
+	Current     float64 `json:"current"`        // amps
+	Voltage     float64 `json:"voltage"`        // volts
+	ActivePower float64 `json:"active_power"`   // watts
+	Node        string  `json:"node,omitempty"` // e.g., "x3000c0s3b0", see OutletMap
+}
+
+// PDUCircuit holds the readings of an input phase or an outlet branch (circuit
+// breaker) of a PDU.
+type PDUCircuit struct {
+	ID          string  `json:"id"` // e.g., "AA" for a phase or "AA1" for a branch
+	Name        string  `json:"name"`
+	Current     float64 `json:"current"`      // amps
+	Voltage     float64 `json:"voltage"`      // volts
+	ActivePower float64 `json:"active_power"` // watts
 }
 
 type PDUInventory struct {
-	Hostname string      `json:"hostname"`
-	Outlets  []PDUOutlet `json:"outlets"`
+	Hostname        string       `json:"hostname"`
+	Model           string       `json:"model,omitempty"`
+	SerialNumber    string       `json:"serial_number,omitempty"`
+	FirmwareVersion string       `json:"firmware_version,omitempty"`
+	Phases          []PDUCircuit `json:"phases,omitempty"`
+	Branches        []PDUCircuit `json:"branches,omitempty"`
+	Outlets         []PDUOutlet  `json:"outlets"`
+}
+
+// TotalActivePower returns the power drawn through the PDU, in watts. The
+// phase readings are used if available, since they include anything not
+// metered per outlet, otherwise the outlet readings are summed.
+func (inventory *PDUInventory) TotalActivePower() float64 {
+	var total float64
+	if len(inventory.Phases) > 0 {
+		for _, phase := range inventory.Phases {
+			total += phase.ActivePower
+		}
+		return total
+	}
+	for _, outlet := range inventory.Outlets {
+		total += outlet.ActivePower
+	}
+	return total
 }
@@ -0,0 +1,216 @@
+package power
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stmcginnis/gofish/schemas"
+)
+
+// The Redfish resources a power limit can be read from and written to.
+const (
+	PowerCapSourceEnvironmentMetrics = "EnvironmentMetrics" // Chassis EnvironmentMetrics PowerLimitWatts
+	PowerCapSourcePowerControl       = "PowerControl"       // legacy Chassis Power PowerControl PowerLimit
+)
+
+// PowerCap is the power limit of a node together with its current consumption.
+type PowerCap struct {
+	ClusterID      string   `json:"cluster_id"                yaml:"cluster_id"`
+	Chassis        string   `json:"chassis"                   yaml:"chassis"`
+	Source         string   `json:"source"                    yaml:"source"`
+	LimitWatts     *float64 `json:"limit_watts"               yaml:"limit_watts"`
+	LimitException string   `json:"limit_exception,omitempty" yaml:"limit_exception,omitempty"`
+	CorrectionInMs *int     `json:"correction_ms,omitempty"   yaml:"correction_ms,omitempty"`
+	MinWatts       *float64 `json:"min_watts,omitempty"       yaml:"min_watts,omitempty"`
+	MaxWatts       *float64 `json:"max_watts,omitempty"       yaml:"max_watts,omitempty"`
+	ConsumedWatts  *float64 `json:"consumed_watts"            yaml:"consumed_watts"`
+}
+
+// PowerCapSettings are the values written by SetPowerCap. The limit exception
+// and correction time are only supported by the legacy PowerControl resource
+// and are left unchanged when empty.
+type PowerCapSettings struct {
+	LimitWatts     float64
+	LimitException schemas.PowerLimitException
+	CorrectionInMs *int
+}
+
+// powerLimit holds the chassis resources providing the power limit of a node.
+type powerLimit struct {
+	chassis *schemas.Chassis
+	metrics *schemas.EnvironmentMetrics
+	power   *schemas.Power
+}
+
+// ParsePowerLimitException converts a case-insensitive limit exception into
+// a Redfish power limit exception.
+func ParsePowerLimitException(exception string) (schemas.PowerLimitException, error) {
+	for _, e := range []schemas.PowerLimitException{
+		schemas.NoActionPowerLimitException,
+		schemas.HardPowerOffPowerLimitException,
+		schemas.LogEventOnlyPowerLimitException,
+		schemas.OemPowerLimitException,
+	} {
+		if strings.EqualFold(string(e), exception) {
+			return e, nil
+		}
+	}
+	return "", fmt.Errorf("invalid limit exception '%s' (must be one of NoAction, HardPowerOff, LogEventOnly or Oem)", exception)
+}
+
+// GetPowerCap gets the power limit and current power consumption of a node
+// from the chassis linked to its ComputerSystem. The EnvironmentMetrics
+// resource is used when it has a power limit, otherwise the legacy Power
+// resource.
+//
+// Parameters:
+//   - node: A CrawlableNode struct containing the node's xname, index within the BMC, and a CrawlerConfig to connect to the BMC.
+//
+// Returns:
+//   - *PowerCap: The power limit of the node.
+//   - error: An error object if no chassis of the node has a power limit.
+func GetPowerCap(node CrawlableNode) (*PowerCap, error) {
+	limit, err := findPowerLimit(node)
+	if err != nil {
+		return nil, err
+	}
+	return limit.toPowerCap(node.ClusterID), nil
+}
+
+// SetPowerCap sets the power limit of a node. The limit is written to the
+// EnvironmentMetrics resource when it has a power limit, unless a limit
+// exception or correction time is requested and the legacy Power resource is
+// also available.
+//
+// Parameters:
+//   - node: A CrawlableNode struct containing the node's xname, index within the BMC, and a CrawlerConfig to connect to the BMC.
+//   - settings: The power limit to set.
+//
+// Returns:
+//   - *PowerCap: The power limit of the node as reported after the update.
+//   - error: An error object if the limit is out of range or the update failed.
+func SetPowerCap(node CrawlableNode, settings PowerCapSettings) (*PowerCap, error) {
+	limit, err := findPowerLimit(node)
+	if err != nil {
+		return nil, err
+	}
+	current := limit.toPowerCap(node.ClusterID)
+	if current.MinWatts != nil && settings.LimitWatts < *current.MinWatts {
+		return nil, fmt.Errorf("power limit of %.0f W is below the minimum of %.0f W of chassis %s", settings.LimitWatts, *current.MinWatts, limit.chassis.ID)
+	}
+	if current.MaxWatts != nil && settings.LimitWatts > *current.MaxWatts {
+		return nil, fmt.Errorf("power limit of %.0f W is above the maximum of %.0f W of chassis %s", settings.LimitWatts, *current.MaxWatts, limit.chassis.ID)
+	}
+
+	legacyOnly := settings.LimitException != "" || settings.CorrectionInMs != nil
+	if limit.metrics != nil && (!legacyOnly || limit.power == nil) {
+		if legacyOnly {
+			log.Warn().Msgf("chassis %s only supports a power limit through EnvironmentMetrics; ignoring limit exception and correction time", limit.chassis.ID)
+		}
+		log.Debug().Msgf("setting power limit of %s to %.0f W through %s", node.ClusterID, settings.LimitWatts, limit.metrics.ODataID)
+		payload := map[string]any{
+			"PowerLimitWatts": map[string]any{"SetPoint": settings.LimitWatts},
+		}
+		if err = limit.metrics.Patch(limit.metrics.ODataID, payload); err != nil {
+			return nil, fmt.Errorf("failed to set power limit of chassis %s: %w", limit.chassis.ID, err)
+		}
+	} else {
+		log.Debug().Msgf("setting power limit of %s to %.0f W through %s", node.ClusterID, settings.LimitWatts, limit.power.ODataID)
+		powerLimit := map[string]any{"LimitInWatts": settings.LimitWatts}
+		if settings.LimitException != "" {
+			powerLimit["LimitException"] = settings.LimitException
+		}
+		if settings.CorrectionInMs != nil {
+			powerLimit["CorrectionInMs"] = *settings.CorrectionInMs
+		}
+		// array members are PATCHed by position, so this only changes the first PowerControl
+		payload := map[string]any{
+			"PowerControl": []map[string]any{{"PowerLimit": powerLimit}},
+		}
+		if err = limit.power.Patch(limit.power.ODataID, payload); err != nil {
+			return nil, fmt.Errorf("failed to set power limit of chassis %s: %w", limit.chassis.ID, err)
+		}
+	}
+
+	// report the limit as the BMC sees it now
+	return GetPowerCap(node)
+}
+
+// findPowerLimit returns the first chassis of a node's ComputerSystem with a
+// power limit in either its EnvironmentMetrics or its legacy Power resource.
+func findPowerLimit(node CrawlableNode) (*powerLimit, error) {
+	rf_system, err := GetComputerSystem(node)
+	if err != nil {
+		return nil, err
+	}
+	rf_chassis, err := rf_system.Chassis()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chassis of system %s: %w", rf_system.ID, err)
+	}
+	for _, chassis := range rf_chassis {
+		limit := powerLimit{chassis: chassis}
+		metrics, err := chassis.EnvironmentMetrics()
+		if err != nil {
+			log.Debug().Err(err).Msgf("failed to get environment metrics of chassis %s", chassis.ID)
+		} else if metrics != nil && hasPowerLimit(metrics) {
+			limit.metrics = metrics
+		}
+		power, err := chassis.Power()
+		if err != nil {
+			log.Debug().Err(err).Msgf("failed to get power of chassis %s", chassis.ID)
+		} else if power != nil && len(power.PowerControl) > 0 {
+			limit.power = power
+		}
+		if limit.metrics != nil || limit.power != nil {
+			return &limit, nil
+		}
+	}
+	return nil, fmt.Errorf("no chassis of system %s has a power limit", rf_system.ID)
+}
+
+// hasPowerLimit reports whether EnvironmentMetrics contains a power limit control.
+func hasPowerLimit(metrics *schemas.EnvironmentMetrics) bool {
+	l := metrics.PowerLimitWatts
+	return l.SetPoint != nil || l.AllowableMax != nil || l.ControlMode != "" || l.DataSourceURI != ""
+}
+
+func (l *powerLimit) toPowerCap(clusterID string) *PowerCap {
+	powerCap := &PowerCap{
+		ClusterID: clusterID,
+		Chassis:   l.chassis.ODataID,
+	}
+	if l.metrics != nil {
+		powerCap.Source = PowerCapSourceEnvironmentMetrics
+		powerCap.LimitWatts = l.metrics.PowerLimitWatts.SetPoint
+		powerCap.MinWatts = l.metrics.PowerLimitWatts.AllowableMin
+		powerCap.MaxWatts = l.metrics.PowerLimitWatts.AllowableMax
+		powerCap.ConsumedWatts = l.metrics.PowerWatts.Reading
+		// a disabled control does not limit anything
+		if l.metrics.PowerLimitWatts.ControlMode == schemas.DisabledControlMode {
+			powerCap.LimitWatts = nil
+		}
+	}
+	if l.power != nil {
+		control := l.power.PowerControl[0]
+		if powerCap.Source == "" {
+			powerCap.Source = PowerCapSourcePowerControl
+			powerCap.LimitWatts = control.PowerLimit.LimitInWatts
+			powerCap.MaxWatts = toFloat64(control.PowerCapacityWatts)
+		}
+		if powerCap.Source == PowerCapSourcePowerControl || powerCap.ConsumedWatts == nil {
+			powerCap.ConsumedWatts = toFloat64(control.PowerConsumedWatts)
+		}
+		powerCap.LimitException = string(control.PowerLimit.LimitException)
+		powerCap.CorrectionInMs = control.PowerLimit.CorrectionInMs
+	}
+	return powerCap
+}
+
+func toFloat64(value *float32) *float64 {
+	if value == nil {
+		return nil
+	}
+	v := float64(*value)
+	return &v
+}
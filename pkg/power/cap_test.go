@@ -0,0 +1,123 @@
+package power
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPowerCap(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		patched = make(map[string]map[string]any)
+	)
+	mux := http.NewServeMux()
+	// serve a static resource, recording the body of any PATCH
+	handle := func(uri string, body string) {
+		mux.HandleFunc(uri, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPatch {
+				payload, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				defer mu.Unlock()
+				var p map[string]any
+				_ = json.Unmarshal(payload, &p)
+				patched[uri] = p
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			_, _ = w.Write([]byte(body))
+		})
+	}
+	handle("/redfish/v1/", `{"@odata.id": "/redfish/v1/", "Systems": {"@odata.id": "/redfish/v1/Systems"}}`)
+	handle("/redfish/v1/Systems", `{"Members": [{"@odata.id": "/redfish/v1/Systems/Node0"}, {"@odata.id": "/redfish/v1/Systems/Node1"}]}`)
+	handle("/redfish/v1/Systems/Node0", `{"@odata.id": "/redfish/v1/Systems/Node0", "Id": "Node0", "Links": {"Chassis": [{"@odata.id": "/redfish/v1/Chassis/Legacy"}]}}`)
+	handle("/redfish/v1/Systems/Node1", `{"@odata.id": "/redfish/v1/Systems/Node1", "Id": "Node1", "Links": {"Chassis": [{"@odata.id": "/redfish/v1/Chassis/Modern"}]}}`)
+	handle("/redfish/v1/Chassis/Legacy", `{"@odata.id": "/redfish/v1/Chassis/Legacy", "Id": "Legacy", "Power": {"@odata.id": "/redfish/v1/Chassis/Legacy/Power"}}`)
+	handle("/redfish/v1/Chassis/Legacy/Power", `{
+		"@odata.id": "/redfish/v1/Chassis/Legacy/Power",
+		"Id": "Power",
+		"PowerControl": [{
+			"@odata.id": "/redfish/v1/Chassis/Legacy/Power#/PowerControl/0",
+			"MemberId": "0",
+			"PowerConsumedWatts": 312,
+			"PowerCapacityWatts": 800,
+			"PowerLimit": {"LimitInWatts": 500, "LimitException": "LogEventOnly", "CorrectionInMs": 1000}
+		}]
+	}`)
+	handle("/redfish/v1/Chassis/Modern", `{"@odata.id": "/redfish/v1/Chassis/Modern", "Id": "Modern", "EnvironmentMetrics": {"@odata.id": "/redfish/v1/Chassis/Modern/EnvironmentMetrics"}}`)
+	handle("/redfish/v1/Chassis/Modern/EnvironmentMetrics", `{
+		"@odata.id": "/redfish/v1/Chassis/Modern/EnvironmentMetrics",
+		"Id": "EnvironmentMetrics",
+		"PowerWatts": {"Reading": 410.5},
+		"PowerLimitWatts": {"SetPoint": 600, "AllowableMin": 200, "AllowableMax": 900, "ControlMode": "Automatic"}
+	}`)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	defer LogoutBMCSessions()
+
+	newNode := func(clusterID string, nodeID string) CrawlableNode {
+		return CrawlableNode{
+			ClusterID: clusterID,
+			NodeID:    nodeID,
+			ConnConfig: crawler.CrawlerConfig{
+				URI:             server.URL,
+				CredentialStore: secrets.NewStaticStore("user", "pass"),
+			},
+		}
+	}
+	legacy, modern := newNode("x1000c0s0b0n0", "Node0"), newNode("x1000c0s0b0n1", "Node1")
+
+	// the legacy PowerControl limit is reported with the consumption
+	powerCap, err := GetPowerCap(legacy)
+	require.NoError(t, err)
+	require.Equal(t, PowerCapSourcePowerControl, powerCap.Source)
+	require.Equal(t, 500.0, *powerCap.LimitWatts)
+	require.Equal(t, 312.0, *powerCap.ConsumedWatts)
+	require.Equal(t, 800.0, *powerCap.MaxWatts)
+	require.Equal(t, "LogEventOnly", powerCap.LimitException)
+
+	exception, err := ParsePowerLimitException("hardpoweroff")
+	require.NoError(t, err)
+	correction := 2000
+	_, err = SetPowerCap(legacy, PowerCapSettings{LimitWatts: 450, LimitException: exception, CorrectionInMs: &correction})
+	require.NoError(t, err)
+	mu.Lock()
+	require.Equal(t, map[string]any{
+		"PowerControl": []any{map[string]any{"PowerLimit": map[string]any{
+			"LimitInWatts":   450.0,
+			"LimitException": "HardPowerOff",
+			"CorrectionInMs": 2000.0,
+		}}},
+	}, patched["/redfish/v1/Chassis/Legacy/Power"])
+	mu.Unlock()
+
+	// the newer EnvironmentMetrics limit is preferred where available
+	powerCap, err = GetPowerCap(modern)
+	require.NoError(t, err)
+	require.Equal(t, PowerCapSourceEnvironmentMetrics, powerCap.Source)
+	require.Equal(t, 600.0, *powerCap.LimitWatts)
+	require.Equal(t, 410.5, *powerCap.ConsumedWatts)
+
+	_, err = SetPowerCap(modern, PowerCapSettings{LimitWatts: 350})
+	require.NoError(t, err)
+	mu.Lock()
+	require.Equal(t, map[string]any{"PowerLimitWatts": map[string]any{"SetPoint": 350.0}}, patched["/redfish/v1/Chassis/Modern/EnvironmentMetrics"])
+	mu.Unlock()
+
+	// limits outside of the allowable range are rejected before anything is sent
+	_, err = SetPowerCap(modern, PowerCapSettings{LimitWatts: 100})
+	require.Error(t, err)
+
+	_, err = ParsePowerLimitException("shrug")
+	require.Error(t, err)
+}
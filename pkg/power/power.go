@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/OpenCHAMI/magellan/internal/format"
 	"github.com/OpenCHAMI/magellan/pkg/bmc"
@@ -27,7 +31,18 @@ type PowerInfo struct {
 }
 
 // Hold onto the current set of open clients, so we don't continually have to log into and out of BMCs
-var savedClients map[string]*gofish.APIClient
+var (
+	savedClients   map[string]*bmcSession
+	savedClientsMu sync.Mutex // only guards the map, never held while logging in
+)
+
+// bmcSession is the client of a single BMC, which is logged in to at most
+// once however many nodes of the BMC ask for it concurrently.
+type bmcSession struct {
+	once   sync.Once
+	client *gofish.APIClient
+	err    error
+}
 
 // ParseInventory reads parameters relevant to power control from the kind of YAML file generated by the `collect` command.
 //
@@ -77,14 +92,15 @@ func ParseInventory(filename string, dataFormat format.DataFormat) ([]bmc.Node,
 		systems := inventory[i].Systems
 		for j := range systems {
 			nodelist = append(nodelist, bmc.Node{
-				// TODO: This assumes indices in the Systems list correspond to nodes' "…nX" xname components.
-				// If the list is reordered at any point, or if nodes were missing during crawl, this may not hold!
-				// FIXME: This assumes strict xname formatting! To become xname-agnostic, this should be
-				// replaced with some other cluster-wide ID (which the BMC/ComputerSystem itself won't know, so
-				// it'll have to be generated/looked up from somewhere else).
-				ClusterID: fmt.Sprintf("%sn%d", inventory[i].ID, j),
-				BmcIP:     inventory[i].FQDN,
-				NodeID:    systems[j].NodeID,
+				// NOTE: This assumes indices in the Systems list correspond to nodes' "…nX" xname components,
+				// which may not hold if the list is reordered or nodes were missing during crawl. Nodes can
+				// also be resolved by their UUID, serial number or System URI instead (see ResolveNodes).
+				ClusterID:    fmt.Sprintf("%sn%d", inventory[i].ID, j),
+				BmcIP:        inventory[i].FQDN,
+				NodeID:       systems[j].NodeID,
+				UUID:         systems[j].UUID,
+				SerialNumber: systems[j].SerialNumber,
+				SystemURI:    systems[j].URI,
 			})
 		}
 	}
@@ -111,17 +127,10 @@ func GetResetTypes(node CrawlableNode) ([]schemas.ResetType, error) {
 	}
 
 	// Determine reset types for the target computer system
-	rf_systems, err := client.GetService().Systems()
+	system, err := findComputerSystem(client, node)
 	if err != nil {
 		return nil, err
 	}
-	var system *schemas.ComputerSystem
-	for i := range rf_systems {
-		if rf_systems[i].ID == node.NodeID {
-			system = rf_systems[i]
-			break
-		}
-	}
 
 	resetTypes, err := system.GetSupportedResetTypes()
 	if err != nil {
@@ -149,18 +158,106 @@ func GetPowerState(node CrawlableNode) (schemas.PowerState, error) {
 	}
 
 	// Determine power details for the target computer system
-	rf_systems, err := client.GetService().Systems()
+	system, err := findComputerSystem(client, node)
 	if err != nil {
 		return "", err
 	}
-	var system *schemas.ComputerSystem
-	for i := range rf_systems {
-		if rf_systems[i].ID == node.NodeID {
-			system = rf_systems[i]
-			break
+	return system.PowerState, nil
+}
+
+// ExpectedPowerState returns the power state a computer system should end up in
+// after a reset of the given type. Warm restarts such as ForceRestart and
+// GracefulRestart have none, since many BMCs report the system as On throughout.
+//
+// Parameters:
+//   - resetType: The schemas.ResetType that was performed.
+//
+// Returns:
+//   - schemas.PowerState: The expected power state after the reset.
+//   - bool: Whether the expected power state is known. Reset types such as ForceRestart, PushPowerButton or Nmi do not have one.
+func ExpectedPowerState(resetType schemas.ResetType) (schemas.PowerState, bool) {
+	switch resetType {
+	case schemas.OnResetType, schemas.ForceOnResetType,
+		schemas.PowerCycleResetType, schemas.FullPowerCycleResetType:
+		return schemas.OnPowerState, true
+	case schemas.ForceOffResetType, schemas.GracefulShutdownResetType:
+		return schemas.OffPowerState, true
+	}
+	return "", false
+}
+
+// CyclesPower reports whether a reset of the given type switches a computer
+// system off and back on. Since the system is already On when such a reset is
+// sent, waiting for it must first see it leave that state (see WaitForPowerCycle).
+func CyclesPower(resetType schemas.ResetType) bool {
+	return resetType == schemas.PowerCycleResetType || resetType == schemas.FullPowerCycleResetType
+}
+
+// WaitForPowerState polls the power state of a computer system until it reaches the expected state or
+// the timeout expires. Errors while polling are only logged, since BMCs may briefly stop responding
+// while a system changes state.
+//
+// Parameters:
+//   - node: A CrawlableNode struct containing the target node's xname, index within the BMC, and a crawler.CrawlerConfig struct.
+//   - expected: The schemas.PowerState to wait for.
+//   - timeout: How long to wait for the expected state.
+//   - interval: How long to wait between polls.
+//
+// Returns:
+//   - schemas.PowerState: The last power state that was observed.
+//   - error: An error object if the expected state was not reached before the timeout.
+func WaitForPowerState(node CrawlableNode, expected schemas.PowerState, timeout time.Duration, interval time.Duration) (schemas.PowerState, error) {
+	return pollPowerState(node, timeout, interval, string(expected), func(state schemas.PowerState) bool {
+		return state == expected
+	})
+}
+
+// WaitForPowerCycle polls the power state of a computer system until it has left the On state and
+// then reached it again, or the timeout expires. The interval must be shorter than the time the
+// system spends off, or the cycle is missed.
+//
+// Parameters:
+//   - node: A CrawlableNode struct containing the target node's xname, index within the BMC, and a crawler.CrawlerConfig struct.
+//   - timeout: How long to wait for the whole power cycle.
+//   - interval: How long to wait between polls.
+//
+// Returns:
+//   - schemas.PowerState: The last power state that was observed.
+//   - error: An error object if the system did not leave and reach On again before the timeout.
+func WaitForPowerCycle(node CrawlableNode, timeout time.Duration, interval time.Duration) (schemas.PowerState, error) {
+	deadline := time.Now().Add(timeout)
+	state, err := pollPowerState(node, timeout, interval, "a state other than On", func(state schemas.PowerState) bool {
+		return state != schemas.OnPowerState
+	})
+	if err != nil {
+		return state, err
+	}
+	return WaitForPowerState(node, schemas.OnPowerState, time.Until(deadline), interval)
+}
+
+// pollPowerState polls the power state of a computer system until done returns true for it or the
+// timeout expires. Errors while polling are only logged.
+func pollPowerState(node CrawlableNode, timeout time.Duration, interval time.Duration, waitingFor string, done func(schemas.PowerState) bool) (schemas.PowerState, error) {
+	var (
+		deadline = time.Now().Add(timeout)
+		state    schemas.PowerState
+	)
+	for {
+		current, err := GetPowerState(node)
+		if err != nil {
+			log.Debug().Err(err).Msgf("failed to poll power state of %s, retrying", node.ClusterID)
+		} else {
+			state = current
+			if done(state) {
+				return state, nil
+			}
+			log.Debug().Msgf("power state of %s is %s, waiting for %s", node.ClusterID, state, waitingFor)
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return state, fmt.Errorf("timed out after %s waiting for %s to reach power state %s", timeout, node.ClusterID, waitingFor)
 		}
+		time.Sleep(interval)
 	}
-	return system.PowerState, nil
 }
 
 // ResetComputerSystem connects to a BMC (Baseboard Management Controller) using the provided configuration,
@@ -186,20 +283,106 @@ func ResetComputerSystem(node CrawlableNode, resetType schemas.ResetType) (*sche
 	log.Debug().Msgf("found ServiceRoot %s. Redfish Version %s", rf_service.ID, rf_service.RedfishVersion)
 
 	// Select the relevant ComputerSystem
-	rf_systems, err := rf_service.Systems()
+	rf_compsys, err := findComputerSystem(client, node)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reset the system
+	return rf_compsys.Reset(resetType)
+}
+
+// ParseBootSource converts a case-insensitive boot source name (e.g. 'pxe' or
+// 'UefiHttp') into a Redfish boot source override target.
+func ParseBootSource(source string) (schemas.BootSource, error) {
+	for _, s := range []schemas.BootSource{
+		schemas.PxeBootSource,
+		schemas.HddBootSource,
+		schemas.CdBootSource,
+		schemas.UsbBootSource,
+		schemas.BiosSetupBootSource,
+		schemas.UefiHTTPBootSource,
+		schemas.UefiShellBootSource,
+		schemas.UefiTargetBootSource,
+		schemas.UtilitiesBootSource,
+		schemas.DiagsBootSource,
+		schemas.RemoteDriveBootSource,
+		schemas.RecoveryBootSource,
+		schemas.NoneBootSource,
+	} {
+		if strings.EqualFold(string(s), source) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("invalid boot source '%s' (e.g. Pxe, Hdd, Cd, BiosSetup or UefiHttp)", source)
+}
+
+// SetBootOverride connects to a BMC (Baseboard Management Controller) using the provided configuration,
+// and PATCHes the boot source override of a particular computer system. This is meant to be done before
+// a reset, so that the system boots from the given target.
+//
+// Parameters:
+//   - node: A CrawlableNode struct containing the node's xname, index within the BMC, and a CrawlerConfig to connect to the BMC.
+//   - target: The schemas.BootSource to boot from.
+//   - enabled: Whether the override applies to the next boot only (Once) or to all boots (Continuous).
+//
+// Returns:
+//   - error: An error object if the target is not supported or any error occurs during the connection or update process.
+func SetBootOverride(node CrawlableNode, target schemas.BootSource, enabled schemas.BootSourceOverrideEnabled) error {
+	log.Debug().Msgf("setting boot source override of %s to %s (%s)", node.ClusterID, target, enabled)
+
+	// Obtain an active client
+	client, err := GetBMCSession(node.ConnConfig)
+	if err != nil {
+		return err
+	}
+
+	// Select the relevant ComputerSystem
+	rf_compsys, err := findComputerSystem(client, node)
+	if err != nil {
+		return err
+	}
+
+	// Not every BMC advertises the allowable targets, so only check when it does
+	allowed := rf_compsys.Boot.AllowableBootSourceOverrideTargetValues
+	if len(allowed) > 0 && !slices.Contains(allowed, target) {
+		return fmt.Errorf("boot source %s is not supported by system %s (supported: %v)", target, rf_compsys.ID, allowed)
+	}
+
+	return rf_compsys.SetBoot(&schemas.Boot{
+		BootSourceOverrideTarget:  target,
+		BootSourceOverrideEnabled: enabled,
+	})
+}
+
+// GetComputerSystem returns the ComputerSystem of a node, using an active BMC session.
+//
+// Parameters:
+//   - node: A CrawlableNode struct containing the node's xname, index within the BMC, and a CrawlerConfig to connect to the BMC.
+//
+// Returns:
+//   - *schemas.ComputerSystem: The ComputerSystem of the node.
+//   - error: An error object if the connection fails or the system no longer exists on the BMC.
+func GetComputerSystem(node CrawlableNode) (*schemas.ComputerSystem, error) {
+	client, err := GetBMCSession(node.ConnConfig)
 	if err != nil {
 		return nil, err
 	}
-	var rf_compsys *schemas.ComputerSystem
+	return findComputerSystem(client, node)
+}
+
+// findComputerSystem selects the ComputerSystem of a node among the systems of its BMC.
+func findComputerSystem(client *gofish.APIClient, node CrawlableNode) (*schemas.ComputerSystem, error) {
+	rf_systems, err := client.GetService().Systems()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get systems from %s: %w", node.ConnConfig.URI, err)
+	}
 	for i := range rf_systems {
 		if rf_systems[i].ID == node.NodeID {
-			rf_compsys = rf_systems[i]
-			break
+			return rf_systems[i], nil
 		}
 	}
-
-	// Reset the system
-	return rf_compsys.Reset(resetType)
+	return nil, fmt.Errorf("system '%s' of node %s not found on %s (the inventory may be out of date)", node.NodeID, node.ClusterID, node.ConnConfig.URI)
 }
 
 // GetBMCSession returns an already-active gofish BMC client, creating a new one if necessary.
@@ -210,22 +393,35 @@ func ResetComputerSystem(node CrawlableNode, resetType schemas.ResetType) (*sche
 //
 // Returns: none.
 func GetBMCSession(config crawler.CrawlerConfig) (*gofish.APIClient, error) {
-	client, exists := savedClients[config.URI]
+	savedClientsMu.Lock()
+	if savedClients == nil {
+		savedClients = make(map[string]*bmcSession)
+	}
+	session, exists := savedClients[config.URI]
+	if !exists {
+		session = &bmcSession{}
+		savedClients[config.URI] = session
+	}
+	savedClientsMu.Unlock()
+
+	// log in outside of the lock, so that a slow BMC doesn't hold up the others
 	if exists {
 		log.Debug().Msgf("found existing client for %s", config.URI)
-	} else {
-		if savedClients == nil {
-			savedClients = make(map[string]*gofish.APIClient)
-		}
-		var err error
-		client, err = crawler.GetBMCClient(config)
-		if err != nil {
-			return nil, err
+	}
+	session.once.Do(func() {
+		session.client, session.err = crawler.GetBMCClient(config)
+		if session.err != nil {
+			// let the next caller try again
+			savedClientsMu.Lock()
+			if savedClients[config.URI] == session {
+				delete(savedClients, config.URI)
+			}
+			savedClientsMu.Unlock()
+			return
 		}
 		log.Debug().Msgf("created new client for %s", config.URI)
-		savedClients[config.URI] = client
-	}
-	return client, nil
+	})
+	return session.client, session.err
 }
 
 // LogoutBMCSessions logs out all active gofish BMC clients, which we normally like to keep open for efficiency.
@@ -235,8 +431,14 @@ func GetBMCSession(config crawler.CrawlerConfig) (*gofish.APIClient, error) {
 //
 // Returns: none.
 func LogoutBMCSessions() {
-	for uri, client := range savedClients {
+	savedClientsMu.Lock()
+	defer savedClientsMu.Unlock()
+	for uri, session := range savedClients {
+		if session.client == nil {
+			continue
+		}
 		log.Debug().Msgf("logging out client for %s", uri)
-		client.Logout()
+		session.client.Logout()
 	}
+	savedClients = nil
 }
@@ -0,0 +1,235 @@
+package power
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetBootOverride(t *testing.T) {
+	t.Parallel()
+
+	var patched map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redfish/v1/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"@odata.id": "/redfish/v1/", "Systems": {"@odata.id": "/redfish/v1/Systems"}}`))
+	})
+	mux.HandleFunc("/redfish/v1/Systems", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Members": [{"@odata.id": "/redfish/v1/Systems/Node0"}]}`))
+	})
+	mux.HandleFunc("/redfish/v1/Systems/Node0", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			body, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(body, &patched)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_, _ = w.Write([]byte(`{
+			"@odata.id": "/redfish/v1/Systems/Node0",
+			"Id": "Node0",
+			"Boot": {
+				"BootSourceOverrideEnabled": "Disabled",
+				"BootSourceOverrideTarget": "None",
+				"BootSourceOverrideTarget@Redfish.AllowableValues": ["None", "Pxe", "Hdd"]
+			}
+		}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	defer LogoutBMCSessions()
+
+	node := CrawlableNode{
+		ClusterID: "x1000c0s0b0n0",
+		NodeID:    "Node0",
+		ConnConfig: crawler.CrawlerConfig{
+			URI:             server.URL,
+			CredentialStore: secrets.NewStaticStore("user", "pass"),
+		},
+	}
+
+	source, err := ParseBootSource("pxe")
+	require.NoError(t, err)
+	require.NoError(t, SetBootOverride(node, source, schemas.OnceBootSourceOverrideEnabled))
+	require.Equal(t, map[string]any{
+		"Boot": map[string]any{
+			"BootSourceOverrideTarget":  "Pxe",
+			"BootSourceOverrideEnabled": "Once",
+		},
+	}, patched)
+
+	// targets not advertised by the BMC are rejected
+	source, err = ParseBootSource("UefiHttp")
+	require.NoError(t, err)
+	require.Error(t, SetBootOverride(node, source, schemas.ContinuousBootSourceOverrideEnabled))
+
+	_, err = ParseBootSource("floppy-disk")
+	require.Error(t, err)
+}
+
+func TestWaitForPowerState(t *testing.T) {
+	t.Parallel()
+
+	var polls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redfish/v1/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"@odata.id": "/redfish/v1/", "Systems": {"@odata.id": "/redfish/v1/Systems"}}`))
+	})
+	mux.HandleFunc("/redfish/v1/Systems", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Members": [{"@odata.id": "/redfish/v1/Systems/Node0"}]}`))
+	})
+	mux.HandleFunc("/redfish/v1/Systems/Node0", func(w http.ResponseWriter, r *http.Request) {
+		// the node takes a few polls to power on
+		state := "PoweringOn"
+		if polls.Add(1) > 3 {
+			state = "On"
+		}
+		_, _ = w.Write([]byte(`{"@odata.id": "/redfish/v1/Systems/Node0", "Id": "Node0", "PowerState": "` + state + `"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	defer LogoutBMCSessions()
+
+	node := CrawlableNode{
+		ClusterID: "x1000c0s0b1n0",
+		NodeID:    "Node0",
+		ConnConfig: crawler.CrawlerConfig{
+			URI:             server.URL,
+			CredentialStore: secrets.NewStaticStore("user", "pass"),
+		},
+	}
+
+	expected, known := ExpectedPowerState(schemas.OnResetType)
+	require.True(t, known)
+
+	// the timeout expires before the node is on
+	state, err := WaitForPowerState(node, expected, 0, time.Millisecond)
+	require.Error(t, err)
+	require.Equal(t, schemas.PoweringOnPowerState, state)
+
+	state, err = WaitForPowerState(node, expected, time.Second, time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, schemas.OnPowerState, state)
+
+	_, known = ExpectedPowerState(schemas.NmiResetType)
+	require.False(t, known)
+}
+
+func TestWaitForPowerCycle(t *testing.T) {
+	t.Parallel()
+
+	var polls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redfish/v1/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"@odata.id": "/redfish/v1/", "Systems": {"@odata.id": "/redfish/v1/Systems"}}`))
+	})
+	mux.HandleFunc("/redfish/v1/Systems", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Members": [{"@odata.id": "/redfish/v1/Systems/Node0"}]}`))
+	})
+	mux.HandleFunc("/redfish/v1/Systems/Node0", func(w http.ResponseWriter, r *http.Request) {
+		// the node is still on when the reset is accepted, then goes off
+		// and comes back on
+		state := "On"
+		switch n := polls.Add(1); {
+		case n > 2 && n <= 4:
+			state = "Off"
+		case n > 4 && n <= 5:
+			state = "PoweringOn"
+		}
+		_, _ = w.Write([]byte(`{"@odata.id": "/redfish/v1/Systems/Node0", "Id": "Node0", "PowerState": "` + state + `"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	defer LogoutBMCSessions()
+
+	node := CrawlableNode{
+		ClusterID: "x1000c0s0b2n0",
+		NodeID:    "Node0",
+		ConnConfig: crawler.CrawlerConfig{
+			URI:             server.URL,
+			CredentialStore: secrets.NewStaticStore("user", "pass"),
+		},
+	}
+
+	require.True(t, CyclesPower(schemas.PowerCycleResetType))
+	require.False(t, CyclesPower(schemas.OnResetType))
+
+	state, err := WaitForPowerCycle(node, time.Second, time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, schemas.OnPowerState, state)
+	require.Greater(t, polls.Load(), int32(5), "the node must be seen off before it is on again")
+
+	// warm restarts are not waited for
+	_, known := ExpectedPowerState(schemas.ForceRestartResetType)
+	require.False(t, known)
+}
+
+func TestGetBMCSession(t *testing.T) {
+	// the slow BMC doesn't answer until it is released
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_, _ = w.Write([]byte(`{"@odata.id": "/redfish/v1/"}`))
+	}))
+	defer slow.Close()
+	var logins atomic.Int32
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logins.Add(1)
+		_, _ = w.Write([]byte(`{"@odata.id": "/redfish/v1/"}`))
+	}))
+	defer fast.Close()
+	defer LogoutBMCSessions()
+
+	store := secrets.NewStaticStore("user", "pass")
+	slowDone := make(chan error)
+	go func() {
+		_, err := GetBMCSession(crawler.CrawlerConfig{URI: slow.URL, CredentialStore: store})
+		slowDone <- err
+	}()
+
+	// logging in to the slow BMC doesn't hold up the others, and concurrent
+	// callers for the same BMC share a single login
+	var (
+		wg      sync.WaitGroup
+		clients = make([]*gofish.APIClient, 5)
+	)
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := GetBMCSession(crawler.CrawlerConfig{URI: fast.URL, CredentialStore: store})
+			require.NoError(t, err)
+			clients[i] = client
+		}()
+	}
+	fastDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(fastDone)
+	}()
+	select {
+	case <-fastDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging in to a BMC waited for another, slow BMC")
+	}
+	require.Equal(t, int32(1), logins.Load())
+	for _, client := range clients {
+		require.Same(t, clients[0], client)
+	}
+
+	close(release)
+	require.NoError(t, <-slowDone)
+}
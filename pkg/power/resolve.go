@@ -0,0 +1,146 @@
+package power
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/OpenCHAMI/magellan/pkg/bmc"
+	"gopkg.in/yaml.v3"
+)
+
+// NodeKey selects which property of the nodes in an inventory is matched
+// against the node IDs requested by the user.
+type NodeKey string
+
+const (
+	NodeKeyAuto   NodeKey = "auto"   // try each of the keys below, in order
+	NodeKeyXname  NodeKey = "xname"  // the xname derived from the BMC ID and system index
+	NodeKeyUUID   NodeKey = "uuid"   // the UUID of the ComputerSystem
+	NodeKeySerial NodeKey = "serial" // the serial number of the ComputerSystem
+	NodeKeyURI    NodeKey = "uri"    // the Redfish URI of the ComputerSystem
+)
+
+var nodeKeys = []NodeKey{NodeKeyXname, NodeKeyUUID, NodeKeySerial, NodeKeyURI}
+
+func (k NodeKey) String() string {
+	return string(k)
+}
+
+// ParseNodeKey converts a case-insensitive key name into a NodeKey.
+func ParseNodeKey(key string) (NodeKey, error) {
+	for _, k := range append([]NodeKey{NodeKeyAuto}, nodeKeys...) {
+		if strings.EqualFold(string(k), key) {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("invalid node key '%s' (must be one of auto, xname, uuid, serial or uri)", key)
+}
+
+// ResolvedNode is an inventory node matched to the ID requested by the user.
+type ResolvedNode struct {
+	ID   string   // the ID as requested by the user
+	Node bmc.Node // the matching node from the inventory
+}
+
+// LoadNodeMap reads a YAML (or JSON) file mapping user-defined node names to
+// the xname, UUID, serial number or System URI of a node, e.g.
+//
+//	compute-001: 4c4c4544-0042-3510-8052-b4c04f4d3232
+//	compute-002: x1000c0s0b3n1
+func LoadNodeMap(path string) (map[string]string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node mapping file: %w", err)
+	}
+	var nodeMap map[string]string
+	if err = yaml.Unmarshal(contents, &nodeMap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal node mapping file: %w", err)
+	}
+	return nodeMap, nil
+}
+
+// ResolveNodes matches each requested node ID against the nodes of an inventory.
+// IDs found in the node map are first replaced by their mapped value. Requested
+// IDs that match no node, or more than one node, are returned with the reason instead.
+//
+// Parameters:
+//   - nodes: The nodes parsed from an inventory, see ParseInventory.
+//   - ids: The node IDs requested by the user.
+//   - key: The property of the nodes to match the IDs against.
+//   - nodeMap: An optional mapping of user-defined names to node IDs.
+//
+// Returns:
+//   - []ResolvedNode: The requested nodes that were found in the inventory, in the requested order.
+//   - map[string]error: The reason each requested node that could not be resolved, by requested ID.
+func ResolveNodes(nodes []bmc.Node, ids []string, key NodeKey, nodeMap map[string]string) ([]ResolvedNode, map[string]error) {
+	var (
+		resolved   = make([]ResolvedNode, 0, len(ids))
+		unresolved = make(map[string]error)
+		keys       = []NodeKey{key}
+	)
+	if key == NodeKeyAuto {
+		keys = nodeKeys
+	}
+	for _, id := range ids {
+		value := id
+		if mapped, found := nodeMap[id]; found {
+			value = mapped
+		}
+
+		// use the first key that matches anything, so an xname can't also be
+		// mistaken for a serial number
+		var matches []bmc.Node
+		for _, k := range keys {
+			for _, node := range nodes {
+				if matchNode(node, k, value) {
+					matches = append(matches, node)
+				}
+			}
+			if len(matches) > 0 {
+				break
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			unresolved[id] = fmt.Errorf("node '%s' not found in inventory by %s", id, describeKeys(keys))
+		case 1:
+			resolved = append(resolved, ResolvedNode{ID: id, Node: matches[0]})
+		default:
+			unresolved[id] = fmt.Errorf("node '%s' is ambiguous, it matches %d nodes in inventory", id, len(matches))
+		}
+	}
+	return resolved, unresolved
+}
+
+func matchNode(node bmc.Node, key NodeKey, value string) bool {
+	switch key {
+	case NodeKeyXname:
+		return node.ClusterID != "" && strings.EqualFold(node.ClusterID, value)
+	case NodeKeyUUID:
+		return node.UUID != "" && strings.EqualFold(node.UUID, value)
+	case NodeKeySerial:
+		return node.SerialNumber != "" && node.SerialNumber == value
+	case NodeKeyURI:
+		return node.SystemURI != "" && normalizeURI(node.SystemURI) == normalizeURI(value)
+	}
+	return false
+}
+
+// normalizeURI strips the scheme and trailing slashes from a URI, so that
+// 'https://bmc/redfish/v1/Systems/1/' and 'bmc/redfish/v1/Systems/1' match.
+func normalizeURI(uri string) string {
+	if _, rest, found := strings.Cut(uri, "://"); found {
+		uri = rest
+	}
+	return strings.TrimRight(uri, "/")
+}
+
+func describeKeys(keys []NodeKey) string {
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, string(k))
+	}
+	return strings.Join(names, ", ")
+}
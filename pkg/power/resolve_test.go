@@ -0,0 +1,45 @@
+package power
+
+import (
+	"testing"
+
+	"github.com/OpenCHAMI/magellan/pkg/bmc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveNodes(t *testing.T) {
+	t.Parallel()
+
+	nodes := []bmc.Node{
+		{ClusterID: "x1000c0s0b0n0", BmcIP: "172.16.0.100", NodeID: "Node0", UUID: "4C4C4544-0042-3510-8052-B4C04F4D3232", SerialNumber: "SN0", SystemURI: "https://172.16.0.100/redfish/v1/Systems/Node0"},
+		{ClusterID: "x1000c0s0b0n1", BmcIP: "172.16.0.100", NodeID: "Node1", UUID: "4c4c4544-0042-3510-8052-b4c04f4d3233", SerialNumber: "SN1", SystemURI: "https://172.16.0.100/redfish/v1/Systems/Node1"},
+		{ClusterID: "x1000c0s1b0n0", BmcIP: "172.16.0.101", NodeID: "1", SerialNumber: "SN1"},
+	}
+
+	resolved, unresolved := ResolveNodes(nodes, []string{
+		"x1000c0s0b0n0",
+		"4c4c4544-0042-3510-8052-b4c04f4d3232",
+		"172.16.0.100/redfish/v1/Systems/Node1/",
+		"compute-3",
+		"SN1",
+		"x9999c0s0b0n0",
+	}, NodeKeyAuto, map[string]string{"compute-3": "x1000c0s1b0n0"})
+
+	require.Len(t, resolved, 4)
+	require.Equal(t, "Node0", resolved[0].Node.NodeID)
+	require.Equal(t, "Node0", resolved[1].Node.NodeID)
+	require.Equal(t, "Node1", resolved[2].Node.NodeID)
+	require.Equal(t, "compute-3", resolved[3].ID)
+	require.Equal(t, "172.16.0.101", resolved[3].Node.BmcIP)
+
+	// duplicate serial numbers and unknown nodes are reported, not guessed
+	require.Len(t, unresolved, 2)
+	require.ErrorContains(t, unresolved["SN1"], "ambiguous")
+	require.ErrorContains(t, unresolved["x9999c0s0b0n0"], "not found")
+
+	// an explicit key does not fall back to the others
+	resolved, unresolved = ResolveNodes(nodes, []string{"x1000c0s0b0n0", "SN0"}, NodeKeySerial, nil)
+	require.Len(t, resolved, 1)
+	require.Equal(t, "SN0", resolved[0].ID)
+	require.Contains(t, unresolved, "x1000c0s0b0n0")
+}
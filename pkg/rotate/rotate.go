@@ -0,0 +1,322 @@
+package rotate
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/pkg/bmc"
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/rs/zerolog/log"
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/schemas"
+)
+
+// PendingPrefix starts the secret IDs that keep a new password that was set
+// on a BMC but could neither be verified nor rolled back, so that it isn't
+// lost, e.g. 'rotate-pending:https://172.16.0.101'.
+const PendingPrefix = "rotate-pending:"
+
+// The character classes of generated passwords. The symbols leave out
+// quotes, backslashes and other characters that BMCs or shells commonly
+// reject or mangle.
+const (
+	lowerChars  = "abcdefghijkmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	digitChars  = "23456789"
+	symbolChars = "!#%+-.:=@_"
+)
+
+// MinPasswordLength is the shortest password GeneratePassword creates.
+const MinPasswordLength = 8
+
+// Status is the outcome of rotating the password of a single BMC.
+type Status string
+
+const (
+	// StatusRotated means the new password was set, verified and stored.
+	StatusRotated Status = "rotated"
+	// StatusVerified means the account was found and would be rotated
+	// (dry run).
+	StatusVerified Status = "verified"
+	// StatusFailed means the password was not changed.
+	StatusFailed Status = "failed"
+	// StatusRolledBack means the new password was set but could not be
+	// verified or stored, and the old password was restored.
+	StatusRolledBack Status = "rolled-back"
+	// StatusRollbackFailed means the new password was set but could not
+	// be verified or stored, and the old password could not be restored.
+	// The new password is kept in the secret store under PendingSecretID.
+	StatusRollbackFailed Status = "rollback-failed"
+)
+
+// AuditEntry records the rotation of the password of a single BMC. It never
+// holds a password.
+type AuditEntry struct {
+	Host            string    `json:"host" yaml:"host"`                                                 // URI of the BMC
+	Username        string    `json:"username,omitempty" yaml:"username,omitempty"`                     // Username of the rotated account
+	Account         string    `json:"account,omitempty" yaml:"account,omitempty"`                       // URI of the rotated ManagerAccount
+	OldSecretID     string    `json:"old_secret_id,omitempty" yaml:"old_secret_id,omitempty"`           // Secret ID the old password was read from
+	NewSecretID     string    `json:"new_secret_id,omitempty" yaml:"new_secret_id,omitempty"`           // Secret ID the new password was stored under
+	UpdatedIDs      []string  `json:"updated_secret_ids,omitempty" yaml:"updated_secret_ids,omitempty"` // Other secret IDs of the BMC that were given the new password
+	PendingSecretID string    `json:"pending_secret_id,omitempty" yaml:"pending_secret_id,omitempty"`   // Secret ID holding a password that could not be rolled back
+	Status          Status    `json:"status" yaml:"status"`                                             // Outcome of the rotation
+	Error           string    `json:"error,omitempty" yaml:"error,omitempty"`                           // Why the rotation failed or was rolled back
+	Started         time.Time `json:"started" yaml:"started"`                                           // Time the rotation of the BMC started
+	Finished        time.Time `json:"finished" yaml:"finished"`                                         // Time the rotation of the BMC finished
+}
+
+// RotateParams are the parameters used to rotate BMC passwords.
+type RotateParams struct {
+	Store          secrets.SecretStore // Store to read the current and write the new credentials
+	Insecure       bool                // Whether to skip TLS verification
+	PasswordLength int                 // Length of the generated passwords
+	DryRun         bool                // Only check that the account of each BMC can be found
+}
+
+// GeneratePassword returns a random password of the given length with at
+// least one lowercase letter, uppercase letter, digit and symbol.
+//
+// Parameters:
+//   - length: The length of the password, at least MinPasswordLength.
+//
+// Returns:
+//   - string: The generated password.
+//   - error: An error if the length is too short or no randomness is available.
+func GeneratePassword(length int) (string, error) {
+	if length < MinPasswordLength {
+		return "", fmt.Errorf("password length must be at least %d", MinPasswordLength)
+	}
+	classes := []string{lowerChars, upperChars, digitChars, symbolChars}
+	all := strings.Join(classes, "")
+	password := make([]byte, length)
+	for i := range password {
+		chars := all
+		if i < len(classes) {
+			chars = classes[i]
+		}
+		c, err := randomChar(chars)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+	// don't leave the guaranteed classes at the start
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+	return string(password), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	if err != nil {
+		return 0, err
+	}
+	return chars[n.Int64()], nil
+}
+
+// connect logs in to a BMC with basic auth and checks that the credentials
+// work by reading a resource that requires them.
+func connect(host, uri string, creds bmc.BMCCredentials, insecure bool) (*gofish.APIClient, error) {
+	client, err := gofish.Connect(gofish.ClientConfig{
+		Endpoint:  host,
+		Username:  creds.Username,
+		Password:  creds.Password,
+		Insecure:  insecure,
+		BasicAuth: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	return client, resp.Body.Close()
+}
+
+// findAccount returns the ManagerAccount with the given username.
+func findAccount(client *gofish.APIClient, username string) (*schemas.ManagerAccount, error) {
+	accountService, err := client.Service.AccountService()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account service: %w", err)
+	}
+	accounts, err := accountService.Accounts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	for _, account := range accounts {
+		if account.UserName == username {
+			return account, nil
+		}
+	}
+	return nil, fmt.Errorf("no account with username '%s'", username)
+}
+
+// setPassword changes the password of an account through the client.
+func setPassword(client *gofish.APIClient, accountURI, password string) error {
+	account, err := schemas.GetManagerAccount(client, accountURI)
+	if err != nil {
+		return fmt.Errorf("failed to get account: %w", err)
+	}
+	account.Password = password
+	return account.Update()
+}
+
+// RotateBMC rotates the password of the account that is used to log in to
+// a BMC. The new password is only stored after logging in with it
+// succeeded, under the hostname or IP address of the BMC so that it is
+// found for any scheme or port, and under every URI of the BMC that already
+// has a secret. If that fails, or the new password can't be
+// stored, the old password is restored.
+//
+// Parameters:
+//   - host: The URI of the BMC, e.g. 'https://172.16.0.101'.
+//   - params: The secret store and how to connect and generate passwords.
+//
+// Returns:
+//   - AuditEntry: The outcome of the rotation.
+func RotateBMC(host string, params *RotateParams) AuditEntry {
+	entry := AuditEntry{Host: host, Started: time.Now().UTC()}
+	finish := func(status Status, err error) AuditEntry {
+		entry.Status = status
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		entry.Finished = time.Now().UTC()
+		return entry
+	}
+
+	// log in with the current credentials, trying each candidate
+	config := crawler.CrawlerConfig{URI: host, Insecure: params.Insecure, CredentialStore: params.Store}
+	client, err := crawler.GetBMCClient(config)
+	if err != nil {
+		return finish(StatusFailed, err)
+	}
+	oldCreds, err := config.GetUserPass()
+	if err != nil {
+		return finish(StatusFailed, err)
+	}
+	entry.Username = oldCreds.Username
+	if match, err := bmc.MatchSecretID(params.Store, host, func() string { return crawler.GetBMCVendor(config) }); err == nil {
+		entry.OldSecretID = match.SecretID
+	}
+
+	account, err := findAccount(client, oldCreds.Username)
+	if err != nil {
+		return finish(StatusFailed, err)
+	}
+	entry.Account = account.ODataID
+	if params.DryRun {
+		return finish(StatusVerified, nil)
+	}
+
+	newCreds := bmc.BMCCredentials{Username: oldCreds.Username}
+	if newCreds.Password, err = GeneratePassword(params.PasswordLength); err != nil {
+		return finish(StatusFailed, err)
+	}
+	if err = setPassword(client, account.ODataID, newCreds.Password); err != nil {
+		return finish(StatusFailed, fmt.Errorf("failed to set new password: %w", err))
+	}
+
+	// only store the new password once it is known to work
+	newClient, err := connect(host, account.ODataID, newCreds, params.Insecure)
+	if err != nil {
+		err = fmt.Errorf("failed to log in with new password: %w", err)
+		return rollback(&entry, finish, params, oldCreds, newCreds, nil, err)
+	}
+	secretID := bmc.HostFromURI(host)
+	if strings.EqualFold(entry.OldSecretID, secretID) {
+		// keep the case of an existing host secret ID
+		secretID = entry.OldSecretID
+	}
+	secret, err := json.Marshal(newCreds)
+	if err == nil {
+		err = params.Store.StoreSecretByID(secretID, string(secret))
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to store new password: %w", err)
+		return rollback(&entry, finish, params, oldCreds, newCreds, newClient, err)
+	}
+	entry.NewSecretID = secretID
+	entry.UpdatedIDs = updateURISecrets(params.Store, secretID, string(secret))
+	return finish(StatusRotated, nil)
+}
+
+// updateURISecrets gives the new secret to every secret ID that is a URI of
+// the BMC with the given host, e.g. 'https://172.16.0.101:443', since those
+// are matched before the host itself when looking up that URI.
+func updateURISecrets(store secrets.SecretStore, host, secret string) []string {
+	secretIDs, err := store.ListSecrets()
+	if err != nil {
+		log.Warn().Err(err).Str("host", host).Msg("failed to list secrets to update with the new password")
+		return nil
+	}
+	var updated []string
+	for secretID := range secretIDs {
+		if !strings.Contains(secretID, "://") || bmc.HostFromURI(secretID) != host {
+			continue
+		}
+		if err := store.StoreSecretByID(secretID, secret); err != nil {
+			log.Warn().Err(err).Str("host", host).Str("secret ID", secretID).Msg("failed to update secret with the new password")
+			continue
+		}
+		updated = append(updated, secretID)
+	}
+	sort.Strings(updated)
+	return updated
+}
+
+// rollback restores the old password of an account after the new password
+// was set but could not be verified or stored. The new password is kept in
+// the secret store under a pending secret ID if that fails too.
+func rollback(entry *AuditEntry, finish func(Status, error) AuditEntry, params *RotateParams, oldCreds, newCreds bmc.BMCCredentials, newClient *gofish.APIClient, cause error) AuditEntry {
+	host := entry.Host
+	log.Warn().Err(cause).Str("host", host).Msg("rolling back password rotation")
+
+	// the BMC may not have applied the new password at all
+	if _, err := connect(host, entry.Account, oldCreds, params.Insecure); err == nil {
+		return finish(StatusRolledBack, cause)
+	}
+
+	var err error
+	if newClient == nil {
+		newClient, err = gofish.Connect(gofish.ClientConfig{
+			Endpoint:  host,
+			Username:  newCreds.Username,
+			Password:  newCreds.Password,
+			Insecure:  params.Insecure,
+			BasicAuth: true,
+		})
+	}
+	if err == nil {
+		err = setPassword(newClient, entry.Account, oldCreds.Password)
+	}
+	if err == nil {
+		_, err = connect(host, entry.Account, oldCreds, params.Insecure)
+	}
+	if err == nil {
+		return finish(StatusRolledBack, cause)
+	}
+
+	log.Error().Err(err).Str("host", host).Msg("failed to roll back password rotation")
+	pending := PendingPrefix + host
+	if secret, jsonErr := json.Marshal(newCreds); jsonErr == nil {
+		if storeErr := params.Store.StoreSecretByID(pending, string(secret)); storeErr == nil {
+			entry.PendingSecretID = pending
+		} else {
+			log.Error().Err(storeErr).Str("host", host).Msg("failed to keep the new password in the secret store")
+		}
+	}
+	return finish(StatusRollbackFailed, fmt.Errorf("%w, and failed to roll back: %w", cause, err))
+}
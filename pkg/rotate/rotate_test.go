@@ -0,0 +1,189 @@
+package rotate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/OpenCHAMI/magellan/pkg/bmc"
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBMC is a BMC with a single 'admin' account whose password can be
+// changed through the AccountService.
+type fakeBMC struct {
+	mu       sync.Mutex
+	password string
+	ignore   bool // accept password changes without applying them
+	lockOut  bool // reject every login once the password was changed
+	changed  bool
+}
+
+func (b *fakeBMC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/redfish/v1/" {
+		_, _ = w.Write([]byte(`{
+			"@odata.id": "/redfish/v1/",
+			"Id": "RootService",
+			"AccountService": {"@odata.id": "/redfish/v1/AccountService"},
+			"Managers": {"@odata.id": "/redfish/v1/Managers"}
+		}`))
+		return
+	}
+	if u, p, ok := r.BasicAuth(); !ok || u != "admin" || p != b.password || (b.lockOut && b.changed) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/redfish/v1/Managers":
+		_, _ = w.Write([]byte(`{"Members": []}`))
+	case "/redfish/v1/AccountService":
+		_, _ = w.Write([]byte(`{
+			"@odata.id": "/redfish/v1/AccountService",
+			"Accounts": {"@odata.id": "/redfish/v1/AccountService/Accounts"}
+		}`))
+	case "/redfish/v1/AccountService/Accounts":
+		_, _ = w.Write([]byte(`{"Members": [{"@odata.id": "/redfish/v1/AccountService/Accounts/2"}]}`))
+	case "/redfish/v1/AccountService/Accounts/2":
+		if r.Method == http.MethodPatch {
+			var body struct{ Password string }
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if !b.ignore {
+				b.password = body.Password
+				b.changed = true
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_, _ = w.Write([]byte(`{
+			"@odata.id": "/redfish/v1/AccountService/Accounts/2",
+			"Id": "2",
+			"UserName": "admin",
+			"Enabled": true
+		}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newStore(t *testing.T) secrets.SecretStore {
+	masterKey, err := secrets.GenerateMasterKey()
+	require.NoError(t, err)
+	store, err := secrets.NewLocalSecretStore(masterKey, filepath.Join(t.TempDir(), "secrets.json"), true)
+	require.NoError(t, err)
+	require.NoError(t, store.StoreSecretByID(secrets.DEFAULT_KEY, `{"username": "admin", "password": "initial"}`))
+	return store
+}
+
+func TestGeneratePassword(t *testing.T) {
+	t.Parallel()
+
+	password, err := GeneratePassword(16)
+	require.NoError(t, err)
+	require.Len(t, password, 16)
+	for _, chars := range []string{lowerChars, upperChars, digitChars, symbolChars} {
+		require.True(t, strings.ContainsAny(password, chars), "%s has no character of %s", password, chars)
+	}
+	other, err := GeneratePassword(16)
+	require.NoError(t, err)
+	require.NotEqual(t, password, other)
+
+	_, err = GeneratePassword(MinPasswordLength - 1)
+	require.Error(t, err)
+}
+
+func TestRotateBMC(t *testing.T) {
+	t.Parallel()
+
+	store := newStore(t)
+	fake := &fakeBMC{password: "initial"}
+	server := httptest.NewTLSServer(fake)
+	defer server.Close()
+
+	entry := RotateBMC(server.URL, &RotateParams{Store: store, Insecure: true, PasswordLength: 16})
+	require.Equal(t, StatusRotated, entry.Status, entry.Error)
+	require.Equal(t, "admin", entry.Username)
+	require.Equal(t, "/redfish/v1/AccountService/Accounts/2", entry.Account)
+	require.Equal(t, secrets.DEFAULT_KEY, entry.OldSecretID)
+	require.Equal(t, "127.0.0.1", entry.NewSecretID)
+
+	// the new password is set on the BMC and stored under its host only
+	creds, err := bmc.GetBMCCredentials(store, entry.NewSecretID)
+	require.NoError(t, err)
+	require.Equal(t, fake.password, creds.Password)
+	require.NotEqual(t, "initial", creds.Password)
+	creds, err = bmc.GetBMCCredentialsDefault(store)
+	require.NoError(t, err)
+	require.Equal(t, "initial", creds.Password)
+
+	// a dry run doesn't change anything
+	before := fake.password
+	entry = RotateBMC(server.URL, &RotateParams{Store: store, Insecure: true, PasswordLength: 16, DryRun: true})
+	require.Equal(t, StatusVerified, entry.Status, entry.Error)
+	require.Equal(t, before, fake.password)
+}
+
+func TestRotateBMCCollectURI(t *testing.T) {
+	t.Parallel()
+
+	// a stale secret under the URI of the BMC is tried before the default
+	store := newStore(t)
+	fake := &fakeBMC{password: "initial"}
+	server := httptest.NewTLSServer(fake)
+	defer server.Close()
+	require.NoError(t, store.StoreSecretByID(server.URL, `{"candidates": [{"username": "admin", "password": "stale"}, {"username": "admin", "password": "initial"}]}`))
+
+	entry := RotateBMC(server.URL, &RotateParams{Store: store, Insecure: true, PasswordLength: 16})
+	require.Equal(t, StatusRotated, entry.Status, entry.Error)
+	require.Equal(t, []string{server.URL}, entry.UpdatedIDs)
+
+	// the BMC is logged in to with the new password under its URI...
+	client, err := crawler.GetBMCClient(crawler.CrawlerConfig{URI: server.URL, Insecure: true, CredentialStore: store})
+	require.NoError(t, err)
+	resp, err := client.Get("/redfish/v1/Managers")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	// ...and under the URI 'collect' uses for it, with the default port
+	config := crawler.CrawlerConfig{URI: "https://127.0.0.1:443", CredentialStore: store}
+	creds, err := config.GetUserPass()
+	require.NoError(t, err)
+	require.Equal(t, fake.password, creds.Password)
+}
+
+func TestRotateBMCRollback(t *testing.T) {
+	t.Parallel()
+
+	// the BMC doesn't apply the new password, so the old one still works
+	store := newStore(t)
+	server := httptest.NewTLSServer(&fakeBMC{password: "initial", ignore: true})
+	defer server.Close()
+
+	entry := RotateBMC(server.URL, &RotateParams{Store: store, Insecure: true, PasswordLength: 16})
+	require.Equal(t, StatusRolledBack, entry.Status)
+	require.Contains(t, entry.Error, "failed to log in with new password")
+	require.Empty(t, entry.NewSecretID)
+	_, err := store.GetSecretByID(server.URL)
+	require.Error(t, err, "the secret store must not be updated")
+
+	// the BMC applies the new password but then rejects every login, so
+	// the new password is kept under a pending secret ID
+	server = httptest.NewTLSServer(&fakeBMC{password: "initial", lockOut: true})
+	defer server.Close()
+
+	entry = RotateBMC(server.URL, &RotateParams{Store: store, Insecure: true, PasswordLength: 16})
+	require.Equal(t, StatusRollbackFailed, entry.Status)
+	require.Equal(t, PendingPrefix+server.URL, entry.PendingSecretID)
+	_, err = store.GetSecretByID(server.URL)
+	require.Error(t, err, "the secret store must not be updated")
+	_, err = store.GetSecretByID(entry.PendingSecretID)
+	require.NoError(t, err)
+}
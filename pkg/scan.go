@@ -3,16 +3,19 @@ package magellan
 import (
 	"crypto/tls"
 	"fmt"
+	"io"
 	"math"
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
 
 	urlx "github.com/OpenCHAMI/magellan/internal/url"
 	"github.com/OpenCHAMI/magellan/pkg/client"
+	"github.com/OpenCHAMI/magellan/pkg/crawler"
 	"github.com/rs/zerolog/log"
 )
 
@@ -45,6 +48,26 @@ func (ra *RemoteAsset) String() string {
 	)
 }
 
+// probe is an HTTP request used to identify the service type of a scanned host.
+type probe struct {
+	Type   string
+	Path   string
+	Accept func(body []byte) bool // optional check of the response body
+}
+
+// accepts checks the body of a successful probe response, if required.
+func (p probe) accepts(res *http.Response) bool {
+	if p.Accept == nil {
+		return true
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		log.Debug().Err(err).Str("type", p.Type).Msg("failed to read probe response")
+		return false
+	}
+	return p.Accept(body)
+}
+
 // ScanParams is a collection of commom parameters passed to the CLI
 type ScanParams struct {
 	TargetHosts    [][]string
@@ -67,7 +90,8 @@ type ScanParams struct {
 // to be made concurrently.
 //
 // If the "disableProbing" flag is set, then the function will skip the extra
-// HTTP request made to check if the response was from a Redfish or JAWS service.
+// HTTP request made to check if the response was from a Redfish, Redfish PDU
+// or JAWS service.
 // Otherwise, not receiving a 200 OK response code from the HTTP request will
 // remove the service from being stored in the list of scanned results.
 //
@@ -85,16 +109,17 @@ func ScanForAssets(params *ScanParams) []RemoteAsset {
 
 	log.Trace().Any("hosts", params.TargetHosts).Msg("starting scan...")
 
-	probesToRun := []struct {
-		Type, Path string
-	}{}
-	for _, item := range params.Include {
-		if item == "bmcs" {
-			probesToRun = append(probesToRun, struct{ Type, Path string }{Type: "Redfish", Path: "/redfish/v1/"})
-		}
-		if item == "pdus" {
-			probesToRun = append(probesToRun, struct{ Type, Path string }{Type: "JAWS", Path: "/jaws/monitor/outlets"})
-		}
+	// PDUs are probed first, since a Redfish PDU would also pass the probe for
+	// a Redfish BMC
+	probesToRun := []probe{}
+	if slices.Contains(params.Include, PDU.String()) {
+		probesToRun = append(probesToRun,
+			probe{Type: "JAWS", Path: "/jaws/monitor/outlets"},
+			probe{Type: "RedfishPDU", Path: "/redfish/v1/", Accept: crawler.HasPowerEquipment},
+		)
+	}
+	if slices.Contains(params.Include, BMC.String()) {
+		probesToRun = append(probesToRun, probe{Type: "Redfish", Path: "/redfish/v1/"})
 	}
 
 	transport := &http.Transport{
@@ -137,7 +162,7 @@ func ScanForAssets(params *ScanParams) []RemoteAsset {
 								}
 
 								res, err := probeClient.Do(req)
-								if err == nil && res != nil && res.StatusCode == http.StatusOK {
+								if err == nil && res != nil && res.StatusCode == http.StatusOK && probe.accepts(res) {
 									if err := res.Body.Close(); err != nil {
 										log.Warn().
 											Err(err).
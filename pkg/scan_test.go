@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/OpenCHAMI/magellan/pkg/test"
@@ -142,6 +143,48 @@ func TestScan(t *testing.T) {
 	}
 }
 
+func TestScanRedfishPDU(t *testing.T) {
+	t.Parallel()
+
+	serveRoot := func(body string) *httptest.Server {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/redfish/v1/", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		})
+		return httptest.NewServer(mux)
+	}
+	pduServer := serveRoot(`{"@odata.id": "/redfish/v1/", "PowerEquipment": {"@odata.id": "/redfish/v1/PowerEquipment"}}`)
+	defer pduServer.Close()
+	bmcServer := serveRoot(test.RESPONSE_ServiceRoot)
+	defer bmcServer.Close()
+
+	params := &ScanParams{
+		TargetHosts: [][]string{{pduServer.URL}, {bmcServer.URL}},
+		Scheme:      scheme,
+		Protocol:    protocol,
+		Concurrency: 1,
+		Timeout:     timeout,
+		Insecure:    true,
+		Include:     []string{"bmcs", "pdus"},
+	}
+
+	// a Redfish PDU is not mistaken for a BMC
+	found := ScanForAssets(params)
+	assert.Len(t, found, 2)
+	for _, asset := range found {
+		if strings.HasSuffix(pduServer.URL, ":"+strconv.Itoa(asset.Port)) {
+			assert.Equal(t, Scanner("RedfishPDU"), asset.ServiceType)
+		} else {
+			assert.Equal(t, Scanner("Redfish"), asset.ServiceType)
+		}
+	}
+
+	// and a BMC is not mistaken for a PDU
+	params.Include = []string{"pdus"}
+	found = ScanForAssets(params)
+	assert.Len(t, found, 1)
+}
+
 func TestGenerateHostsFromSubnet(t *testing.T) {
 	t.Parallel()
 
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/rs/zerolog/log"
@@ -121,16 +122,24 @@ func (l *LocalSecretStore) RemoveSecretByID(secretID string) error {
 	return nil
 }
 
-// openStore tries to create or open the LocalSecretStore based on the environment
-// variable MASTER_KEY. If not found, it prints an error.
+// openStore tries to create or open the LocalSecretStore with the master key
+// from LoadMasterKey. If not found, it prints an error. Paths starting with
+// 'vault://' open a VaultStore with DefaultVaultConfig instead.
 func OpenStore(filename string) (SecretStore, error) {
 	if filename == "" {
 		return nil, fmt.Errorf("path to secret store required")
 	}
+	if IsVaultURI(filename) {
+		store, err := NewVaultStore(filename, DefaultVaultConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open Vault secret store: %v", err)
+		}
+		return store, nil
+	}
 
-	masterKey := os.Getenv("MASTER_KEY")
-	if masterKey == "" {
-		return nil, fmt.Errorf("MASTER_KEY environment variable not set")
+	masterKey, err := LoadMasterKey()
+	if err != nil {
+		return nil, err
 	}
 
 	store, err := NewLocalSecretStore(masterKey, filename, true)
@@ -140,6 +149,81 @@ func OpenStore(filename string) (SecretStore, error) {
 	return store, nil
 }
 
+// Rekey decrypts every secret with the current master key and encrypts it
+// again with a new one. The secrets file is first copied to backupPath, then
+// replaced atomically, so that it holds either the old or the new secrets if
+// anything fails. Nothing is changed if any secret can't be decrypted.
+//
+// Parameters:
+//   - newMasterKeyHex: The new master key in hex.
+//   - backupPath: The path to copy the secrets file to before replacing it.
+//
+// Returns:
+//   - error: An error if a secret can't be decrypted or the file can't be replaced.
+func (l *LocalSecretStore) Rekey(newMasterKeyHex, backupPath string) error {
+	newMasterKey, err := hex.DecodeString(newMasterKeyHex)
+	if err != nil {
+		return fmt.Errorf("failed to decode new master key from hex representation: %v", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rekeyed := make(map[string]string, len(l.Secrets))
+	for secretID, encrypted := range l.Secrets {
+		secret, err := decryptAESGCM(deriveAESKey(l.masterKey, secretID), encrypted)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt secret %s with the current master key: %v", secretID, err)
+		}
+		if rekeyed[secretID], err = encryptAESGCM(deriveAESKey(newMasterKey, secretID), []byte(secret)); err != nil {
+			return fmt.Errorf("failed to encrypt secret %s with the new master key: %v", secretID, err)
+		}
+	}
+
+	original, err := os.ReadFile(l.filename)
+	if err != nil {
+		return fmt.Errorf("failed to read secrets file: %v", err)
+	}
+	if err = os.WriteFile(backupPath, original, 0600); err != nil {
+		return fmt.Errorf("failed to write backup of secrets file: %v", err)
+	}
+
+	// write to a temporary file next to the secrets file and rename it, so
+	// that the secrets file is never partially written
+	tmp, err := os.CreateTemp(filepath.Dir(l.filename), filepath.Base(l.filename)+".rekey-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary secrets file: %v", err)
+	}
+	defer func() {
+		if err := os.Remove(tmp.Name()); err != nil && !os.IsNotExist(err) {
+			log.Warn().Err(err).Str("path", tmp.Name()).Msg("could not remove temporary secrets file")
+		}
+	}()
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(rekeyed); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write temporary secrets file: %v", err)
+	}
+	if info, err := os.Stat(l.filename); err == nil {
+		if err = os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+			log.Warn().Err(err).Str("path", tmp.Name()).Msg("could not keep the permissions of the secrets file")
+		}
+	}
+	if err = os.Rename(tmp.Name(), l.filename); err != nil {
+		return fmt.Errorf("failed to replace secrets file: %v", err)
+	}
+
+	l.masterKey = newMasterKey
+	l.Secrets = rekeyed
+	return nil
+}
+
 // Saves secrets back to the JSON file
 func SaveSecrets(jsonFile string, store map[string]string) error {
 	file, err := os.OpenFile(jsonFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
@@ -3,6 +3,7 @@ package secrets
 import (
 	"encoding/hex"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/rs/zerolog/log"
@@ -170,3 +171,81 @@ func TestListSecrets(t *testing.T) {
 		log.Warn().Err(err).Msg("could not close response resource")
 	}
 }
+
+func TestRekey(t *testing.T) {
+	oldKey, err := GenerateMasterKey()
+	if err != nil {
+		t.Fatalf("Failed to generate master key: %v", err)
+	}
+	newKey, err := GenerateMasterKey()
+	if err != nil {
+		t.Fatalf("Failed to generate master key: %v", err)
+	}
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "secrets.json")
+	backup := filepath.Join(dir, "secrets.json.bak")
+
+	store, err := NewLocalSecretStore(oldKey, filename, true)
+	if err != nil {
+		t.Fatalf("Failed to create LocalSecretStore: %v", err)
+	}
+	if err = store.StoreSecretByID("test_secret", "my_secret_value"); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+	original, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read secrets file: %v", err)
+	}
+
+	if err = store.Rekey(newKey, backup); err != nil {
+		t.Fatalf("Failed to rekey secrets: %v", err)
+	}
+
+	// the backup holds the secrets encrypted with the old key
+	saved, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if string(saved) != string(original) {
+		t.Errorf("Expected backup to match the original secrets file")
+	}
+
+	// the secrets file only opens with the new key
+	oldStore, err := NewLocalSecretStore(oldKey, filename, false)
+	if err != nil {
+		t.Fatalf("Failed to open rekeyed secrets file: %v", err)
+	}
+	if _, err = oldStore.GetSecretByID("test_secret"); err == nil {
+		t.Errorf("Expected the old master key to no longer decrypt the secrets")
+	}
+	newStore, err := NewLocalSecretStore(newKey, filename, false)
+	if err != nil {
+		t.Fatalf("Failed to open rekeyed secrets file: %v", err)
+	}
+	if secret, err := newStore.GetSecretByID("test_secret"); err != nil || secret != "my_secret_value" {
+		t.Errorf("Expected secret value my_secret_value, got %s (%v)", secret, err)
+	}
+	if secret, err := store.GetSecretByID("test_secret"); err != nil || secret != "my_secret_value" {
+		t.Errorf("Expected rekeyed store to keep working, got %s (%v)", secret, err)
+	}
+
+	// rekeying with the wrong current key leaves the file untouched
+	rekeyed, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read secrets file: %v", err)
+	}
+	if err = oldStore.Rekey(newKey, filepath.Join(dir, "other.bak")); err == nil {
+		t.Errorf("Expected rekeying with the wrong master key to fail")
+	}
+	after, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read secrets file: %v", err)
+	}
+	if string(after) != string(rekeyed) {
+		t.Errorf("Expected secrets file to be left unchanged after a failed rekey")
+	}
+	if _, err = os.Stat(filepath.Join(dir, "other.bak")); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup to be written after a failed rekey")
+	}
+}
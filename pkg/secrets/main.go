@@ -2,9 +2,16 @@ package secrets
 
 const DEFAULT_KEY = "default"
 
+// RedactedSecret is listed by ListSecrets in place of the secrets of stores
+// that would otherwise return them in plaintext.
+const RedactedSecret = "[redacted]"
+
 type SecretStore interface {
 	GetSecretByID(secretID string) (string, error)
 	StoreSecretByID(secretID, secret string) error
+	// ListSecrets returns every secret ID with an opaque value, i.e. the
+	// encrypted secret or RedactedSecret, never the secret in plaintext.
+	// Use GetSecretByID to read a secret.
 	ListSecrets() (map[string]string, error)
 	RemoveSecretByID(secretID string) error
 }
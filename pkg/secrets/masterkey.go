@@ -0,0 +1,88 @@
+package secrets
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MasterKeySource is where the master key of a LocalSecretStore comes from.
+// The first of Key, Command and File that is set is used.
+type MasterKeySource struct {
+	Key     string // the master key in hex
+	Command string // a shell command printing the master key, e.g. 'pass show magellan/master-key'
+	File    string // a file holding the master key
+}
+
+// DefaultMasterKeySource is where OpenStore gets the master key from. The
+// MASTER_KEY environment variable is used if it sets neither Command nor
+// File.
+var DefaultMasterKeySource = MasterKeySource{}
+
+// Load returns the master key from the source, in hex.
+//
+// Returns:
+//   - string: The master key in hex.
+//   - error: An error if the source sets nothing, can't be read, or doesn't hold a hex key.
+func (source MasterKeySource) Load() (string, error) {
+	var (
+		key  string
+		from string
+	)
+	switch {
+	case source.Key != "":
+		key, from = source.Key, "key"
+	case source.Command != "":
+		// let commands prompt for a passphrase on the terminal
+		cmd := exec.Command("sh", "-c", source.Command)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to run master key command: %w", err)
+		}
+		key, from = string(output), "master key command"
+	case source.File != "":
+		info, err := os.Stat(source.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read master key file: %w", err)
+		}
+		if info.Mode().Perm()&0o077 != 0 {
+			log.Warn().Str("path", source.File).Msgf("master key file is accessible by other users (mode %s)", info.Mode().Perm())
+		}
+		data, err := os.ReadFile(source.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read master key file: %w", err)
+		}
+		key, from = string(data), "master key file "+source.File
+	default:
+		return "", fmt.Errorf("no master key set")
+	}
+
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("%s is empty", from)
+	}
+	if _, err := hex.DecodeString(key); err != nil {
+		return "", fmt.Errorf("%s is not a hex master key: %w", from, err)
+	}
+	return key, nil
+}
+
+// LoadMasterKey returns the master key from DefaultMasterKeySource, or from
+// the MASTER_KEY environment variable if it sets neither a command nor a
+// file.
+func LoadMasterKey() (string, error) {
+	source := DefaultMasterKeySource
+	if source.Command == "" && source.File == "" {
+		source.Key = os.Getenv("MASTER_KEY")
+		if source.Key == "" {
+			return "", fmt.Errorf("MASTER_KEY environment variable not set, and neither a master key file nor command were given")
+		}
+	}
+	return source.Load()
+}
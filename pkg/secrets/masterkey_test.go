@@ -0,0 +1,49 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMasterKeySourceLoad(t *testing.T) {
+	masterKey, err := GenerateMasterKey()
+	if err != nil {
+		t.Fatalf("Failed to generate master key: %v", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "master.key")
+	if err = os.WriteFile(keyFile, []byte(masterKey+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write master key file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		source  MasterKeySource
+		wantErr bool
+	}{
+		{name: "key", source: MasterKeySource{Key: masterKey}},
+		{name: "file", source: MasterKeySource{File: keyFile}},
+		{name: "command", source: MasterKeySource{Command: "cat " + keyFile}},
+		{name: "key before file", source: MasterKeySource{Key: masterKey, File: "does-not-exist"}},
+		{name: "nothing", source: MasterKeySource{}, wantErr: true},
+		{name: "missing file", source: MasterKeySource{File: "does-not-exist"}, wantErr: true},
+		{name: "failing command", source: MasterKeySource{Command: "exit 1"}, wantErr: true},
+		{name: "empty command", source: MasterKeySource{Command: "true"}, wantErr: true},
+		{name: "not hex", source: MasterKeySource{Key: "not-a-key"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		key, err := tt.source.Load()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got key %s", tt.name, key)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: failed to load master key: %v", tt.name, err)
+		} else if key != masterKey {
+			t.Errorf("%s: expected master key %s, got %s", tt.name, masterKey, key)
+		}
+	}
+}
@@ -25,7 +25,7 @@ func (s *StaticStore) StoreSecretByID(secretID, secret string) error {
 
 func (s *StaticStore) ListSecrets() (map[string]string, error) {
 	return map[string]string{
-		"static_creds": fmt.Sprintf(`{"username":"%s","password":"%s"}`, s.Username, s.Password),
+		"static_creds": RedactedSecret,
 	}, nil
 }
 
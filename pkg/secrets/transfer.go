@@ -0,0 +1,372 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/OpenCHAMI/magellan/internal/format"
+)
+
+// FORMAT_CSV is the format of secrets files in CSV, which DataFormat
+// doesn't cover. Secrets can also be imported and exported in JSON, YAML
+// and NDJSON.
+const FORMAT_CSV format.DataFormat = "csv"
+
+// The columns of a CSV secrets file. Other columns are ignored, so that a
+// spreadsheet can be exported as is.
+const (
+	csvColumnID       = "id"
+	csvColumnUsername = "username"
+	csvColumnPassword = "password"
+	csvColumnSecret   = "secret"
+)
+
+// Record is a secret in a file of secrets to import or export. Most secrets
+// are BMC credentials with a username and password, while any other secret,
+// e.g. SNMP credentials or candidate credentials, is kept as is in Secret.
+// Several records with the same ID are imported as candidate credentials to
+// try in order.
+type Record struct {
+	ID       string `json:"id" yaml:"id"`
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	Secret   string `json:"secret,omitempty" yaml:"secret,omitempty"`
+}
+
+// credentials is the secret of a Record with a username and password, with
+// the same fields as bmc.BMCCredentials.
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// FormatFromFileExt returns the format of a secrets file from its extension,
+// which is CSV for '.csv' and otherwise the same as format.DataFormatFromFileExt.
+func FormatFromFileExt(path string, defaultFmt format.DataFormat) format.DataFormat {
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		return FORMAT_CSV
+	}
+	return format.DataFormatFromFileExt(path, defaultFmt)
+}
+
+// ReadRecords reads the records of a secrets file in CSV, JSON, YAML or
+// NDJSON. CSV files must have a header row with an 'id' column, and either
+// 'username' and 'password' or 'secret' columns. Lines starting with '#'
+// are comments.
+//
+// Parameters:
+//   - input: The contents of the secrets file.
+//   - inFormat: The format of the secrets file.
+//
+// Returns:
+//   - []Record: The records in the order they appear in the file.
+//   - error: An error if the file can't be parsed.
+func ReadRecords(input []byte, inFormat format.DataFormat) ([]Record, error) {
+	if inFormat == FORMAT_CSV {
+		return readCSVRecords(input)
+	}
+	var records []Record
+	if err := format.UnmarshalData(input, &records, inFormat); err != nil {
+		return nil, fmt.Errorf("failed to read secrets: %w", err)
+	}
+	return records, nil
+}
+
+func readCSVRecords(input []byte) ([]Record, error) {
+	reader := csv.NewReader(bytes.NewReader(input))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	// spreadsheets leave out trailing empty columns
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header of CSV secrets: %w", err)
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if _, exists := columns[column]; !exists {
+			columns[column] = i
+		}
+	}
+	if _, ok := columns[csvColumnID]; !ok {
+		return nil, fmt.Errorf("line 1: CSV secrets have no '%s' column", csvColumnID)
+	}
+	_, hasUsername := columns[csvColumnUsername]
+	_, hasSecret := columns[csvColumnSecret]
+	if !hasUsername && !hasSecret {
+		return nil, fmt.Errorf("line 1: CSV secrets have no '%s' or '%s' column", csvColumnUsername, csvColumnSecret)
+	}
+	field := func(row []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// csv.ParseError already includes the line
+			return nil, fmt.Errorf("failed to read CSV secrets: %w", err)
+		}
+		record := Record{
+			ID:       strings.TrimSpace(field(row, csvColumnID)),
+			Username: strings.TrimSpace(field(row, csvColumnUsername)),
+			Password: field(row, csvColumnPassword), // may start or end with spaces
+			Secret:   strings.TrimSpace(field(row, csvColumnSecret)),
+		}
+		if record == (Record{}) {
+			// spreadsheets often export empty rows
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// WriteRecords writes records to a secrets file in CSV, JSON, YAML or NDJSON
+// that ReadRecords reads back. CSV files only have a 'secret' column if any
+// record needs it.
+//
+// Parameters:
+//   - records: The records to write.
+//   - outFormat: The format of the secrets file.
+//
+// Returns:
+//   - []byte: The contents of the secrets file.
+//   - error: An error if the records can't be written in the format.
+func WriteRecords(records []Record, outFormat format.DataFormat) ([]byte, error) {
+	if outFormat != FORMAT_CSV {
+		return format.MarshalData(records, outFormat)
+	}
+	header := []string{csvColumnID, csvColumnUsername, csvColumnPassword}
+	for _, record := range records {
+		if record.Secret != "" {
+			header = append(header, csvColumnSecret)
+			break
+		}
+	}
+	var (
+		buf    bytes.Buffer
+		writer = csv.NewWriter(&buf)
+	)
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		row := []string{record.ID, record.Username, record.Password, record.Secret}
+		if err := writer.Write(row[:len(header)]); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// RecordsToSecrets turns records into the secrets to store by ID. Records
+// with a username and password become BMC credentials, and several of them
+// with the same ID become candidate credentials to try in order.
+//
+// Parameters:
+//   - records: The records read from a secrets file.
+//
+// Returns:
+//   - map[string]string: The secrets by ID.
+//   - error: An error if a record has no ID, no username or mixes a secret with credentials.
+func RecordsToSecrets(records []Record) (map[string]string, error) {
+	var (
+		candidates = map[string][]credentials{}
+		secrets    = map[string]string{}
+	)
+	for i, record := range records {
+		switch {
+		case record.ID == "":
+			return nil, fmt.Errorf("record %d has no ID", i+1)
+		case record.Secret != "":
+			if record.Username != "" || record.Password != "" {
+				return nil, fmt.Errorf("record %d (%s) has both a secret and credentials", i+1, record.ID)
+			}
+			if _, exists := secrets[record.ID]; exists || len(candidates[record.ID]) > 0 {
+				return nil, fmt.Errorf("record %d (%s) has a secret but its ID is already used", i+1, record.ID)
+			}
+			secrets[record.ID] = record.Secret
+		case record.Username == "":
+			return nil, fmt.Errorf("record %d (%s) has no username", i+1, record.ID)
+		default:
+			if _, exists := secrets[record.ID]; exists {
+				return nil, fmt.Errorf("record %d (%s) has credentials but its ID already has a secret", i+1, record.ID)
+			}
+			candidates[record.ID] = append(candidates[record.ID], credentials{Username: record.Username, Password: record.Password})
+		}
+	}
+	for id, creds := range candidates {
+		var data any = creds[0]
+		if len(creds) > 1 {
+			data = map[string]any{"candidates": creds}
+		}
+		secret, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal credentials of %s: %w", id, err)
+		}
+		secrets[id] = string(secret)
+	}
+	return secrets, nil
+}
+
+// SecretsToRecords turns secrets into records sorted by ID. Secrets that are
+// just a username and password are split into those fields, and any other
+// secret is kept as is.
+func SecretsToRecords(secrets map[string]string) []Record {
+	records := make([]Record, 0, len(secrets))
+	for id, secret := range secrets {
+		record := Record{ID: id, Secret: secret}
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(secret), &fields); err == nil && len(fields) == 2 {
+			username, hasUsername := fields["username"].(string)
+			password, hasPassword := fields["password"].(string)
+			if hasUsername && hasPassword && username != "" {
+				record = Record{ID: id, Username: username, Password: password}
+			}
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+	return records
+}
+
+// ReadAllSecrets returns every secret of a store in plaintext. It works with
+// any SecretStore, as ListSecrets doesn't return plaintext for all of them.
+//
+// Parameters:
+//   - store: The store to read the secrets of.
+//
+// Returns:
+//   - map[string]string: The secrets by ID.
+//   - error: An error if the secrets can't be listed or any of them can't be read.
+func ReadAllSecrets(store SecretStore) (map[string]string, error) {
+	listed, err := store.ListSecrets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	secrets := make(map[string]string, len(listed))
+	for id := range listed {
+		if secrets[id], err = store.GetSecretByID(id); err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", id, err)
+		}
+	}
+	return secrets, nil
+}
+
+// StoreSecrets stores secrets in any SecretStore in the order of their IDs.
+// Existing secrets are left untouched unless overwrite is set.
+//
+// Parameters:
+//   - store: The store to store the secrets in.
+//   - secrets: The secrets by ID.
+//   - overwrite: Whether to replace secrets that already exist in the store.
+//
+// Returns:
+//   - []string: The IDs of the secrets that were stored.
+//   - []string: The IDs of the secrets that were skipped because they already exist.
+//   - error: An error if any secret can't be stored, after which the rest aren't either.
+func StoreSecrets(store SecretStore, secrets map[string]string, overwrite bool) ([]string, []string, error) {
+	ids := make([]string, 0, len(secrets))
+	for id := range secrets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	existing, err := store.ListSecrets()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	var stored, skipped []string
+	for _, id := range ids {
+		if _, exists := existing[id]; exists && !overwrite {
+			skipped = append(skipped, id)
+			continue
+		}
+		if err := store.StoreSecretByID(id, secrets[id]); err != nil {
+			return stored, skipped, fmt.Errorf("failed to store secret %s: %w", id, err)
+		}
+		stored = append(stored, id)
+	}
+	return stored, skipped, nil
+}
+
+// WriteBundle writes secrets to a new encrypted bundle. A bundle is a
+// secrets file like that of a LocalSecretStore, encrypted with its own key
+// instead of the master key, so it can also be used as a secrets file with
+// that key. The secret IDs are not encrypted.
+//
+// Parameters:
+//   - path: The path of the bundle, which must not exist yet.
+//   - bundleKeyHex: The key to encrypt the bundle with, in hex.
+//   - secrets: The secrets by ID.
+//
+// Returns:
+//   - error: An error if the bundle exists or can't be written.
+func WriteBundle(path, bundleKeyHex string, secrets map[string]string) error {
+	bundleKey, err := hex.DecodeString(bundleKeyHex)
+	if err != nil {
+		return fmt.Errorf("failed to decode bundle key from hex representation: %v", err)
+	}
+	encrypted := make(map[string]string, len(secrets))
+	for id, secret := range secrets {
+		if encrypted[id], err = encryptAESGCM(deriveAESKey(bundleKey, id), []byte(secret)); err != nil {
+			return fmt.Errorf("failed to encrypt secret %s: %v", id, err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %v", err)
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(encrypted)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write bundle: %v", err)
+	}
+	return nil
+}
+
+// ReadBundle reads the secrets of an encrypted bundle written by WriteBundle.
+//
+// Parameters:
+//   - path: The path of the bundle.
+//   - bundleKeyHex: The key the bundle was encrypted with, in hex.
+//
+// Returns:
+//   - map[string]string: The secrets by ID.
+//   - error: An error if the bundle can't be read or decrypted with the key.
+func ReadBundle(path, bundleKeyHex string) (map[string]string, error) {
+	bundle, err := NewLocalSecretStore(bundleKeyHex, path, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %v", err)
+	}
+	secrets, err := ReadAllSecrets(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt bundle, check the bundle key: %w", err)
+	}
+	return secrets, nil
+}
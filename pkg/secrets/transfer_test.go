@@ -0,0 +1,176 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/OpenCHAMI/magellan/internal/format"
+)
+
+func TestReadRecords(t *testing.T) {
+	csvInput := `id,username,password,notes
+# factory defaults are tried in order
+https://172.16.0.101,root,hunter2,rack 1
+172.16.1.0/24,admin," pa,ss"
+
+default,ADMIN,ADMIN
+default,root,calvin
+`
+	records, err := ReadRecords([]byte(csvInput), FORMAT_CSV)
+	if err != nil {
+		t.Fatalf("Failed to read CSV records: %v", err)
+	}
+	expected := []Record{
+		{ID: "https://172.16.0.101", Username: "root", Password: "hunter2"},
+		{ID: "172.16.1.0/24", Username: "admin", Password: " pa,ss"},
+		{ID: "default", Username: "ADMIN", Password: "ADMIN"},
+		{ID: "default", Username: "root", Password: "calvin"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected records %v, got %v", expected, records)
+	}
+
+	yamlInput := `
+- id: https://172.16.0.101
+  username: root
+  password: hunter2
+- id: snmp
+  secret: '{"community": "public"}'
+`
+	records, err = ReadRecords([]byte(yamlInput), format.FORMAT_YAML)
+	if err != nil {
+		t.Fatalf("Failed to read YAML records: %v", err)
+	}
+	if len(records) != 2 || records[1].Secret != `{"community": "public"}` {
+		t.Errorf("Expected 2 records with an SNMP secret, got %v", records)
+	}
+
+	if _, err = ReadRecords([]byte("host,username,password\n"), FORMAT_CSV); err == nil {
+		t.Errorf("Expected an error for CSV records without an ID column")
+	}
+}
+
+func TestRecordsRoundTrip(t *testing.T) {
+	secrets, err := RecordsToSecrets([]Record{
+		{ID: "https://172.16.0.101", Username: "root", Password: "hunter2"},
+		{ID: "default", Username: "ADMIN", Password: "ADMIN"},
+		{ID: "default", Username: "root", Password: "calvin"},
+		{ID: "snmp", Secret: `{"community":"public"}`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to convert records to secrets: %v", err)
+	}
+	expected := map[string]string{
+		"https://172.16.0.101": `{"username":"root","password":"hunter2"}`,
+		"default":              `{"candidates":[{"username":"ADMIN","password":"ADMIN"},{"username":"root","password":"calvin"}]}`,
+		"snmp":                 `{"community":"public"}`,
+	}
+	if !reflect.DeepEqual(secrets, expected) {
+		t.Errorf("Expected secrets %v, got %v", expected, secrets)
+	}
+
+	// secrets survive being written and read back in every format
+	for _, f := range []format.DataFormat{FORMAT_CSV, format.FORMAT_JSON, format.FORMAT_YAML, format.FORMAT_NDJSON} {
+		output, err := WriteRecords(SecretsToRecords(secrets), f)
+		if err != nil {
+			t.Fatalf("Failed to write records as %s: %v", f, err)
+		}
+		records, err := ReadRecords(output, f)
+		if err != nil {
+			t.Fatalf("Failed to read records as %s: %v", f, err)
+		}
+		read, err := RecordsToSecrets(records)
+		if err != nil {
+			t.Fatalf("Failed to convert records read as %s to secrets: %v", f, err)
+		}
+		if !reflect.DeepEqual(read, expected) {
+			t.Errorf("Expected secrets %v read as %s, got %v", expected, f, read)
+		}
+	}
+
+	for _, invalid := range [][]Record{
+		{{Username: "root", Password: "hunter2"}},
+		{{ID: "default", Password: "hunter2"}},
+		{{ID: "default", Username: "root", Password: "hunter2", Secret: "{}"}},
+		{{ID: "default", Username: "root", Password: "hunter2"}, {ID: "default", Secret: "{}"}},
+	} {
+		if _, err = RecordsToSecrets(invalid); err == nil {
+			t.Errorf("Expected an error for records %v", invalid)
+		}
+	}
+}
+
+func TestBundle(t *testing.T) {
+	masterKey, err := GenerateMasterKey()
+	if err != nil {
+		t.Fatalf("Failed to generate master key: %v", err)
+	}
+	bundleKey, err := GenerateMasterKey()
+	if err != nil {
+		t.Fatalf("Failed to generate bundle key: %v", err)
+	}
+
+	dir := t.TempDir()
+	store, err := NewLocalSecretStore(masterKey, filepath.Join(dir, "secrets.json"), true)
+	if err != nil {
+		t.Fatalf("Failed to create LocalSecretStore: %v", err)
+	}
+	for id, secret := range map[string]string{
+		"default": `{"username":"root","password":"hunter2"}`,
+		"snmp":    `{"community":"public"}`,
+	} {
+		if err = store.StoreSecretByID(id, secret); err != nil {
+			t.Fatalf("Failed to store secret: %v", err)
+		}
+	}
+
+	exported, err := ReadAllSecrets(store)
+	if err != nil {
+		t.Fatalf("Failed to read secrets: %v", err)
+	}
+	path := filepath.Join(dir, "secrets.bundle")
+	if err = WriteBundle(path, bundleKey, exported); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+	if err = WriteBundle(path, bundleKey, exported); err == nil {
+		t.Errorf("Expected an error when the bundle already exists")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected bundle to only be readable by its owner, got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	if _, err = ReadBundle(path, masterKey); err == nil {
+		t.Errorf("Expected an error when reading the bundle with the wrong key")
+	}
+	imported, err := ReadBundle(path, bundleKey)
+	if err != nil {
+		t.Fatalf("Failed to read bundle: %v", err)
+	}
+	if !reflect.DeepEqual(imported, exported) {
+		t.Errorf("Expected secrets %v from bundle, got %v", exported, imported)
+	}
+
+	// existing secrets are only replaced when asked to
+	other, err := NewLocalSecretStore(masterKey, filepath.Join(dir, "other.json"), true)
+	if err != nil {
+		t.Fatalf("Failed to create LocalSecretStore: %v", err)
+	}
+	if err = other.StoreSecretByID("default", `{"username":"admin","password":"admin"}`); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+	stored, skipped, err := StoreSecrets(other, imported, false)
+	if err != nil {
+		t.Fatalf("Failed to store secrets: %v", err)
+	}
+	if !reflect.DeepEqual(stored, []string{"snmp"}) || !reflect.DeepEqual(skipped, []string{"default"}) {
+		t.Errorf("Expected to store snmp and skip default, stored %v and skipped %v", stored, skipped)
+	}
+	if stored, _, err = StoreSecrets(other, imported, true); err != nil || len(stored) != 2 {
+		t.Errorf("Expected to store 2 secrets when overwriting, stored %v (%v)", stored, err)
+	}
+	if secret, _ := other.GetSecretByID("default"); secret != imported["default"] {
+		t.Errorf("Expected secret %s to be overwritten, got %s", imported["default"], secret)
+	}
+}
@@ -0,0 +1,356 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VAULT_SCHEME is the scheme of secret store paths that select a VaultStore,
+// e.g. 'vault://secret/magellan/bmcs'.
+const VAULT_SCHEME = "vault://"
+
+// The authentication methods supported by a VaultStore.
+const (
+	VaultAuthToken      = "token"
+	VaultAuthAppRole    = "approle"
+	VaultAuthKubernetes = "kubernetes"
+)
+
+// maxCASRetries is how many times a write is retried when the secret was
+// changed by someone else between reading and writing it.
+const maxCASRetries = 3
+
+// VaultConfig holds how to reach and authenticate to Vault. The defaults
+// come from the environment variables used by the Vault CLI, see
+// VaultConfigFromEnv().
+type VaultConfig struct {
+	Address    string        // the URL of the Vault server (VAULT_ADDR)
+	Namespace  string        // the Vault Enterprise namespace (VAULT_NAMESPACE)
+	AuthMethod string        // one of VaultAuthToken, VaultAuthAppRole or VaultAuthKubernetes (VAULT_AUTH_METHOD)
+	AuthMount  string        // the mount of the auth method if not its default (VAULT_AUTH_MOUNT)
+	Token      string        // the token for the 'token' auth method (VAULT_TOKEN)
+	RoleID     string        // the role ID for the 'approle' auth method (VAULT_ROLE_ID)
+	SecretID   string        // the secret ID for the 'approle' auth method (VAULT_SECRET_ID)
+	Role       string        // the role for the 'kubernetes' auth method (VAULT_ROLE)
+	JWTPath    string        // the service account token for the 'kubernetes' auth method (VAULT_K8S_TOKEN_PATH)
+	CACert     string        // the CA certificate to verify Vault with (VAULT_CACERT)
+	Insecure   bool          // skip verifying the certificate of Vault (VAULT_SKIP_VERIFY)
+	Timeout    time.Duration // the timeout of each request
+}
+
+// DefaultVaultConfig is the configuration used by OpenStore() for 'vault://'
+// paths. The CLI overrides it with the 'secrets.vault' section of the config
+// file.
+var DefaultVaultConfig = VaultConfigFromEnv()
+
+// VaultConfigFromEnv returns the Vault configuration set by the environment.
+func VaultConfigFromEnv() VaultConfig {
+	config := VaultConfig{
+		Address:    os.Getenv("VAULT_ADDR"),
+		Namespace:  os.Getenv("VAULT_NAMESPACE"),
+		AuthMethod: os.Getenv("VAULT_AUTH_METHOD"),
+		AuthMount:  os.Getenv("VAULT_AUTH_MOUNT"),
+		Token:      os.Getenv("VAULT_TOKEN"),
+		RoleID:     os.Getenv("VAULT_ROLE_ID"),
+		SecretID:   os.Getenv("VAULT_SECRET_ID"),
+		Role:       os.Getenv("VAULT_ROLE"),
+		JWTPath:    os.Getenv("VAULT_K8S_TOKEN_PATH"),
+		CACert:     os.Getenv("VAULT_CACERT"),
+		Timeout:    10 * time.Second,
+	}
+	config.Insecure, _ = strconv.ParseBool(os.Getenv("VAULT_SKIP_VERIFY"))
+	return config
+}
+
+// VaultStore keeps secrets in a single secret of a Vault KV version 2
+// secrets engine, with one key per secret ID, much like LocalSecretStore
+// keeps them in a single file. Vault encrypts the secrets itself, so they
+// are stored as is. Writes use check-and-set so that concurrent changes
+// to other secret IDs are not lost.
+type VaultStore struct {
+	mu     sync.Mutex
+	config VaultConfig
+	client *http.Client
+	token  string
+	mount  string
+	path   string
+}
+
+// vaultResponse is the part of a Vault API response used by VaultStore.
+type vaultResponse struct {
+	Errors []string `json:"errors"`
+	Auth   struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Data struct {
+		Data     map[string]string `json:"data"`
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	} `json:"data"`
+}
+
+// IsVaultURI reports whether a secret store path selects a VaultStore.
+func IsVaultURI(path string) bool {
+	return strings.HasPrefix(path, VAULT_SCHEME)
+}
+
+// ParseVaultURI splits a 'vault://<mount>/<path>' secret store path into the
+// mount of the KV version 2 secrets engine and the path of the secret.
+func ParseVaultURI(uri string) (string, string, error) {
+	if !strings.HasPrefix(uri, VAULT_SCHEME) {
+		return "", "", fmt.Errorf("'%s' is not a %s path", uri, VAULT_SCHEME)
+	}
+	mount, path, _ := strings.Cut(strings.Trim(strings.TrimPrefix(uri, VAULT_SCHEME), "/"), "/")
+	if mount == "" || path == "" {
+		return "", "", fmt.Errorf("expected %s<mount>/<path> but got '%s'", VAULT_SCHEME, uri)
+	}
+	return mount, path, nil
+}
+
+// NewVaultStore authenticates to Vault and returns a store for the secret
+// at a 'vault://<mount>/<path>' path.
+//
+// Parameters:
+//   - uri: The path of the secret as 'vault://<mount>/<path>', e.g. 'vault://secret/magellan/bmcs'.
+//   - config: How to reach and authenticate to Vault.
+//
+// Returns:
+//   - *VaultStore: The store, ready to use.
+//   - error: An error object if the path is invalid or authentication failed.
+func NewVaultStore(uri string, config VaultConfig) (*VaultStore, error) {
+	mount, path, err := ParseVaultURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	if config.Address == "" {
+		config.Address = "http://127.0.0.1:8200"
+	}
+	if config.AuthMethod == "" {
+		config.AuthMethod = VaultAuthToken
+	}
+	if config.AuthMount == "" {
+		config.AuthMount = config.AuthMethod
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: config.Insecure}
+	if config.CACert != "" {
+		pem, err := os.ReadFile(config.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Vault CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in Vault CA certificate '%s'", config.CACert)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	store := &VaultStore{
+		config: config,
+		client: &http.Client{Transport: transport, Timeout: config.Timeout},
+		mount:  mount,
+		path:   path,
+	}
+	if err := store.login(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// login gets a Vault token with the configured auth method.
+func (v *VaultStore) login() error {
+	var body map[string]string
+	switch v.config.AuthMethod {
+	case VaultAuthToken:
+		if v.config.Token == "" {
+			return fmt.Errorf("Vault token auth requires a token (VAULT_TOKEN)")
+		}
+		v.token = v.config.Token
+		return nil
+	case VaultAuthAppRole:
+		if v.config.RoleID == "" {
+			return fmt.Errorf("Vault AppRole auth requires a role ID (VAULT_ROLE_ID)")
+		}
+		body = map[string]string{"role_id": v.config.RoleID, "secret_id": v.config.SecretID}
+	case VaultAuthKubernetes:
+		if v.config.Role == "" {
+			return fmt.Errorf("Vault Kubernetes auth requires a role (VAULT_ROLE)")
+		}
+		jwtPath := v.config.JWTPath
+		if jwtPath == "" {
+			jwtPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+		}
+		jwt, err := os.ReadFile(jwtPath)
+		if err != nil {
+			return fmt.Errorf("failed to read Kubernetes service account token: %w", err)
+		}
+		body = map[string]string{"role": v.config.Role, "jwt": strings.TrimSpace(string(jwt))}
+	default:
+		return fmt.Errorf("invalid Vault auth method '%s', valid values are: %s, %s, %s", v.config.AuthMethod, VaultAuthToken, VaultAuthAppRole, VaultAuthKubernetes)
+	}
+
+	response, _, err := v.request(http.MethodPost, "auth/"+v.config.AuthMount+"/login", body)
+	if err != nil {
+		return fmt.Errorf("failed to log in to Vault with %s auth: %w", v.config.AuthMethod, err)
+	}
+	if response.Auth.ClientToken == "" {
+		return fmt.Errorf("failed to log in to Vault with %s auth: no token in response", v.config.AuthMethod)
+	}
+	v.token = response.Auth.ClientToken
+	return nil
+}
+
+// request sends a request to the Vault API and decodes the response. A
+// missing secret is not an error, but returns a status of 404.
+func (v *VaultStore) request(method string, path string, body any) (*vaultResponse, int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	endpoint, err := url.JoinPath(v.config.Address, "v1", path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid Vault address '%s': %w", v.config.Address, err)
+	}
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+	if v.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.config.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	var response vaultResponse
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, res.StatusCode, err
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &response); err != nil {
+			return nil, res.StatusCode, fmt.Errorf("failed to decode Vault response: %w", err)
+		}
+	}
+	if res.StatusCode == http.StatusNotFound && len(response.Errors) == 0 {
+		return &response, res.StatusCode, nil
+	}
+	if res.StatusCode >= 300 {
+		return &response, res.StatusCode, fmt.Errorf("Vault returned %s: %s", res.Status, strings.Join(response.Errors, "; "))
+	}
+	return &response, res.StatusCode, nil
+}
+
+// read returns all of the secrets with the version of the Vault secret
+// holding them, which is 0 if it doesn't exist yet.
+func (v *VaultStore) read() (map[string]string, int, error) {
+	response, status, err := v.request(http.MethodGet, v.mount+"/data/"+v.path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if status == http.StatusNotFound || response.Data.Data == nil {
+		// a deleted secret still has a version
+		return map[string]string{}, response.Data.Metadata.Version, nil
+	}
+	return response.Data.Data, response.Data.Metadata.Version, nil
+}
+
+// update changes the secrets with check-and-set, retrying if the secret
+// was changed in the meantime.
+func (v *VaultStore) update(change func(secrets map[string]string) error) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for range maxCASRetries {
+		secrets, version, err := v.read()
+		if err != nil {
+			return err
+		}
+		if err := change(secrets); err != nil {
+			return err
+		}
+		_, status, err := v.request(http.MethodPost, v.mount+"/data/"+v.path, map[string]any{
+			"options": map[string]int{"cas": version},
+			"data":    secrets,
+		})
+		if status == http.StatusBadRequest && err != nil && strings.Contains(err.Error(), "check-and-set") {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("secret %s/%s was changed concurrently too many times", v.mount, v.path)
+}
+
+// GetSecretByID returns the secret stored under secretID
+func (v *VaultStore) GetSecretByID(secretID string) (string, error) {
+	secrets, _, err := v.read()
+	if err != nil {
+		return "", err
+	}
+	secret, exists := secrets[secretID]
+	if !exists {
+		return "", fmt.Errorf("no secret found for %s", secretID)
+	}
+	return secret, nil
+}
+
+// StoreSecretByID stores the secret under secretID in Vault
+func (v *VaultStore) StoreSecretByID(secretID, secret string) error {
+	return v.update(func(secrets map[string]string) error {
+		secrets[secretID] = secret
+		return nil
+	})
+}
+
+// ListSecrets returns the secret IDs, with RedactedSecret in place of their
+// secrets
+func (v *VaultStore) ListSecrets() (map[string]string, error) {
+	secrets, _, err := v.read()
+	if err != nil {
+		return nil, err
+	}
+	for secretID := range secrets {
+		secrets[secretID] = RedactedSecret
+	}
+	return secrets, nil
+}
+
+// RemoveSecretByID removes the secret stored under secretID from Vault
+func (v *VaultStore) RemoveSecretByID(secretID string) error {
+	return v.update(func(secrets map[string]string) error {
+		if _, exists := secrets[secretID]; !exists {
+			return fmt.Errorf("no secret found for %s", secretID)
+		}
+		delete(secrets, secretID)
+		return nil
+	})
+}
@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/OpenCHAMI/magellan/pkg/test"
+)
+
+func TestVaultStore(t *testing.T) {
+	vault := test.NewVault("root")
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	store, err := NewVaultStore("vault://secret/magellan/bmcs", VaultConfig{Address: server.URL, Token: "root"})
+	if err != nil {
+		t.Fatalf("Failed to create VaultStore: %v", err)
+	}
+
+	// a missing secret is an empty store
+	secrets, err := store.ListSecrets()
+	if err != nil || len(secrets) != 0 {
+		t.Fatalf("Expected no secrets, got %v (%v)", secrets, err)
+	}
+	if _, err := store.GetSecretByID("default"); err == nil {
+		t.Errorf("Expected an error getting a missing secret")
+	}
+
+	creds := `{"username":"root","password":"initial0"}`
+	if err := store.StoreSecretByID("default", creds); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+	if secret, err := store.GetSecretByID("default"); err != nil || secret != creds {
+		t.Errorf("Expected %s, got %s (%v)", creds, secret, err)
+	}
+
+	// concurrent writes to different IDs are not lost
+	var wg sync.WaitGroup
+	for _, id := range []string{"https://172.16.0.1", "https://172.16.0.2"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := store.StoreSecretByID(id, creds); err != nil {
+				t.Errorf("Failed to store secret: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	// another client sees them
+	other, err := NewVaultStore("vault://secret/magellan/bmcs", VaultConfig{Address: server.URL, Token: "root"})
+	if err != nil {
+		t.Fatalf("Failed to create VaultStore: %v", err)
+	}
+	if secrets, err := other.ListSecrets(); err != nil || len(secrets) != 3 {
+		t.Errorf("Expected 3 secrets, got %v (%v)", secrets, err)
+	} else if secrets["default"] != RedactedSecret {
+		t.Errorf("Expected listed secrets to be redacted, got %s", secrets["default"])
+	}
+
+	if err := other.RemoveSecretByID("https://172.16.0.1"); err != nil {
+		t.Fatalf("Failed to remove secret: %v", err)
+	}
+	if _, err := store.GetSecretByID("https://172.16.0.1"); err == nil {
+		t.Errorf("Expected removed secret to be gone")
+	}
+	if err := store.RemoveSecretByID("https://172.16.0.1"); err == nil {
+		t.Errorf("Expected an error removing a missing secret")
+	}
+
+	// a bad token is rejected on the first request
+	bad, err := NewVaultStore("vault://secret/magellan/bmcs", VaultConfig{Address: server.URL, Token: "bad"})
+	if err != nil {
+		t.Fatalf("Failed to create VaultStore: %v", err)
+	}
+	if _, err := bad.ListSecrets(); err == nil {
+		t.Errorf("Expected an error with a bad token")
+	}
+}
+
+func TestVaultStoreAuth(t *testing.T) {
+	vault := test.NewVault("issued")
+	vault.RoleID, vault.SecretID = "role-id", "secret-id"
+	vault.Role, vault.JWT = "magellan", "service-account-jwt"
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	jwtPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwtPath, []byte(vault.JWT+"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+
+	for _, config := range []VaultConfig{
+		{Address: server.URL, AuthMethod: VaultAuthAppRole, RoleID: "role-id", SecretID: "secret-id"},
+		{Address: server.URL, AuthMethod: VaultAuthKubernetes, Role: "magellan", JWTPath: jwtPath},
+	} {
+		store, err := NewVaultStore("vault://secret/magellan/bmcs", config)
+		if err != nil {
+			t.Fatalf("Failed to log in with %s: %v", config.AuthMethod, err)
+		}
+		if err := store.StoreSecretByID("default", "{}"); err != nil {
+			t.Errorf("Failed to store secret with %s token: %v", config.AuthMethod, err)
+		}
+	}
+
+	for _, config := range []VaultConfig{
+		{Address: server.URL, AuthMethod: VaultAuthAppRole, RoleID: "role-id", SecretID: "wrong"},
+		{Address: server.URL, AuthMethod: VaultAuthKubernetes, Role: "other", JWTPath: jwtPath},
+		{Address: server.URL, AuthMethod: "ldap"},
+		{Address: server.URL},
+	} {
+		if _, err := NewVaultStore("vault://secret/magellan/bmcs", config); err == nil {
+			t.Errorf("Expected %s login to fail", config.AuthMethod)
+		}
+	}
+
+	for _, uri := range []string{"vault://secret", "vault:///bmcs", "secrets.json"} {
+		if _, _, err := ParseVaultURI(uri); err == nil {
+			t.Errorf("Expected an error parsing %s", uri)
+		}
+	}
+}
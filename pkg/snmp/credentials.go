@@ -0,0 +1,136 @@
+package snmp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/gosnmp/gosnmp"
+)
+
+// Credentials are the SNMP credentials of a PDU as kept in a secret store.
+// A community selects SNMPv2c; otherwise a username selects SNMPv3 with the
+// user-based security model. The password field is accepted as the
+// authentication passphrase so that entries stored with `magellan secrets
+// store` for a username and password can be used as is.
+type Credentials struct {
+	Community    string `json:"community,omitempty"`
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+	AuthProtocol string `json:"auth_protocol,omitempty"`
+	AuthPassword string `json:"auth_password,omitempty"`
+	PrivProtocol string `json:"priv_protocol,omitempty"`
+	PrivPassword string `json:"priv_password,omitempty"`
+}
+
+// GetCredentials looks up the SNMP credentials for a PDU in a secret store,
+// falling back to the default credentials when there are none for the ID.
+//
+// Parameters:
+//   - store: The secret store to look the credentials up in.
+//   - id: The secret ID of the PDU, usually its hostname.
+//
+// Returns:
+//   - Credentials: The credentials found for the PDU.
+//   - error: An error object if neither specific nor default credentials could be loaded.
+func GetCredentials(store secrets.SecretStore, id string) (Credentials, error) {
+	var creds Credentials
+	if store == nil {
+		return creds, fmt.Errorf("invalid secrets store")
+	}
+	secret, err := store.GetSecretByID(id)
+	if err != nil {
+		if secret, err = store.GetSecretByID(secrets.DEFAULT_KEY); err != nil {
+			return creds, fmt.Errorf("get SNMP credentials for %s from secret store: %w", id, err)
+		}
+	}
+	if err = json.Unmarshal([]byte(secret), &creds); err != nil {
+		return creds, fmt.Errorf("get SNMP credentials for %s from secret store: failed to unmarshal: %w", id, err)
+	}
+	if creds.Community == "" && creds.Username == "" {
+		return creds, fmt.Errorf("SNMP credentials for %s have neither a community nor a username", id)
+	}
+	return creds, nil
+}
+
+// apply sets the SNMP version and security parameters of a client
+// according to the credentials.
+func (creds Credentials) apply(client *gosnmp.GoSNMP) error {
+	if creds.Community != "" {
+		client.Version = gosnmp.Version2c
+		client.Community = creds.Community
+		return nil
+	}
+
+	authPassword := creds.AuthPassword
+	if authPassword == "" {
+		authPassword = creds.Password
+	}
+	params := &gosnmp.UsmSecurityParameters{
+		UserName:                 creds.Username,
+		AuthenticationProtocol:   gosnmp.NoAuth,
+		AuthenticationPassphrase: authPassword,
+		PrivacyProtocol:          gosnmp.NoPriv,
+		PrivacyPassphrase:        creds.PrivPassword,
+	}
+	client.Version = gosnmp.Version3
+	client.SecurityModel = gosnmp.UserSecurityModel
+	client.MsgFlags = gosnmp.NoAuthNoPriv
+	client.SecurityParameters = params
+
+	if authPassword == "" {
+		return nil
+	}
+	authProtocol, err := parseAuthProtocol(creds.AuthProtocol)
+	if err != nil {
+		return err
+	}
+	params.AuthenticationProtocol = authProtocol
+	client.MsgFlags = gosnmp.AuthNoPriv
+
+	if creds.PrivPassword == "" {
+		return nil
+	}
+	privProtocol, err := parsePrivProtocol(creds.PrivProtocol)
+	if err != nil {
+		return err
+	}
+	params.PrivacyProtocol = privProtocol
+	client.MsgFlags = gosnmp.AuthPriv
+	return nil
+}
+
+// parseAuthProtocol converts an authentication protocol name, defaulting to SHA.
+func parseAuthProtocol(protocol string) (gosnmp.SnmpV3AuthProtocol, error) {
+	switch strings.ToUpper(protocol) {
+	case "", "SHA":
+		return gosnmp.SHA, nil
+	case "MD5":
+		return gosnmp.MD5, nil
+	case "SHA224":
+		return gosnmp.SHA224, nil
+	case "SHA256":
+		return gosnmp.SHA256, nil
+	case "SHA384":
+		return gosnmp.SHA384, nil
+	case "SHA512":
+		return gosnmp.SHA512, nil
+	}
+	return gosnmp.NoAuth, fmt.Errorf("invalid SNMP authentication protocol '%s' (must be one of MD5, SHA, SHA224, SHA256, SHA384 or SHA512)", protocol)
+}
+
+// parsePrivProtocol converts a privacy protocol name, defaulting to AES.
+func parsePrivProtocol(protocol string) (gosnmp.SnmpV3PrivProtocol, error) {
+	switch strings.ToUpper(protocol) {
+	case "", "AES":
+		return gosnmp.AES, nil
+	case "DES":
+		return gosnmp.DES, nil
+	case "AES192":
+		return gosnmp.AES192, nil
+	case "AES256":
+		return gosnmp.AES256, nil
+	}
+	return gosnmp.NoPriv, fmt.Errorf("invalid SNMP privacy protocol '%s' (must be one of DES, AES, AES192 or AES256)", protocol)
+}
@@ -0,0 +1,462 @@
+package snmp
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/pkg/pdu"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/gosnmp/gosnmp"
+	"github.com/rs/zerolog/log"
+)
+
+const DefaultPort = 161
+
+const (
+	oidSysObjectID = ".1.3.6.1.2.1.1.2.0"
+
+	// ENTITY-MIB entPhysicalEntry
+	oidEntPhysicalEntry     = ".1.3.6.1.2.1.47.1.1.1.1"
+	colEntPhysicalClass     = 5
+	colEntPhysicalFirmRev   = 9
+	colEntPhysicalSerial    = 11
+	colEntPhysicalModel     = 13
+	entPhysicalClassChassis = 3
+
+	// APC PowerNet-MIB (rPDU2)
+	oidPowerNet                  = ".1.3.6.1.4.1.318"
+	oidRPDU2PhaseStatusEntry     = ".1.3.6.1.4.1.318.1.1.26.6.3.1"
+	oidRPDU2OutletSwitchedEntry  = ".1.3.6.1.4.1.318.1.1.26.9.2.3.1"
+	oidRPDU2OutletMeteredEntry   = ".1.3.6.1.4.1.318.1.1.26.9.4.3.1"
+	colRPDU2Module               = 2
+	colRPDU2OutletName           = 3
+	colRPDU2OutletNumber         = 4
+	colRPDU2OutletSwitchedState  = 5
+	colRPDU2OutletMeteredCurrent = 6
+	colRPDU2OutletMeteredPower   = 7
+	colRPDU2PhaseNumber          = 3
+	colRPDU2PhaseCurrent         = 5
+	colRPDU2PhaseVoltage         = 6
+	colRPDU2PhasePower           = 7
+
+	// Raritan PDU2-MIB
+	oidPDU2                         = ".1.3.6.1.4.1.13742"
+	oidPDU2OutletConfigEntry        = ".1.3.6.1.4.1.13742.6.3.5.3.1"
+	oidPDU2OutletSwitchControlEntry = ".1.3.6.1.4.1.13742.6.4.1.2.1"
+	oidPDU2InletSensorConfigEntry   = ".1.3.6.1.4.1.13742.6.3.3.4.1"
+	oidPDU2OutletSensorConfigEntry  = ".1.3.6.1.4.1.13742.6.3.5.4.1"
+	oidPDU2InletSensorEntry         = ".1.3.6.1.4.1.13742.6.5.2.3.1"
+	oidPDU2OutletSensorEntry        = ".1.3.6.1.4.1.13742.6.5.4.3.1"
+	colPDU2OutletLabel              = 2
+	colPDU2OutletName               = 3
+	colPDU2OutletSwitchingState     = 3
+	colPDU2SensorDecimalDigits      = 7
+	colPDU2SensorValue              = 4
+	pdu2SensorRMSCurrent            = 1
+	pdu2SensorRMSVoltage            = 4
+	pdu2SensorActivePower           = 5
+	pdu2StateOn                     = 7
+	pdu2StateOff                    = 8
+)
+
+type CrawlerConfig struct {
+	// Host is the address of the PDU, optionally with a port.
+	Host string
+	// SecretID is the ID of the credentials in the store. Defaults to the host.
+	SecretID        string
+	CredentialStore secrets.SecretStore
+	Timeout         time.Duration
+	Retries         int
+}
+
+// row is a single row of an SNMP table, with its index and values by column.
+type row struct {
+	index   []int
+	columns map[int]gosnmp.SnmpPDU
+}
+
+// CrawlPDU connects to a PDU over SNMPv2c or SNMPv3 and collects its outlet
+// inventory, in the same form as the JAWS and Redfish crawlers. The outlet
+// tables of APC (PowerNet-MIB) and Raritan (PDU2-MIB) PDUs are supported and
+// chosen by the sysObjectID of the agent; the identity of the PDU comes from
+// the ENTITY-MIB when the agent implements it.
+//
+// Parameters:
+//   - config: A CrawlerConfig struct containing the address of the PDU and the store with its SNMP credentials.
+//
+// Returns:
+//   - *pdu.PDUInventory: The inventory of the PDU.
+//   - error: An error object if the PDU could not be reached or has no outlets in either MIB.
+func CrawlPDU(config CrawlerConfig) (*pdu.PDUInventory, error) {
+	client, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Conn.Close()
+
+	inventory := &pdu.PDUInventory{Hostname: config.Host}
+
+	var sysObjectID string
+	res, err := client.Get([]string{oidSysObjectID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sysObjectID from %s: %w", config.Host, err)
+	}
+	if len(res.Variables) > 0 {
+		sysObjectID, _ = res.Variables[0].Value.(string)
+	}
+	log.Debug().Str("host", config.Host).Msgf("SNMP agent has sysObjectID %s", sysObjectID)
+
+	switch {
+	case strings.HasPrefix(sysObjectID, oidPowerNet+"."):
+		err = crawlPowerNet(client, inventory)
+	case strings.HasPrefix(sysObjectID, oidPDU2+"."):
+		err = crawlPDU2(client, inventory)
+	default:
+		// unknown agent, so look for either outlet table
+		if err = crawlPowerNet(client, inventory); err == nil && len(inventory.Outlets) == 0 {
+			err = crawlPDU2(client, inventory)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(inventory.Outlets) == 0 {
+		return nil, fmt.Errorf("no outlets found in PowerNet-MIB or PDU2-MIB on %s", config.Host)
+	}
+
+	// the identity is optional, so only log failures
+	if err := getIdentity(client, inventory); err != nil {
+		log.Warn().Err(err).Str("host", config.Host).Msg("failed to get PDU identity from ENTITY-MIB")
+	}
+
+	log.Debug().Msgf("collected inventory for %d outlets from %s", len(inventory.Outlets), config.Host)
+	return inventory, nil
+}
+
+// connect creates an SNMP client for the PDU using its credentials from
+// the secret store.
+func connect(config CrawlerConfig) (*gosnmp.GoSNMP, error) {
+	secretID := config.SecretID
+	if secretID == "" {
+		secretID = config.Host
+	}
+	creds, err := GetCredentials(config.CredentialStore, secretID)
+	if err != nil {
+		return nil, err
+	}
+
+	host, port := config.Host, uint16(DefaultPort)
+	if h, p, err := net.SplitHostPort(config.Host); err == nil {
+		n, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in SNMP host '%s': %w", config.Host, err)
+		}
+		host, port = h, uint16(n)
+	}
+
+	client := &gosnmp.GoSNMP{
+		Target:  host,
+		Port:    port,
+		Timeout: config.Timeout,
+		Retries: config.Retries,
+		MaxOids: gosnmp.MaxOids,
+	}
+	if client.Timeout == 0 {
+		client.Timeout = gosnmp.Default.Timeout
+	}
+	if err := creds.apply(client); err != nil {
+		return nil, err
+	}
+	if err := client.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to SNMP agent on %s: %w", config.Host, err)
+	}
+	return client, nil
+}
+
+// crawlPowerNet collects the outlets and phases of an APC rack PDU from the
+// rPDU2 tables. Switched and metered outlets are merged by module and number.
+func crawlPowerNet(client *gosnmp.GoSNMP, inventory *pdu.PDUInventory) error {
+	switched, err := walkTable(client, oidRPDU2OutletSwitchedEntry)
+	if err != nil {
+		return fmt.Errorf("failed to get rPDU2 switched outlets: %w", err)
+	}
+	metered, err := walkTable(client, oidRPDU2OutletMeteredEntry)
+	if err != nil {
+		return fmt.Errorf("failed to get rPDU2 metered outlets: %w", err)
+	}
+
+	var (
+		outlets = map[string]*pdu.PDUOutlet{}
+		order   []string
+	)
+	outlet := func(r row) *pdu.PDUOutlet {
+		id := fmt.Sprintf("%s%d", moduleLetter(toInt(r.columns[colRPDU2Module])), toInt(r.columns[colRPDU2OutletNumber]))
+		if o, ok := outlets[id]; ok {
+			return o
+		}
+		o := &pdu.PDUOutlet{ID: id, Name: toString(r.columns[colRPDU2OutletName])}
+		outlets[id] = o
+		order = append(order, id)
+		return o
+	}
+	for _, r := range switched {
+		o := outlet(r)
+		switch toInt(r.columns[colRPDU2OutletSwitchedState]) {
+		case 1:
+			o.PowerState = "Off"
+		case 2:
+			o.PowerState = "On"
+		}
+	}
+	for _, r := range metered {
+		o := outlet(r)
+		o.Current = float64(toInt(r.columns[colRPDU2OutletMeteredCurrent])) / 10
+		o.ActivePower = float64(toInt(r.columns[colRPDU2OutletMeteredPower]))
+	}
+	for _, id := range order {
+		inventory.Outlets = append(inventory.Outlets, *outlets[id])
+	}
+
+	phases, err := walkTable(client, oidRPDU2PhaseStatusEntry)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to get rPDU2 phases")
+	}
+	for _, r := range phases {
+		number := toInt(r.columns[colRPDU2PhaseNumber])
+		inventory.Phases = append(inventory.Phases, pdu.PDUCircuit{
+			ID:      fmt.Sprintf("%s:L%d", moduleLetter(toInt(r.columns[colRPDU2Module])), number),
+			Name:    fmt.Sprintf("Phase %d", number),
+			Current: float64(toInt(r.columns[colRPDU2PhaseCurrent])) / 10,
+			Voltage: float64(toInt(r.columns[colRPDU2PhaseVoltage])),
+			// reported in hundredths of kW
+			ActivePower: float64(toInt(r.columns[colRPDU2PhasePower])) * 10,
+		})
+	}
+	return nil
+}
+
+// crawlPDU2 collects the outlets and inlets of a Raritan PDU. Sensor values
+// are scaled by the number of decimal digits configured for each sensor.
+func crawlPDU2(client *gosnmp.GoSNMP, inventory *pdu.PDUInventory) error {
+	configs, err := walkTable(client, oidPDU2OutletConfigEntry)
+	if err != nil {
+		return fmt.Errorf("failed to get PDU2 outlets: %w", err)
+	}
+	states, err := walkTable(client, oidPDU2OutletSwitchControlEntry)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to get PDU2 outlet switching states")
+	}
+	readings, err := getSensorReadings(client, oidPDU2OutletSensorEntry, oidPDU2OutletSensorConfigEntry)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to get PDU2 outlet sensors")
+	}
+
+	stateByIndex := map[string]int{}
+	for _, r := range states {
+		stateByIndex[indexString(r.index)] = toInt(r.columns[colPDU2OutletSwitchingState])
+	}
+	for _, r := range configs {
+		if len(r.index) != 2 {
+			continue
+		}
+		key := indexString(r.index)
+		name := toString(r.columns[colPDU2OutletName])
+		if name == "" {
+			name = toString(r.columns[colPDU2OutletLabel])
+		}
+		outlet := pdu.PDUOutlet{
+			ID:          fmt.Sprintf("%s%d", moduleLetter(r.index[0]), r.index[1]),
+			Name:        name,
+			Current:     readings[key][pdu2SensorRMSCurrent],
+			Voltage:     readings[key][pdu2SensorRMSVoltage],
+			ActivePower: readings[key][pdu2SensorActivePower],
+		}
+		switch stateByIndex[key] {
+		case pdu2StateOn:
+			outlet.PowerState = "On"
+		case pdu2StateOff:
+			outlet.PowerState = "Off"
+		}
+		inventory.Outlets = append(inventory.Outlets, outlet)
+	}
+
+	inlets, err := getSensorReadings(client, oidPDU2InletSensorEntry, oidPDU2InletSensorConfigEntry)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to get PDU2 inlet sensors")
+	}
+	keys := make([]string, 0, len(inlets))
+	for key := range inlets {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		inventory.Phases = append(inventory.Phases, pdu.PDUCircuit{
+			ID:          "I" + key,
+			Name:        "Inlet " + key,
+			Current:     inlets[key][pdu2SensorRMSCurrent],
+			Voltage:     inlets[key][pdu2SensorRMSVoltage],
+			ActivePower: inlets[key][pdu2SensorActivePower],
+		})
+	}
+	return nil
+}
+
+// getSensorReadings walks a PDU2-MIB sensor measurements table indexed by
+// PDU, outlet or inlet and sensor type, and returns the scaled readings by
+// PDU and outlet or inlet, then sensor type.
+func getSensorReadings(client *gosnmp.GoSNMP, valueEntry string, configEntry string) (map[string]map[int]float64, error) {
+	values, err := walkTable(client, valueEntry)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := walkTable(client, configEntry)
+	if err != nil {
+		return nil, err
+	}
+	digits := map[string]int{}
+	for _, r := range configs {
+		digits[indexString(r.index)] = toInt(r.columns[colPDU2SensorDecimalDigits])
+	}
+
+	readings := map[string]map[int]float64{}
+	for _, r := range values {
+		if len(r.index) != 3 {
+			continue
+		}
+		key := indexString(r.index[:2])
+		if readings[key] == nil {
+			readings[key] = map[int]float64{}
+		}
+		value := float64(toInt(r.columns[colPDU2SensorValue]))
+		readings[key][r.index[2]] = value / math.Pow10(digits[indexString(r.index)])
+	}
+	return readings, nil
+}
+
+// getIdentity sets the model, serial number and firmware version of the PDU
+// from the first chassis in the ENTITY-MIB, or the first entity with a
+// serial number if there is no chassis.
+func getIdentity(client *gosnmp.GoSNMP, inventory *pdu.PDUInventory) error {
+	entities, err := walkTable(client, oidEntPhysicalEntry)
+	if err != nil {
+		return err
+	}
+	if len(entities) == 0 {
+		return fmt.Errorf("no physical entities found")
+	}
+	index := slices.IndexFunc(entities, func(r row) bool {
+		return toInt(r.columns[colEntPhysicalClass]) == entPhysicalClassChassis
+	})
+	if index < 0 {
+		index = slices.IndexFunc(entities, func(r row) bool {
+			return toString(r.columns[colEntPhysicalSerial]) != ""
+		})
+	}
+	if index < 0 {
+		return fmt.Errorf("no chassis found in physical entities")
+	}
+	entity := entities[index]
+	inventory.Model = toString(entity.columns[colEntPhysicalModel])
+	inventory.SerialNumber = toString(entity.columns[colEntPhysicalSerial])
+	inventory.FirmwareVersion = toString(entity.columns[colEntPhysicalFirmRev])
+	return nil
+}
+
+// walkTable walks the columns of an SNMP table entry and returns its rows in
+// the order of their first appearance. A missing table yields no rows.
+func walkTable(client *gosnmp.GoSNMP, entryOID string) ([]row, error) {
+	walk := client.BulkWalkAll
+	if client.Version == gosnmp.Version1 {
+		walk = client.WalkAll
+	}
+	variables, err := walk(entryOID)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		rows    []row
+		indexes = map[string]int{}
+	)
+	for _, variable := range variables {
+		suffix, ok := strings.CutPrefix(variable.Name, entryOID+".")
+		if !ok {
+			continue
+		}
+		column, index, ok := strings.Cut(suffix, ".")
+		if !ok {
+			continue
+		}
+		col, err := strconv.Atoi(column)
+		if err != nil {
+			continue
+		}
+		i, ok := indexes[index]
+		if !ok {
+			parsed, err := parseIndex(index)
+			if err != nil {
+				continue
+			}
+			i = len(rows)
+			indexes[index] = i
+			rows = append(rows, row{index: parsed, columns: map[int]gosnmp.SnmpPDU{}})
+		}
+		rows[i].columns[col] = variable
+	}
+	return rows, nil
+}
+
+func parseIndex(index string) ([]int, error) {
+	var parsed []int
+	for _, part := range strings.Split(index, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, n)
+	}
+	return parsed, nil
+}
+
+func indexString(index []int) string {
+	parts := make([]string, len(index))
+	for i, n := range index {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+// moduleLetter converts a 1-based module or PDU number into the letter used
+// in outlet IDs, so that outlet 3 of the first module becomes "A3".
+func moduleLetter(module int) string {
+	if module < 1 || module > 26 {
+		return "A"
+	}
+	return string(rune('A' + module - 1))
+}
+
+func toInt(variable gosnmp.SnmpPDU) int {
+	if variable.Value == nil {
+		return 0
+	}
+	switch variable.Type {
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+		return int(gosnmp.ToBigInt(variable.Value).Int64())
+	}
+	return 0
+}
+
+func toString(variable gosnmp.SnmpPDU) string {
+	switch value := variable.Value.(type) {
+	case []byte:
+		return strings.TrimSpace(string(value))
+	case string:
+		return strings.TrimSpace(value)
+	}
+	return ""
+}
@@ -0,0 +1,251 @@
+package snmp
+
+import (
+	"net"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/magellan/pkg/pdu"
+	"github.com/OpenCHAMI/magellan/pkg/secrets"
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/require"
+)
+
+// startAgent runs a minimal SNMPv2c agent on a local UDP port that answers
+// get, get-next and get-bulk requests from a fixed set of variables. Requests
+// with another community are dropped, like real agents do.
+func startAgent(t *testing.T, community string, variables []gosnmp.SnmpPDU) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	slices.SortFunc(variables, func(a, b gosnmp.SnmpPDU) int {
+		return slices.Compare(parseOID(a.Name), parseOID(b.Name))
+	})
+	next := func(name string) gosnmp.SnmpPDU {
+		oid := parseOID(name)
+		for _, variable := range variables {
+			if slices.Compare(parseOID(variable.Name), oid) > 0 {
+				return variable
+			}
+		}
+		return gosnmp.SnmpPDU{Name: name, Type: gosnmp.EndOfMibView}
+	}
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request, err := (&gosnmp.GoSNMP{}).SnmpDecodePacket(buf[:n])
+			if err != nil || request.Community != community {
+				continue
+			}
+
+			var results []gosnmp.SnmpPDU
+			for _, requested := range request.Variables {
+				switch request.PDUType {
+				case gosnmp.GetRequest:
+					index := slices.IndexFunc(variables, func(v gosnmp.SnmpPDU) bool { return v.Name == requested.Name })
+					if index < 0 {
+						results = append(results, gosnmp.SnmpPDU{Name: requested.Name, Type: gosnmp.NoSuchObject})
+					} else {
+						results = append(results, variables[index])
+					}
+				case gosnmp.GetNextRequest:
+					results = append(results, next(requested.Name))
+				case gosnmp.GetBulkRequest:
+					name := requested.Name
+					for range request.MaxRepetitions {
+						variable := next(name)
+						results = append(results, variable)
+						if variable.Type == gosnmp.EndOfMibView {
+							break
+						}
+						name = variable.Name
+					}
+				}
+			}
+
+			response := &gosnmp.SnmpPacket{
+				Version:   request.Version,
+				Community: request.Community,
+				PDUType:   gosnmp.GetResponse,
+				RequestID: request.RequestID,
+				Variables: results,
+			}
+			out, err := response.MarshalMsg()
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteTo(out, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func parseOID(name string) []int {
+	var oid []int
+	for _, part := range strings.Split(strings.TrimPrefix(name, "."), ".") {
+		n, _ := strconv.Atoi(part)
+		oid = append(oid, n)
+	}
+	return oid
+}
+
+func integer(name string, value int) gosnmp.SnmpPDU {
+	return gosnmp.SnmpPDU{Name: name, Type: gosnmp.Integer, Value: value}
+}
+
+func gauge(name string, value uint32) gosnmp.SnmpPDU {
+	return gosnmp.SnmpPDU{Name: name, Type: gosnmp.Gauge32, Value: value}
+}
+
+func octets(name string, value string) gosnmp.SnmpPDU {
+	return gosnmp.SnmpPDU{Name: name, Type: gosnmp.OctetString, Value: []byte(value)}
+}
+
+func newStore(t *testing.T, creds map[string]string) secrets.SecretStore {
+	t.Helper()
+
+	masterKey, err := secrets.GenerateMasterKey()
+	require.NoError(t, err)
+	store, err := secrets.NewLocalSecretStore(masterKey, filepath.Join(t.TempDir(), "secrets.json"), true)
+	require.NoError(t, err)
+	for id, secret := range creds {
+		require.NoError(t, store.StoreSecretByID(id, secret))
+	}
+	return store
+}
+
+func TestCrawlPowerNetPDU(t *testing.T) {
+	t.Parallel()
+
+	host := startAgent(t, "private", []gosnmp.SnmpPDU{
+		{Name: oidSysObjectID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.318.1.3.4.6"},
+		// two switched outlets of module 1, of which the first is metered
+		integer(oidRPDU2OutletSwitchedEntry+".2.1", 1),
+		integer(oidRPDU2OutletSwitchedEntry+".2.2", 1),
+		octets(oidRPDU2OutletSwitchedEntry+".3.1", "x3000c0s1b0"),
+		octets(oidRPDU2OutletSwitchedEntry+".3.2", "Outlet 2"),
+		integer(oidRPDU2OutletSwitchedEntry+".4.1", 1),
+		integer(oidRPDU2OutletSwitchedEntry+".4.2", 2),
+		integer(oidRPDU2OutletSwitchedEntry+".5.1", 2),
+		integer(oidRPDU2OutletSwitchedEntry+".5.2", 1),
+		integer(oidRPDU2OutletMeteredEntry+".2.1", 1),
+		octets(oidRPDU2OutletMeteredEntry+".3.1", "x3000c0s1b0"),
+		integer(oidRPDU2OutletMeteredEntry+".4.1", 1),
+		gauge(oidRPDU2OutletMeteredEntry+".6.1", 17),
+		gauge(oidRPDU2OutletMeteredEntry+".7.1", 198),
+		integer(oidRPDU2PhaseStatusEntry+".2.1", 1),
+		integer(oidRPDU2PhaseStatusEntry+".3.1", 1),
+		gauge(oidRPDU2PhaseStatusEntry+".5.1", 21),
+		integer(oidRPDU2PhaseStatusEntry+".6.1", 208),
+		gauge(oidRPDU2PhaseStatusEntry+".7.1", 24),
+		// a module and the chassis in the ENTITY-MIB
+		integer(oidEntPhysicalEntry+".5.1", 9),
+		integer(oidEntPhysicalEntry+".5.2", 3),
+		octets(oidEntPhysicalEntry+".9.2", "v6.9.6"),
+		octets(oidEntPhysicalEntry+".11.1", "MOD0001"),
+		octets(oidEntPhysicalEntry+".11.2", "5A1234E56789"),
+		octets(oidEntPhysicalEntry+".13.2", "AP8861"),
+	})
+
+	// the PDU has no specific credentials, so the default ones are used
+	store := newStore(t, map[string]string{secrets.DEFAULT_KEY: `{"community": "private"}`})
+	inventory, err := CrawlPDU(CrawlerConfig{Host: host, CredentialStore: store, Timeout: time.Second})
+	require.NoError(t, err)
+	require.Equal(t, host, inventory.Hostname)
+	require.Equal(t, "AP8861", inventory.Model)
+	require.Equal(t, "5A1234E56789", inventory.SerialNumber)
+	require.Equal(t, "v6.9.6", inventory.FirmwareVersion)
+	require.Equal(t, []pdu.PDUOutlet{
+		{ID: "A1", Name: "x3000c0s1b0", PowerState: "On", Current: 1.7, ActivePower: 198},
+		{ID: "A2", Name: "Outlet 2", PowerState: "Off"},
+	}, inventory.Outlets)
+	require.Equal(t, []pdu.PDUCircuit{{ID: "A:L1", Name: "Phase 1", Current: 2.1, Voltage: 208, ActivePower: 240}}, inventory.Phases)
+
+	// a wrong community gets no answer
+	store = newStore(t, map[string]string{host: `{"community": "public"}`})
+	_, err = CrawlPDU(CrawlerConfig{Host: host, CredentialStore: store, Timeout: 100 * time.Millisecond})
+	require.Error(t, err)
+}
+
+func TestCrawlPDU2PDU(t *testing.T) {
+	t.Parallel()
+
+	host := startAgent(t, "public", []gosnmp.SnmpPDU{
+		{Name: oidSysObjectID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.13742.6"},
+		octets(oidPDU2OutletConfigEntry+".2.1.1", "1"),
+		octets(oidPDU2OutletConfigEntry+".2.1.2", "2"),
+		octets(oidPDU2OutletConfigEntry+".3.1.1", "x3000c0s3b0"),
+		octets(oidPDU2OutletConfigEntry+".3.1.2", ""),
+		integer(oidPDU2OutletSwitchControlEntry+".3.1.1", pdu2StateOn),
+		integer(oidPDU2OutletSwitchControlEntry+".3.1.2", pdu2StateOff),
+		gauge(oidPDU2OutletSensorConfigEntry+".7.1.1.1", 3),
+		gauge(oidPDU2OutletSensorConfigEntry+".7.1.1.4", 0),
+		gauge(oidPDU2OutletSensorConfigEntry+".7.1.1.5", 0),
+		gauge(oidPDU2OutletSensorEntry+".4.1.1.1", 1250),
+		gauge(oidPDU2OutletSensorEntry+".4.1.1.4", 230),
+		gauge(oidPDU2OutletSensorEntry+".4.1.1.5", 287),
+		gauge(oidPDU2InletSensorConfigEntry+".7.1.1.1", 1),
+		gauge(oidPDU2InletSensorEntry+".4.1.1.1", 14),
+		gauge(oidPDU2InletSensorEntry+".4.1.1.4", 230),
+		gauge(oidPDU2InletSensorEntry+".4.1.1.5", 290),
+	})
+
+	store := newStore(t, map[string]string{host: `{"community": "public"}`})
+	inventory, err := CrawlPDU(CrawlerConfig{Host: host, CredentialStore: store, Timeout: time.Second})
+	require.NoError(t, err)
+	require.Empty(t, inventory.Model)
+	require.Equal(t, []pdu.PDUOutlet{
+		{ID: "A1", Name: "x3000c0s3b0", PowerState: "On", Current: 1.25, Voltage: 230, ActivePower: 287},
+		{ID: "A2", Name: "2", PowerState: "Off"},
+	}, inventory.Outlets)
+	require.Equal(t, []pdu.PDUCircuit{{ID: "I1.1", Name: "Inlet 1.1", Current: 1.4, Voltage: 230, ActivePower: 290}}, inventory.Phases)
+	require.Equal(t, 290.0, inventory.TotalActivePower())
+}
+
+func TestCredentials(t *testing.T) {
+	t.Parallel()
+
+	store := newStore(t, map[string]string{
+		"x3000m0": `{"username": "monitor", "auth_protocol": "sha256", "auth_password": "authpass", "priv_password": "privpass"}`,
+		"x3000m1": `{"username": "admin", "password": "secret"}`,
+		"x3000m2": `{"username": "admin", "auth_password": "secret", "auth_protocol": "rot13"}`,
+	})
+
+	creds, err := GetCredentials(store, "x3000m0")
+	require.NoError(t, err)
+	client := &gosnmp.GoSNMP{}
+	require.NoError(t, creds.apply(client))
+	require.Equal(t, gosnmp.Version3, client.Version)
+	require.Equal(t, gosnmp.AuthPriv, client.MsgFlags)
+	params := client.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	require.Equal(t, gosnmp.SHA256, params.AuthenticationProtocol)
+	require.Equal(t, gosnmp.AES, params.PrivacyProtocol)
+
+	// a username and password are used for authentication without privacy
+	creds, err = GetCredentials(store, "x3000m1")
+	require.NoError(t, err)
+	client = &gosnmp.GoSNMP{}
+	require.NoError(t, creds.apply(client))
+	require.Equal(t, gosnmp.AuthNoPriv, client.MsgFlags)
+	require.Equal(t, "secret", client.SecurityParameters.(*gosnmp.UsmSecurityParameters).AuthenticationPassphrase)
+
+	creds, err = GetCredentials(store, "x3000m2")
+	require.NoError(t, err)
+	require.Error(t, creds.apply(&gosnmp.GoSNMP{}))
+
+	// there are no default credentials to fall back to
+	_, err = GetCredentials(store, "x3000m3")
+	require.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Vault is a stand-in for a Vault server in dev mode, with a KV version 2
+// secrets engine at 'secret/' and the AppRole and Kubernetes auth methods
+// enabled. It only implements the API used by secrets.VaultStore.
+type Vault struct {
+	RootToken string // the token accepted for every request
+	RoleID    string // the AppRole role ID that logs in
+	SecretID  string // the AppRole secret ID that logs in
+	Role      string // the Kubernetes role that logs in
+	JWT       string // the Kubernetes service account token that logs in
+
+	mu       sync.Mutex
+	secrets  map[string]map[string]string
+	versions map[string]int
+}
+
+// NewVault returns a Vault stand-in that accepts the given root token.
+func NewVault(rootToken string) *Vault {
+	return &Vault{
+		RootToken: rootToken,
+		secrets:   map[string]map[string]string{},
+		versions:  map[string]int{},
+	}
+}
+
+func writeVaultResponse(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeVaultErrors(w http.ResponseWriter, status int, errors ...string) {
+	writeVaultResponse(w, status, map[string]any{"errors": errors})
+}
+
+func (v *Vault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	switch {
+	case r.Method == http.MethodPost && path == "auth/approle/login":
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if v.RoleID == "" || body["role_id"] != v.RoleID || body["secret_id"] != v.SecretID {
+			writeVaultErrors(w, http.StatusBadRequest, "invalid role or secret ID")
+			return
+		}
+		writeVaultResponse(w, http.StatusOK, map[string]any{"auth": map[string]any{"client_token": v.RootToken}})
+		return
+	case r.Method == http.MethodPost && path == "auth/kubernetes/login":
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if v.Role == "" || body["role"] != v.Role || body["jwt"] != v.JWT {
+			writeVaultErrors(w, http.StatusForbidden, "permission denied")
+			return
+		}
+		writeVaultResponse(w, http.StatusOK, map[string]any{"auth": map[string]any{"client_token": v.RootToken}})
+		return
+	}
+
+	if r.Header.Get("X-Vault-Token") != v.RootToken {
+		writeVaultErrors(w, http.StatusForbidden, "permission denied")
+		return
+	}
+	secretPath, ok := strings.CutPrefix(path, "secret/data/")
+	if !ok {
+		writeVaultErrors(w, http.StatusNotFound, "no handler for route '"+path+"'")
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		data, exists := v.secrets[secretPath]
+		if !exists {
+			writeVaultErrors(w, http.StatusNotFound)
+			return
+		}
+		writeVaultResponse(w, http.StatusOK, map[string]any{
+			"data": map[string]any{
+				"data":     data,
+				"metadata": map[string]any{"version": v.versions[secretPath]},
+			},
+		})
+	case http.MethodPost, http.MethodPut:
+		var body struct {
+			Options struct {
+				CAS *int `json:"cas"`
+			} `json:"options"`
+			Data map[string]string `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeVaultErrors(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if body.Options.CAS != nil && *body.Options.CAS != v.versions[secretPath] {
+			writeVaultErrors(w, http.StatusBadRequest, "check-and-set parameter did not match the current version")
+			return
+		}
+		v.versions[secretPath]++
+		v.secrets[secretPath] = body.Data
+		writeVaultResponse(w, http.StatusOK, map[string]any{"data": map[string]any{"version": v.versions[secretPath]}})
+	default:
+		writeVaultErrors(w, http.StatusMethodNotAllowed)
+	}
+}
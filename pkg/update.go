@@ -37,7 +37,7 @@ func UpdateFirmwareRemote(q *UpdateParams) error {
 	}
 
 	// Get BMC credentials from secret store in update parameters
-	bmcCreds, err := bmc.GetBMCCredentials(q.SecretStore, q.URI)
+	bmcCreds, _, err := bmc.GetMatchingBMCCredentials(q.SecretStore, q.URI, nil)
 	if err != nil {
 		return fmt.Errorf("failed to get BMC credentials: %w", err)
 	}
@@ -80,7 +80,7 @@ func GetUpdateStatus(q *UpdateParams) error {
 	}
 
 	// Get BMC credentials from secret store in update parameters
-	bmcCreds, err := bmc.GetBMCCredentials(q.SecretStore, q.URI)
+	bmcCreds, _, err := bmc.GetMatchingBMCCredentials(q.SecretStore, q.URI, nil)
 	if err != nil {
 		return fmt.Errorf("failed to get BMC credentials: %w", err)
 	}